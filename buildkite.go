@@ -1,31 +1,215 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
-	"net/url"
 	"os"
-	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
 const buildkiteBaseURL = "https://api.buildkite.com/v2"
-const buildkiteMaxPages = 10 // Fetch up to 10 pages (1000 builds)
+
+// buildkiteMaxPages is the safety cap on pages per pipeline (BUILDKITE_MAX_PAGES, default 30 = 3000 builds).
+// Pagination normally stops at BuildKite's last page; hitting the cap is reported as truncated in meta.
+var buildkiteMaxPages = 30
+
 const buildkitePerPage = 100
 
+// buildkiteConfig returns the token and the default (first) org from BUILDKITE_ORG.
 func buildkiteConfig() (token, org string, ok bool) {
 	token = strings.TrimSpace(os.Getenv("BUILDKITE_TOKEN"))
-	org = strings.TrimSpace(os.Getenv("BUILDKITE_ORG"))
-	if token == "" || org == "" {
+	orgs := buildkiteOrgs()
+	if token == "" || len(orgs) == 0 {
 		return "", "", false
 	}
-	return token, org, true
+	return token, orgs[0], true
+}
+
+// defaultBuildkitePipelines are the deployment pipelines used when BUILDKITE_PIPELINES is unset.
+var defaultBuildkitePipelines = []string{
+	"core-stack-deployment-pipeline",
+	"core-stack-deployment-pipeline-legacy",
+}
+
+// buildkiteDeploymentPipelines are the pipelines fetched and counted as deployments by default, from
+// comma-separated BUILDKITE_PIPELINES, read once at startup by loadConfig.
+var buildkiteDeploymentPipelines = defaultBuildkitePipelines
+
+func loadBuildkitePipelines() []string {
+	var pipelines []string
+	for _, p := range strings.Split(os.Getenv("BUILDKITE_PIPELINES"), ",") {
+		p = strings.ToLower(strings.TrimSpace(p))
+		if p == "" {
+			continue
+		}
+		if !buildkiteSlugPattern.MatchString(p) {
+			log.Printf("[Config] Ignoring invalid BUILDKITE_PIPELINES slug %q", p)
+			continue
+		}
+		pipelines = append(pipelines, p)
+	}
+	if len(pipelines) == 0 {
+		return defaultBuildkitePipelines
+	}
+	return pipelines
+}
+
+// buildkiteOrgs returns the configured org slugs; BUILDKITE_ORG may be a comma-separated list.
+func buildkiteOrgs() []string {
+	var orgs []string
+	for _, o := range strings.Split(os.Getenv("BUILDKITE_ORG"), ",") {
+		if o = strings.TrimSpace(o); o != "" {
+			orgs = append(orgs, o)
+		}
+	}
+	return orgs
+}
+
+// selectBuildkiteOrgs resolves ?org= against the configured orgs: empty means the first org, "all" means every org.
+func selectBuildkiteOrgs(c *gin.Context) ([]string, error) {
+	orgs := buildkiteOrgs()
+	want := strings.TrimSpace(c.Query("org"))
+	switch {
+	case want == "":
+		return orgs[:1], nil
+	case strings.EqualFold(want, "all"):
+		return orgs, nil
+	}
+	for _, o := range orgs {
+		if strings.EqualFold(o, want) {
+			return []string{o}, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown org %q (configured: %s, or all)", want, strings.Join(orgs, ", "))
+}
+
+// buildkitePartialKey is the request value key for BuildKite data this request had to go without.
+const buildkitePartialKey = "buildkite_partial"
+
+type buildkitePartialSet struct {
+	mu   sync.Mutex
+	msgs []string
+}
+
+// recordBuildkitePartial notes a page, pipeline or org whose builds are missing after retries, so the handler
+// reports partial data instead of silently under-counting.
+func recordBuildkitePartial(ctx context.Context, format string, args ...interface{}) {
+	set := requestValue(ctx, buildkitePartialKey, func() *buildkitePartialSet { return &buildkitePartialSet{} })
+	set.mu.Lock()
+	defer set.mu.Unlock()
+	set.msgs = append(set.msgs, fmt.Sprintf(format, args...))
+}
+
+// buildkitePartial returns what recordBuildkitePartial noted for this request.
+func buildkitePartial(ctx context.Context) []string {
+	set, ok := lookupRequestValue[buildkitePartialSet](ctx, buildkitePartialKey)
+	if !ok {
+		return nil
+	}
+	set.mu.Lock()
+	defer set.mu.Unlock()
+	return append([]string(nil), set.msgs...)
+}
+
+// addBuildkitePartial marks meta partial and lists the missing pages/pipelines/orgs when any fetch gave up.
+func addBuildkitePartial(c *gin.Context, meta gin.H) {
+	if missing := buildkitePartial(c.Request.Context()); len(missing) > 0 {
+		meta["partial"] = true
+		meta["partial_errors"] = missing
+	}
+}
+
+// buildkitePipelineStatus is one pipeline's fetch outcome. Handlers report them in meta.pipelines so a pipeline
+// that failed shows up there (and as meta.partial) instead of silently dropping out of the numbers.
+type buildkitePipelineStatus struct {
+	Org        string `json:"org"`
+	Pipeline   string `json:"pipeline"`
+	OK         bool   `json:"ok"`
+	BuildCount int    `json:"build_count"`
+	Truncated  bool   `json:"truncated"` // more pages existed past buildkiteMaxPages; the oldest builds are missing
+	Error      string `json:"error,omitempty"`
+}
+
+// newPipelineStatus records one pipeline fetch, noting a failure as partial data for the request.
+func newPipelineStatus(ctx context.Context, org, pipeline string, builds []BuildkiteBuild, truncated bool, err error) buildkitePipelineStatus {
+	if err != nil {
+		logf(ctx, "BuildKite", "Warning: Failed to fetch from %s: %v", pipeline, err)
+		recordBuildkitePartial(ctx, "%s/%s: %v", org, pipeline, err)
+		return buildkitePipelineStatus{Org: org, Pipeline: pipeline, Error: err.Error()}
+	}
+	return buildkitePipelineStatus{Org: org, Pipeline: pipeline, OK: true, BuildCount: len(builds), Truncated: truncated}
+}
+
+// addBuildkitePipelines sets meta.pipelines to the per-pipeline outcomes, meta.partial when any failed, and
+// meta.truncated when any hit buildkiteMaxPages.
+func addBuildkitePipelines(meta gin.H, statuses []buildkitePipelineStatus) {
+	if statuses == nil {
+		statuses = []buildkitePipelineStatus{}
+	}
+	meta["pipelines"] = statuses
+	meta["truncated"] = false
+	for _, s := range statuses {
+		if !s.OK {
+			meta["partial"] = true
+		}
+		if s.Truncated {
+			meta["truncated"] = true
+			meta["max_pages"] = buildkiteMaxPages
+		}
+	}
+}
+
+// fetchBuildsAcrossOrgs runs fetch for each org, tags every build and pipeline status with its org, and merges
+// the results. An org that fails is logged and skipped; it's only an error when every org fails.
+func fetchBuildsAcrossOrgs(ctx context.Context, orgs []string, fetch func(org string) ([]BuildkiteBuild, []buildkitePipelineStatus, error)) ([]BuildkiteBuild, []buildkitePipelineStatus, error) {
+	var all []BuildkiteBuild
+	var statuses []buildkitePipelineStatus
+	var lastErr error
+	for _, org := range orgs {
+		builds, orgStatuses, err := fetch(org)
+		for i := range orgStatuses {
+			orgStatuses[i].Org = org
+		}
+		statuses = append(statuses, orgStatuses...)
+		if err != nil {
+			logf(ctx, "BuildKite", "Warning: Failed to fetch org %s: %v", org, err)
+			recordBuildkitePartial(ctx, "org %s: %v", org, err)
+			lastErr = err
+			continue
+		}
+		for i := range builds {
+			builds[i].Org = org
+		}
+		all = append(all, builds...)
+	}
+	if lastErr != nil && len(all) == 0 {
+		return nil, statuses, lastErr
+	}
+	return dedupeBuilds(all), statuses, nil
+}
+
+// fetchBuildsAcrossOrgsBeforeNow is fetchBuildsAcrossOrgs for handlers that fetch uncached, dropping builds
+// created after a ?now= override as getCachedBuilds does.
+func fetchBuildsAcrossOrgsBeforeNow(ctx context.Context, orgs []string, fetch func(org string) ([]BuildkiteBuild, []buildkitePipelineStatus, error)) ([]BuildkiteBuild, []buildkitePipelineStatus, error) {
+	builds, statuses, err := fetchBuildsAcrossOrgs(ctx, orgs, fetch)
+	return buildsBeforeNow(ctx, builds), statuses, err
+}
+
+// buildkiteDeploymentsByOrg counts deployment-pipeline builds on production branches per org for the by-org breakdown.
+func buildkiteDeploymentsByOrg(builds []BuildkiteBuild, branches *branchFilter) map[string]int {
+	byOrg := make(map[string]int)
+	for _, b := range builds {
+		if isDeploymentPipeline(b) && branches.matches(b.Branch) {
+			byOrg[b.Org]++
+		}
+	}
+	return byOrg
 }
 
 func buildkiteConfigMissing() []string {
@@ -55,103 +239,86 @@ type BuildkiteBuild struct {
 	Branch  string `json:"branch"`
 	Commit  string `json:"commit"`
 	Message string `json:"message"`
+	WebURL  string `json:"web_url"`
+	Org     string `json:"org,omitempty"` // set by fetchBuildsAcrossOrgs, not returned by the API
 }
 
-// fetchBuilds fetches builds from BuildKite API with pagination
-// For deployment pipeline, fetch from specific pipeline endpoint instead of org-wide
-func fetchBuilds(c *gin.Context, token, org string, createdFrom time.Time) ([]BuildkiteBuild, error) {
-	pipelines := []string{
-		"core-stack-deployment-pipeline",
-		"core-stack-deployment-pipeline-legacy",
+// buildkiteBuildURL returns the BuildKite web page for a build, preferring the web_url the API returned.
+func buildkiteBuildURL(org string, build BuildkiteBuild) string {
+	if build.WebURL != "" {
+		return build.WebURL
+	}
+	if build.Org != "" {
+		org = build.Org
 	}
+	return fmt.Sprintf("https://buildkite.com/%s/%s/builds/%d", org, build.Pipeline.Slug, build.Number)
+}
 
+// buildkiteFailureEntry is the per-build shape returned when include_failures is on.
+func buildkiteFailureEntry(org string, build BuildkiteBuild) gin.H {
+	return gin.H{
+		"number":      build.Number,
+		"org":         build.Org,
+		"pipeline":    build.Pipeline.Slug,
+		"branch":      build.Branch,
+		"message":     build.Message,
+		"finished_at": build.FinishedAt,
+		"web_url":     buildkiteBuildURL(org, build),
+	}
+}
+
+// fetchBuilds fetches builds from BuildKite API with pagination
+// For deployment pipeline, fetch from specific pipeline endpoint instead of org-wide
+func fetchBuilds(ctx context.Context, token, org string, createdFrom time.Time) ([]BuildkiteBuild, []buildkitePipelineStatus, error) {
 	var allBuilds []BuildkiteBuild
+	var statuses []buildkitePipelineStatus
 
-	// Fetch from both deployment pipelines
-	for _, pipeline := range pipelines {
-		pipelineBuilds, err := fetchBuildsFromPipelineSequential(c, token, org, pipeline, createdFrom)
-		if err != nil {
-			log.Printf("[BuildKite] Warning: Failed to fetch from %s: %v", pipeline, err)
-			continue
-		}
+	// Fetch from each deployment pipeline
+	for _, pipeline := range buildkiteDeploymentPipelines {
+		pipelineBuilds, truncated, err := fetchBuildsFromPipelineSequential(ctx, token, org, pipeline, createdFrom)
+		statuses = append(statuses, newPipelineStatus(ctx, org, pipeline, pipelineBuilds, truncated, err))
 		allBuilds = append(allBuilds, pipelineBuilds...)
 	}
 
-	log.Printf("[BuildKite] Total builds fetched from all pipelines: %d", len(allBuilds))
-	return allBuilds, nil
+	logf(ctx, "BuildKite", "Total builds fetched from all pipelines: %d", len(allBuilds))
+	return allBuilds, statuses, nil
 }
 
-// fetchBuildsFromPipelineSequential fetches builds from a single pipeline (sequential pagination)
-func fetchBuildsFromPipelineSequential(c *gin.Context, token, org, pipeline string, createdFrom time.Time) ([]BuildkiteBuild, error) {
-	var builds []BuildkiteBuild
-
-	for page := 1; page <= buildkiteMaxPages; page++ {
-		query := url.Values{}
-		query.Set("created_from", createdFrom.Format(time.RFC3339))
-		query.Set("per_page", fmt.Sprintf("%d", buildkitePerPage))
-		query.Set("page", fmt.Sprintf("%d", page))
-
-		// Query specific pipeline instead of all org builds
-		reqURL := fmt.Sprintf("%s/organizations/%s/pipelines/%s/builds?%s", buildkiteBaseURL, org, pipeline, query.Encode())
-		req, err := http.NewRequestWithContext(c.Request.Context(), http.MethodGet, reqURL, nil)
-		if err != nil {
-			return nil, err
-		}
-
-		req.Header.Set("Authorization", "Bearer "+token)
-		req.Header.Set("Accept", "application/json")
-
-		resp, err := http.DefaultClient.Do(req)
-		if err != nil {
-			return nil, err
-		}
-		body, _ := io.ReadAll(resp.Body)
-		resp.Body.Close()
-
-		if resp.StatusCode != http.StatusOK {
-			return nil, fmt.Errorf("BuildKite API returned %d: %s", resp.StatusCode, string(body))
+// fetchBuildsFromPipelineSequential fetches builds from a single pipeline (sequential pagination). truncated
+// reports that pages remained after buildkiteMaxPages.
+func fetchBuildsFromPipelineSequential(ctx context.Context, token, org, pipeline string, createdFrom time.Time) (builds []BuildkiteBuild, truncated bool, err error) {
+	more := true
+	for page := 1; more && page <= buildkiteMaxPages; page++ {
+		var p buildkitePage
+		if p, err = fetchBuildkitePage(ctx, token, buildkiteBuildsPageURL(org, pipeline, createdFrom, page)); err != nil {
+			return nil, false, err
 		}
-
-		var builds []BuildkiteBuild
-		var pageBuilds []BuildkiteBuild
-		if err := json.Unmarshal(body, &pageBuilds); err != nil {
-			return nil, err
-		}
-
-		if len(pageBuilds) == 0 {
-			break
-		}
-
-		builds = append(builds, pageBuilds...)
-
-		// If we got fewer than per_page, we've reached the last page
-		if len(pageBuilds) < buildkitePerPage {
-			break
-		}
-
-		log.Printf("[BuildKite] Fetched %s page %d (%d builds, %d total)", pipeline, page, len(pageBuilds), len(builds))
+		builds = append(builds, p.Builds...)
+		more = p.More
+		logf(ctx, "BuildKite", "Fetched %s page %d (%d builds, %d total)", pipeline, page, len(p.Builds), len(builds))
+	}
+	if more {
+		logf(ctx, "BuildKite", "%s: stopped after %d pages; older builds not fetched", pipeline, buildkiteMaxPages)
 	}
 
-	log.Printf("[BuildKite] Total builds fetched from %s: %d", pipeline, len(builds))
-	return builds, nil
+	logf(ctx, "BuildKite", "Total builds fetched from %s: %d", pipeline, len(builds))
+	return builds, more, nil
 }
 
-// isDeploymentPipeline checks if a build is from a deployment pipeline
-// Configured to track: Core Stack Deployment Pipeline and Legacy
+// isDeploymentPipeline checks if a build is from one of buildkiteDeploymentPipelines
 func isDeploymentPipeline(build BuildkiteBuild) bool {
 	slug := strings.ToLower(build.Pipeline.Slug)
-
-	// Track both deployment pipelines
-	if slug == "core-stack-deployment-pipeline" || slug == "core-stack-deployment-pipeline-legacy" {
-		return true
+	for _, p := range buildkiteDeploymentPipelines {
+		if slug == p {
+			return true
+		}
 	}
-
 	return false
 }
 
 // kpiBuildkiteDeploymentTime returns average deployment time per week
 func kpiBuildkiteDeploymentTime(c *gin.Context) {
-	token, org, ok := buildkiteConfig()
+	token, _, ok := buildkiteConfig()
 	if !ok {
 		missing := buildkiteConfigMissing()
 		c.JSON(http.StatusServiceUnavailable, gin.H{
@@ -162,75 +329,69 @@ func kpiBuildkiteDeploymentTime(c *gin.Context) {
 		return
 	}
 
-	// Fetch builds from last 3 months
-	threeMonthsAgo := time.Now().AddDate(0, -3, 0)
-	builds, err := fetchBuilds(c, token, org, threeMonthsAgo)
+	orgs, err := selectBuildkiteOrgs(c)
 	if err != nil {
-		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to fetch builds: " + err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-
-	// Filter deployment builds and calculate durations by week
-	weekDurations := make(map[string][]float64) // week -> list of durations in minutes
-	deploymentCount := 0
-
-	for _, build := range builds {
-		// Only count passed deployments for average time
-		if build.State != "passed" {
-			continue
-		}
-
-		if !isDeploymentPipeline(build) {
-			continue
-		}
-
-		startedAt, okStart := parseTime(build.StartedAt)
-		finishedAt, okFinish := parseTime(build.FinishedAt)
-		if !okStart || !okFinish || finishedAt.Before(startedAt) {
-			continue
-		}
-
-		durationMinutes := finishedAt.Sub(startedAt).Minutes()
-		week := weekKey(finishedAt)
-		weekDurations[week] = append(weekDurations[week], durationMinutes)
-		deploymentCount++
+	opts, err := buildkiteAggOptionsFromQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
-	log.Printf("[BuildKite] Deployment time: %d deployment builds processed", deploymentCount)
+	// Fetch builds from last 3 months
+	now := requestNow(c)
+	threeMonthsAgo := now.AddDate(0, -3, 0)
+	builds, pipelineStatuses, err := fetchBuildsAcrossOrgsBeforeNow(c.Request.Context(), orgs, func(org string) ([]BuildkiteBuild, []buildkitePipelineStatus, error) {
+		return fetchBuilds(c.Request.Context(), token, org, threeMonthsAgo)
+	})
+	if err != nil {
+		c.JSON(upstreamFailureStatus(err), upstreamErrorBody("Failed to fetch builds: ", err))
+		return
+	}
 
-	// Calculate average per week
-	var weeks []string
-	for w := range weekDurations {
-		weeks = append(weeks, w)
+	// Only passed deployments count toward average time
+	if opts.ByWeekday {
+		opts.Bucket = weekdayKey
+	}
+	m := aggregateBuildkite(builds, opts)
+	logf(c.Request.Context(), "BuildKite", "Deployment time: %d deployment builds processed", m.TimedCount)
+	fillGaps := parseFillGaps(c) && !opts.ByWeekday
+	if fillGaps {
+		m.fillGaps(bucketGranularity(granularityWeek).keys(threeMonthsAgo, now))
 	}
-	sort.Strings(weeks)
 
-	avgDurations := make([]float64, len(weeks))
-	for i, w := range weeks {
-		durations := weekDurations[w]
-		var sum float64
-		for _, d := range durations {
-			sum += d
-		}
-		avgDurations[i] = sum / float64(len(durations))
-	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"weeks":             weeks,
-		"avg_duration_mins": avgDurations,
-		"meta": gin.H{
-			"total_builds":       len(builds),
-			"deployment_builds":  deploymentCount,
-			"date_range":         fmt.Sprintf("last 3 months (from %s)", threeMonthsAgo.Format("2006-01-02")),
-			"note":               "Average deployment time (start to finish) for passed builds only",
-			"org":                org,
-		},
-	})
+	meta := gin.H{
+		"source":             sourceLive,
+		"total_builds":       len(builds),
+		"deployment_builds":  m.TimedCount,
+		"date_range":         fmt.Sprintf("last 3 months (from %s)", threeMonthsAgo.Format("2006-01-02")),
+		"note":               "Average deployment time (start to finish) for passed builds only",
+		"fill_gaps":          fillGaps,
+		"org":                strings.Join(orgs, ","),
+		"by_org":             buildkiteDeploymentsByOrg(builds, opts.Branches),
+		"bucket_by":          opts.BucketBy,
+		"duration_basis":     opts.DurationBasis,
+		"branch_patterns":    opts.Branches.patterns(),
+		"bucket_timezone":    bucketLocation.String(),
+	}
+	addBuildkitePipelines(meta, pipelineStatuses)
+	addBuildkitePartial(c, meta)
+	if opts.ByWeekday {
+		resp := m.weekdayJSON()
+		resp["meta"] = meta
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+	resp := m.deploymentTimeJSON("weeks")
+	resp["meta"] = meta
+	c.JSON(http.StatusOK, resp)
 }
 
 // kpiBuildkiteDeploymentFailureRate returns deployment failure rate per week
 func kpiBuildkiteDeploymentFailureRate(c *gin.Context) {
-	token, org, ok := buildkiteConfig()
+	token, _, ok := buildkiteConfig()
 	if !ok {
 		missing := buildkiteConfigMissing()
 		c.JSON(http.StatusServiceUnavailable, gin.H{
@@ -241,90 +402,71 @@ func kpiBuildkiteDeploymentFailureRate(c *gin.Context) {
 		return
 	}
 
-	// Fetch builds from last 3 months
-	threeMonthsAgo := time.Now().AddDate(0, -3, 0)
-	builds, err := fetchBuilds(c, token, org, threeMonthsAgo)
+	orgs, err := selectBuildkiteOrgs(c)
 	if err != nil {
-		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to fetch builds: " + err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	opts, err := buildkiteAggOptionsFromQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Count passed and failed deployments by week
-	weekPassed := make(map[string]int)
-	weekFailed := make(map[string]int)
-	deploymentCount := 0
-
-	for _, build := range builds {
-		if !isDeploymentPipeline(build) {
-			continue
-		}
-
-		// Only count finished builds (passed or failed)
-		if build.State != "passed" && build.State != "failed" {
-			continue
-		}
-
-		finishedAt, ok := parseTime(build.FinishedAt)
-		if !ok {
-			continue
-		}
-
-		week := weekKey(finishedAt)
-		if build.State == "passed" {
-			weekPassed[week]++
-		} else if build.State == "failed" {
-			weekFailed[week]++
-		}
-		deploymentCount++
+	// Fetch builds from last 3 months
+	now := requestNow(c)
+	threeMonthsAgo := now.AddDate(0, -3, 0)
+	builds, pipelineStatuses, err := fetchBuildsAcrossOrgsBeforeNow(c.Request.Context(), orgs, func(org string) ([]BuildkiteBuild, []buildkitePipelineStatus, error) {
+		return fetchBuilds(c.Request.Context(), token, org, threeMonthsAgo)
+	})
+	if err != nil {
+		c.JSON(upstreamFailureStatus(err), upstreamErrorBody("Failed to fetch builds: ", err))
+		return
 	}
 
-	log.Printf("[BuildKite] Failure rate: %d deployment builds processed", deploymentCount)
+	includeFailures := c.Query("include_failures") == "1" || c.Query("include_failures") == "true"
 
-	// Calculate failure rate per week
-	weeksMap := make(map[string]struct{})
-	for w := range weekPassed {
-		weeksMap[w] = struct{}{}
+	// Count passed and failed deployments by week (or by weekday with ?bucket=dow)
+	if opts.ByWeekday {
+		opts.Bucket = weekdayKey
 	}
-	for w := range weekFailed {
-		weeksMap[w] = struct{}{}
+	m := aggregateBuildkite(builds, opts)
+	deploymentCount := m.PassedCount + m.FailedCount
+	logf(c.Request.Context(), "BuildKite", "Failure rate: %d deployment builds processed", deploymentCount)
+	fillGaps := parseFillGaps(c) && !opts.ByWeekday
+	if fillGaps {
+		m.fillGaps(bucketGranularity(granularityWeek).keys(threeMonthsAgo, now))
 	}
 
-	var weeks []string
-	for w := range weeksMap {
-		weeks = append(weeks, w)
+	resp := m.failureRateJSON("weeks") // failure_rate is a percentage
+	if opts.ByWeekday {
+		resp = m.weekdayJSON()
 	}
-	sort.Strings(weeks)
-
-	failureRates := make([]float64, len(weeks))
-	passedCounts := make([]int, len(weeks))
-	failedCounts := make([]int, len(weeks))
-
-	for i, w := range weeks {
-		passed := weekPassed[w]
-		failed := weekFailed[w]
-		total := passed + failed
-
-		passedCounts[i] = passed
-		failedCounts[i] = failed
-
-		if total > 0 {
-			failureRates[i] = float64(failed) / float64(total) * 100
-		} else {
-			failureRates[i] = 0
+	resp["meta"] = gin.H{
+		"source":             sourceLive,
+		"total_builds":       len(builds),
+		"deployment_builds":  deploymentCount,
+		"date_range":         fmt.Sprintf("last 3 months (from %s)", threeMonthsAgo.Format("2006-01-02")),
+		"note":               "Failure rate = failed / (passed + failed) * 100",
+		"fill_gaps":          fillGaps,
+		"org":                strings.Join(orgs, ","),
+		"by_org":             buildkiteDeploymentsByOrg(builds, opts.Branches),
+		"bucket_by":          opts.BucketBy,
+		"duration_basis":     opts.DurationBasis,
+		"branch_patterns":    opts.Branches.patterns(),
+		"bucket_timezone":    bucketLocation.String(),
+	}
+	addBuildkitePipelines(resp["meta"].(gin.H), pipelineStatuses)
+	resp["meta"].(gin.H)["target_status"] = kpiTargetMeta("deployment_failure_rate", m.FailureRates)
+	m.addExclusionMeta(resp["meta"].(gin.H), opts)
+	m.addFailureAccountingMeta(resp["meta"].(gin.H), opts)
+	addBuildkitePartial(c, resp["meta"].(gin.H))
+	if includeFailures {
+		failures := []gin.H{}
+		for _, build := range m.FailedBuilds {
+			failures = append(failures, buildkiteFailureEntry(build.Org, build))
 		}
+		resp["failures"] = failures
 	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"weeks":         weeks,
-		"failure_rate":  failureRates, // percentage
-		"passed":        passedCounts,
-		"failed":        failedCounts,
-		"meta": gin.H{
-			"total_builds":       len(builds),
-			"deployment_builds":  deploymentCount,
-			"date_range":         fmt.Sprintf("last 3 months (from %s)", threeMonthsAgo.Format("2006-01-02")),
-			"note":               "Failure rate = failed / (passed + failed) * 100",
-			"org":                org,
-		},
-	})
+	c.JSON(http.StatusOK, resp)
 }
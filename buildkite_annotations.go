@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BuildkiteAnnotation is one build annotation; failing steps usually post style "error" with the reason.
+type BuildkiteAnnotation struct {
+	Context string `json:"context"`
+	Style   string `json:"style"` // success, info, warning, error
+	HTML    string `json:"body_html"`
+}
+
+// fetchBuildAnnotations returns a build's annotations in BuildKite's order, following Link rel="next" pages.
+// Annotations can be added while a build runs, so they are not cached.
+func fetchBuildAnnotations(ctx context.Context, token, org, pipeline string, number int) ([]BuildkiteAnnotation, error) {
+	var annotations []BuildkiteAnnotation
+	nextURL := fmt.Sprintf("%s/organizations/%s/pipelines/%s/builds/%d/annotations", buildkiteBaseURL, org, pipeline, number)
+	for page := 1; nextURL != "" && page <= buildkiteMaxPages; page++ {
+		body, header, err := buildkiteGet(ctx, token, nextURL)
+		if err != nil {
+			return nil, err
+		}
+		var pageAnnotations []BuildkiteAnnotation
+		if err := json.Unmarshal(body, &pageAnnotations); err != nil {
+			return nil, err
+		}
+		annotations = append(annotations, pageAnnotations...)
+		nextURL = parseLinkHeader(header.Get("Link"))["next"]
+	}
+	if nextURL != "" {
+		logf(ctx, "BuildKite Annotations", "%s/%s#%d: stopped after %d pages; remaining annotations not fetched", org, pipeline, number, buildkiteMaxPages)
+	}
+	return annotations, nil
+}
+
+// GET /api/buildkite/builds/:number/annotations?pipeline=&org= – a build's annotations (context, style, html),
+// to show why a deployment failed without opening BuildKite. 404 when BuildKite has no such build.
+func buildkiteBuildAnnotations(c *gin.Context) {
+	token, _, ok := buildkiteConfig()
+	if !ok {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error":   "BuildKite not configured",
+			"missing": buildkiteConfigMissing(),
+			"hint":    "Set BUILDKITE_TOKEN and BUILDKITE_ORG in .env",
+		})
+		return
+	}
+	orgs, err := selectBuildkiteOrgs(c)
+	if err != nil || len(orgs) != 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "org must name a single configured org"})
+		return
+	}
+	org := orgs[0]
+	number, err := strconv.Atoi(c.Param("number"))
+	if err != nil || number < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "build number must be a positive integer"})
+		return
+	}
+	pipeline := strings.ToLower(strings.TrimSpace(c.DefaultQuery("pipeline", buildkiteDeploymentPipelines[0])))
+	if !buildkiteSlugPattern.MatchString(pipeline) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid pipeline slug %q", pipeline)})
+		return
+	}
+
+	annotations, err := fetchBuildAnnotations(c.Request.Context(), token, org, pipeline, number)
+	var ue *upstreamError
+	if errors.As(err, &ue) && ue.Status == http.StatusNotFound {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":        fmt.Sprintf("build %d not found in %s/%s", number, org, pipeline),
+			"org":          org,
+			"pipeline":     pipeline,
+			"build_number": number,
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(upstreamFailureStatus(err), upstreamErrorBody("Failed to fetch build annotations: ", err))
+		return
+	}
+
+	type annotation struct {
+		Context string `json:"context"`
+		Style   string `json:"style"`
+		HTML    string `json:"html"`
+	}
+	out := make([]annotation, 0, len(annotations))
+	errorCount := 0
+	for _, a := range annotations {
+		if a.Style == "error" {
+			errorCount++
+		}
+		out = append(out, annotation{Context: a.Context, Style: a.Style, HTML: a.HTML})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"annotations": out,
+		"meta": gin.H{
+			"source":       sourceLive,
+			"org":          org,
+			"pipeline":     pipeline,
+			"build_number": number,
+			"build_url":    fmt.Sprintf("https://buildkite.com/%s/%s/builds/%d", org, pipeline, number),
+			"count":        len(out),
+			"error_count":  errorCount,
+			"note":         "html is BuildKite's rendered annotation body; sanitize before inserting it into a page.",
+		},
+	})
+}
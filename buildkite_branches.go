@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// branchFilter decides which branches count as production deploys. Patterns are comma-separated globs
+// (path.Match syntax, e.g. "main,release/*") or regexes wrapped in slashes ("/^hotfix-\d+$/").
+// A leading "!" denies a pattern; deny wins over allow. With no allow patterns every branch is allowed.
+type branchFilter struct {
+	allow []branchPattern
+	deny  []branchPattern
+	raw   []string
+}
+
+type branchPattern struct {
+	glob string
+	re   *regexp.Regexp
+}
+
+func (p branchPattern) match(branch string) bool {
+	if p.re != nil {
+		return p.re.MatchString(branch)
+	}
+	ok, _ := path.Match(p.glob, branch)
+	return ok
+}
+
+// parseBranchFilter parses a pattern list; an empty list returns nil (all branches count).
+func parseBranchFilter(raw string) (*branchFilter, error) {
+	f := &branchFilter{}
+	for _, item := range strings.Split(raw, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		pat, deny := item, false
+		if strings.HasPrefix(pat, "!") {
+			pat, deny = strings.TrimSpace(pat[1:]), true
+		}
+		var bp branchPattern
+		if len(pat) > 2 && strings.HasPrefix(pat, "/") && strings.HasSuffix(pat, "/") {
+			re, err := regexp.Compile(pat[1 : len(pat)-1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid branch regex %q: %v", item, err)
+			}
+			bp.re = re
+		} else {
+			if _, err := path.Match(pat, ""); err != nil || pat == "" {
+				return nil, fmt.Errorf("invalid branch glob %q", item)
+			}
+			bp.glob = pat
+		}
+		if deny {
+			f.deny = append(f.deny, bp)
+		} else {
+			f.allow = append(f.allow, bp)
+		}
+		f.raw = append(f.raw, item)
+	}
+	if len(f.raw) == 0 {
+		return nil, nil
+	}
+	return f, nil
+}
+
+// matches reports whether builds on branch count as production deploys. A nil filter allows everything.
+func (f *branchFilter) matches(branch string) bool {
+	if f == nil {
+		return true
+	}
+	for _, p := range f.deny {
+		if p.match(branch) {
+			return false
+		}
+	}
+	if len(f.allow) == 0 {
+		return true
+	}
+	for _, p := range f.allow {
+		if p.match(branch) {
+			return true
+		}
+	}
+	return false
+}
+
+// patterns returns the effective patterns for meta (empty when every branch counts).
+func (f *branchFilter) patterns() []string {
+	if f == nil {
+		return []string{}
+	}
+	return f.raw
+}
+
+// defaultBranchFilter comes from BUILDKITE_DEPLOY_BRANCHES; ?branches= overrides it per request.
+var defaultBranchFilter *branchFilter
+
+func loadBranchFilter() *branchFilter {
+	raw := strings.TrimSpace(os.Getenv("BUILDKITE_DEPLOY_BRANCHES"))
+	f, err := parseBranchFilter(raw)
+	if err != nil {
+		log.Printf("[Config] Ignoring BUILDKITE_DEPLOY_BRANCHES=%q: %v", raw, err)
+		return nil
+	}
+	return f
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BuildkiteJob is the subset of a build's job we use for step timing.
+type BuildkiteJob struct {
+	ID         string `json:"id"`
+	Type       string `json:"type"` // script, waiter, manual, trigger
+	Name       string `json:"name"`
+	StepKey    string `json:"step_key"`
+	State      string `json:"state"`
+	StartedAt  string `json:"started_at"`
+	FinishedAt string `json:"finished_at"`
+	WebURL     string `json:"web_url"`
+}
+
+const (
+	buildkiteJobsLimitDefault = 20
+	buildkiteJobsLimitMax     = 100
+)
+
+// Finished builds never change, so their jobs are cached per build; running builds are always refetched.
+// The cache is bounded because the key comes from request parameters.
+const buildkiteJobCacheMaxEntries = 1000
+
+var buildkiteJobCache = newBoundedTTLCache[string, []BuildkiteJob](24*time.Hour, 0, buildkiteJobCacheMaxEntries)
+
+// parseLinkHeader maps rel → URL from an RFC 8288 Link header (BuildKite uses rel="next" for pagination).
+func parseLinkHeader(h string) map[string]string {
+	links := make(map[string]string)
+	for _, part := range strings.Split(h, ",") {
+		segs := strings.Split(part, ";")
+		if len(segs) < 2 {
+			continue
+		}
+		target := strings.Trim(strings.TrimSpace(segs[0]), "<>")
+		for _, attr := range segs[1:] {
+			attr = strings.TrimSpace(attr)
+			if strings.HasPrefix(attr, "rel=") {
+				links[strings.Trim(strings.TrimPrefix(attr, "rel="), `"`)] = target
+			}
+		}
+	}
+	return links
+}
+
+// fetchBuildJobs returns the jobs of one build, following Link rel="next" pages when BuildKite paginates them.
+// Each request goes through buildkiteThrottle; pages are capped at buildkiteMaxPages.
+func fetchBuildJobs(ctx context.Context, token, org, pipeline string, number int) ([]BuildkiteJob, bool, error) {
+	cacheKey := fmt.Sprintf("%s/%s#%d", org, pipeline, number)
+	if cached, ok := buildkiteJobCache.Get(cacheKey); ok {
+		return cached, true, nil
+	}
+
+	var jobs []BuildkiteJob
+	finished := false
+	nextURL := fmt.Sprintf("%s/organizations/%s/pipelines/%s/builds/%d", buildkiteBaseURL, org, pipeline, number)
+	for page := 1; nextURL != "" && page <= buildkiteMaxPages; page++ {
+		body, header, err := buildkiteGet(ctx, token, nextURL)
+		if err != nil {
+			return nil, false, err
+		}
+		var build struct {
+			FinishedAt string         `json:"finished_at"`
+			Jobs       []BuildkiteJob `json:"jobs"`
+		}
+		if err := json.Unmarshal(body, &build); err != nil {
+			return nil, false, err
+		}
+		finished = build.FinishedAt != ""
+		jobs = append(jobs, build.Jobs...)
+		nextURL = parseLinkHeader(header.Get("Link"))["next"]
+	}
+	if nextURL != "" {
+		logf(ctx, "BuildKite Jobs", "%s: stopped after %d pages; remaining jobs not fetched", cacheKey, buildkiteMaxPages)
+	}
+
+	if finished {
+		buildkiteJobCache.Set(cacheKey, jobs)
+	}
+	return jobs, false, nil
+}
+
+// GET /api/buildkite/builds/:number/jobs?pipeline=&org=&limit=20 – per-job timing for one build.
+// Returns the top `limit` jobs by duration plus an "others" aggregate for the rest.
+func buildkiteBuildJobs(c *gin.Context) {
+	token, _, ok := buildkiteConfig()
+	if !ok {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error":   "BuildKite not configured",
+			"missing": buildkiteConfigMissing(),
+			"hint":    "Set BUILDKITE_TOKEN and BUILDKITE_ORG in .env",
+		})
+		return
+	}
+	orgs, err := selectBuildkiteOrgs(c)
+	if err != nil || len(orgs) != 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "org must name a single configured org"})
+		return
+	}
+	org := orgs[0]
+	number, err := strconv.Atoi(c.Param("number"))
+	if err != nil || number < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "build number must be a positive integer"})
+		return
+	}
+	pipeline := strings.ToLower(strings.TrimSpace(c.DefaultQuery("pipeline", buildkiteDeploymentPipelines[0])))
+	if !buildkiteSlugPattern.MatchString(pipeline) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid pipeline slug %q", pipeline)})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(buildkiteJobsLimitDefault)))
+	if err != nil || limit < 1 || limit > buildkiteJobsLimitMax {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be an integer between 1 and %d", buildkiteJobsLimitMax)})
+		return
+	}
+
+	jobs, cached, err := fetchBuildJobs(c.Request.Context(), token, org, pipeline, number)
+	if err != nil {
+		c.JSON(upstreamFailureStatus(err), upstreamErrorBody("Failed to fetch build jobs: ", err))
+		return
+	}
+
+	type jobTiming struct {
+		Name         string  `json:"name"`
+		StepKey      string  `json:"step_key,omitempty"`
+		State        string  `json:"state"`
+		DurationMins float64 `json:"duration_mins"`
+		WebURL       string  `json:"web_url,omitempty"`
+	}
+	var timed []jobTiming
+	untimed := 0
+	for _, j := range jobs {
+		if j.Type != "script" {
+			continue // waiters, block steps and triggers have no runtime of their own
+		}
+		started, okStart := parseTime(j.StartedAt)
+		finished, okFinish := parseTime(j.FinishedAt)
+		if !okStart || !okFinish || !finished.After(started) {
+			untimed++
+			continue
+		}
+		timed = append(timed, jobTiming{
+			Name:         j.Name,
+			StepKey:      j.StepKey,
+			State:        j.State,
+			DurationMins: durationMins(finished.Sub(started)),
+			WebURL:       j.WebURL,
+		})
+	}
+	sort.SliceStable(timed, func(i, j int) bool { return timed[i].DurationMins > timed[j].DurationMins })
+
+	others := gin.H{"count": 0, "total_duration_mins": 0.0}
+	if len(timed) > limit {
+		var total float64
+		for _, j := range timed[limit:] {
+			total += j.DurationMins
+		}
+		others = gin.H{"count": len(timed) - limit, "total_duration_mins": math.Round(total*100) / 100}
+		timed = timed[:limit]
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"jobs":   timed,
+		"others": others,
+		"meta": gin.H{
+			"source":       cacheSource(cached, false),
+			"org":          org,
+			"pipeline":     pipeline,
+			"build_number": number,
+			"build_url":    fmt.Sprintf("https://buildkite.com/%s/%s/builds/%d", org, pipeline, number),
+			"jobs_total":   len(jobs),
+			"untimed_jobs": untimed,
+			"limit":        limit,
+			"cached":       cached,
+		},
+	})
+}
+
+// durationMins converts a duration to minutes rounded to two decimals.
+func durationMins(d time.Duration) float64 {
+	return math.Round(d.Minutes()*100) / 100
+}
@@ -0,0 +1,780 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// buildkiteAggOptions controls which builds aggregateBuildkite counts and how it buckets them.
+type buildkiteAggOptions struct {
+	IsDeploy func(BuildkiteBuild) bool // which builds are deployments; nil means isDeploymentPipeline
+	Bucket   func(time.Time) string    // bucket key for a build's basis time; nil means weekKey
+	Include  func(at time.Time) bool   // optional window filter on the basis time
+	BucketBy string                    // basis time: bucketByFinished (default) or bucketByStarted
+	// DurationBasis is the timestamp deploy duration is measured from to finished_at: durationBasisStarted (default),
+	// durationBasisScheduled or durationBasisCreated (the latter two include queue wait)
+	DurationBasis string
+	Branches      *branchFilter // production branch patterns; nil counts every branch
+	ByWeekday     bool          // ?bucket=dow: handlers bucket by weekdayKey instead of their own period
+
+	ExcludeFirstOfDay bool // drop each day's first deploy (by start time) from the failure rate only
+	IncludeCanceled   bool // ?include_canceled=true: canceled deploys count as failures in the failure rate
+}
+
+const (
+	bucketByFinished = "finished"
+	bucketByStarted  = "started"
+
+	durationBasisStarted   = "started"
+	durationBasisScheduled = "scheduled"
+	durationBasisCreated   = "created"
+)
+
+// durationStart is the build's timestamp for opts.DurationBasis.
+func (opts buildkiteAggOptions) durationStart(build BuildkiteBuild) (time.Time, bool) {
+	switch opts.DurationBasis {
+	case durationBasisScheduled:
+		return parseTime(build.ScheduledAt)
+	case durationBasisCreated:
+		return parseTime(build.CreatedAt)
+	default:
+		return parseTime(build.StartedAt)
+	}
+}
+
+// buildkiteAggOptionsFromQuery reads the query options shared by the BuildKite metrics endpoints
+// (?bucket_by=started|finished, ?duration_basis=started|scheduled|created, ?branches=, ?exclude_first_of_day=1,
+// ?include_canceled=1, ?bucket=dow); handlers add Bucket and Include for their own window.
+func buildkiteAggOptionsFromQuery(c *gin.Context) (buildkiteAggOptions, error) {
+	opts := buildkiteAggOptions{BucketBy: bucketByFinished, DurationBasis: durationBasisStarted, Branches: defaultBranchFilter}
+	if raw, ok := c.GetQuery("branches"); ok {
+		f, err := parseBranchFilter(raw)
+		if err != nil {
+			return opts, err
+		}
+		opts.Branches = f
+	}
+	opts.ExcludeFirstOfDay = c.Query("exclude_first_of_day") == "1" || c.Query("exclude_first_of_day") == "true"
+	opts.IncludeCanceled = c.Query("include_canceled") == "1" || c.Query("include_canceled") == "true"
+	switch by := strings.ToLower(strings.TrimSpace(c.Query("bucket_by"))); by {
+	case "", bucketByFinished:
+	case bucketByStarted:
+		opts.BucketBy = bucketByStarted
+	default:
+		return opts, fmt.Errorf("bucket_by must be started or finished, got %q", by)
+	}
+	switch basis := strings.ToLower(strings.TrimSpace(c.Query("duration_basis"))); basis {
+	case "", durationBasisStarted:
+	case durationBasisScheduled, durationBasisCreated:
+		opts.DurationBasis = basis
+	default:
+		return opts, fmt.Errorf("duration_basis must be started, scheduled or created, got %q", basis)
+	}
+	switch bucket := strings.ToLower(strings.TrimSpace(c.Query("bucket"))); bucket {
+	case "":
+	case "dow":
+		opts.ByWeekday = true
+	default:
+		return opts, fmt.Errorf("bucket must be dow, got %q", bucket)
+	}
+	return opts, nil
+}
+
+// weekdays is the day-of-week bucket order for ?bucket=dow.
+var weekdays = []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday, time.Sunday}
+
+// weekdayKey buckets by day of week ("Monday") in the bucketing zone, pooling every week in the window.
+func weekdayKey(t time.Time) string {
+	return bucketTime(t).Weekday().String()
+}
+
+// buildkiteMetrics is the per-bucket deployment time, failure rate, and frequency computed from a build list.
+// Duration buckets only include buckets with a passed deploy; rate buckets include any deploy counted in the rate
+// (passed or failed, plus canceled with IncludeCanceled).
+type buildkiteMetrics struct {
+	DurationBuckets []string
+	AvgDurations    []float64
+	P50Durations    []float64 // nearest-rank percentiles of the same passed-deploy durations
+	P90Durations    []float64
+
+	RateBuckets  []string
+	FailureRates []float64
+	Passed       []int
+	Failed       []int
+
+	// Canceled deploys per bucket, reported whether or not the rate counts them. A bucket of only canceled
+	// deploys has no failure rate, so the canceled series keeps its own bucket list.
+	CanceledBuckets []string
+	Canceled        []int
+
+	FrequencyBuckets []string
+	DeployCounts     []int
+
+	Deployments   int // deployment builds with the basis timestamp, any state
+	PassedCount   int
+	FailedCount   int
+	CanceledCount int
+	TimedCount    int // passed deployments with a usable duration
+
+	// Completeness inputs: deployments in a terminal state, and how many of those had the basis timestamp
+	TerminalCount int
+	TerminalTimed int
+
+	FailedBuilds []BuildkiteBuild
+
+	ExcludedFirstOfDay int // passed/failed deploys left out of the failure rate by ExcludeFirstOfDay
+
+	// Consecutive passed deploys (by finish time): since the most recent failure, and the best run in the window
+	CurrentStreak int
+	LongestStreak int
+
+	// GapBuckets, set by fillGaps, is every bucket in the window. The JSON sections then report all of them,
+	// with null averages and rates and zero counts where there were no deploys.
+	GapBuckets []string
+}
+
+// fillGaps makes the JSON sections report every bucket in buckets (in bucket order), not only those with deploys.
+func (m *buildkiteMetrics) fillGaps(buckets []string) {
+	m.GapBuckets = buckets
+}
+
+// reportedBuckets is keys as aggregated, or with fillGaps the sorted union of GapBuckets and keys.
+func (m buildkiteMetrics) reportedBuckets(keys []string) []string {
+	if m.GapBuckets == nil {
+		return keys
+	}
+	all := make(map[string]struct{}, len(m.GapBuckets)+len(keys))
+	for _, k := range m.GapBuckets {
+		all[k] = struct{}{}
+	}
+	for _, k := range keys {
+		all[k] = struct{}{}
+	}
+	return sortedKeys(all)
+}
+
+// unionKeys is the sorted union of bucket lists.
+func unionKeys(lists ...[]string) []string {
+	all := make(map[string]struct{})
+	for _, keys := range lists {
+		for _, k := range keys {
+			all[k] = struct{}{}
+		}
+	}
+	return sortedKeys(all)
+}
+
+// alignFloats spreads vals (one per key) over buckets; buckets without a value are null.
+func alignFloats(keys []string, vals []float64, buckets []string) []*float64 {
+	byKey := make(map[string]float64, len(keys))
+	for i, k := range keys {
+		byKey[k] = vals[i]
+	}
+	out := make([]*float64, len(buckets))
+	for i, b := range buckets {
+		if v, ok := byKey[b]; ok {
+			out[i] = &v
+		}
+	}
+	return out
+}
+
+// alignInts spreads vals (one per key) over buckets; buckets without a value are 0.
+func alignInts(keys []string, vals []int, buckets []string) []int {
+	byKey := make(map[string]int, len(keys))
+	for i, k := range keys {
+		byKey[k] = vals[i]
+	}
+	out := make([]int, len(buckets))
+	for i, b := range buckets {
+		out[i] = byKey[b]
+	}
+	return out
+}
+
+// addBucketRanges adds week_ranges or month_ranges for a section keyed by bucketName.
+func addBucketRanges(out gin.H, bucketName string, buckets []string) {
+	switch bucketName {
+	case "weeks":
+		out["week_ranges"] = weekRangeLabels(buckets)
+	case "months":
+		out["month_ranges"] = monthRangeLabels(buckets)
+	}
+}
+
+// aggregateBuildkite buckets deployment builds by finish (or, with BucketBy started, start) time and computes average duration (passed only),
+// failure rate = failed / (passed + failed) * 100 (canceled added to both with IncludeCanceled), and deploy count per bucket.
+// Running and scheduled builds have no finish time and never reach the rate.
+func aggregateBuildkite(builds []BuildkiteBuild, opts buildkiteAggOptions) buildkiteMetrics {
+	isDeploy := opts.IsDeploy
+	if isDeploy == nil {
+		isDeploy = isDeploymentPipeline
+	}
+	bucket := opts.Bucket
+	if bucket == nil {
+		bucket = weekKey
+	}
+
+	var m buildkiteMetrics
+	durations := make(map[string][]float64)
+	passed := make(map[string]int)
+	failed := make(map[string]int)
+	canceled := make(map[string]int)
+	canceledInRate := make(map[string]int)
+	counts := make(map[string]int)
+	type outcome struct {
+		finishedAt time.Time
+		passed     bool
+	}
+	var outcomes []outcome
+
+	// First deploy of each day by start time, for ExcludeFirstOfDay (morning warm-up failures)
+	type dayFirst struct {
+		build     BuildkiteBuild
+		startedAt time.Time
+	}
+	firstOfDay := make(map[string]dayFirst)
+	if opts.ExcludeFirstOfDay {
+		for _, build := range builds {
+			if !isDeploy(build) || !opts.Branches.matches(build.Branch) {
+				continue
+			}
+			startedAt, ok := parseTime(build.StartedAt)
+			if !ok {
+				continue
+			}
+			day := dayKey(startedAt)
+			if first, seen := firstOfDay[day]; !seen || startedAt.Before(first.startedAt) {
+				firstOfDay[day] = dayFirst{build, startedAt}
+			}
+		}
+	}
+	isFirstOfDay := func(build BuildkiteBuild, startedAt time.Time, okStart bool) bool {
+		if !opts.ExcludeFirstOfDay || !okStart {
+			return false
+		}
+		first := firstOfDay[dayKey(startedAt)].build
+		return first.Number == build.Number && first.Org == build.Org && first.Pipeline.Slug == build.Pipeline.Slug
+	}
+
+	for _, build := range builds {
+		if !isDeploy(build) || !opts.Branches.matches(build.Branch) {
+			continue
+		}
+		terminal := build.State == "passed" || build.State == "failed" || build.State == "canceled"
+
+		finishedAt, okFinish := parseTime(build.FinishedAt)
+		if !okFinish {
+			if terminal {
+				m.TerminalCount++
+			}
+			continue
+		}
+		startedAt, okStart := parseTime(build.StartedAt)
+		at := finishedAt
+		if opts.BucketBy == bucketByStarted {
+			if !okStart {
+				if terminal {
+					m.TerminalCount++
+				}
+				continue
+			}
+			at = startedAt
+		}
+		if opts.Include != nil && !opts.Include(at) {
+			continue
+		}
+		if terminal {
+			m.TerminalCount++
+			m.TerminalTimed++
+		}
+
+		key := bucket(at)
+		m.Deployments++
+		counts[key]++
+		inRate := build.State == "passed" || build.State == "failed" || (opts.IncludeCanceled && build.State == "canceled")
+		excludeFromRate := inRate && isFirstOfDay(build, startedAt, okStart)
+		if excludeFromRate {
+			m.ExcludedFirstOfDay++
+		}
+
+		switch build.State {
+		case "passed":
+			// A basis time after finish (clock skew, missing data) has no meaningful duration; skip it. A no-op
+			// deploy that finishes the instant it starts is a real 0-minute duration and counts
+			if durationFrom, ok := opts.durationStart(build); ok && !finishedAt.Before(durationFrom) {
+				durations[key] = append(durations[key], finishedAt.Sub(durationFrom).Minutes())
+				m.TimedCount++
+			}
+			if !excludeFromRate {
+				passed[key]++
+			}
+			m.PassedCount++
+			outcomes = append(outcomes, outcome{finishedAt, true})
+		case "failed":
+			if !excludeFromRate {
+				failed[key]++
+			}
+			m.FailedCount++
+			m.FailedBuilds = append(m.FailedBuilds, build)
+			outcomes = append(outcomes, outcome{finishedAt, false})
+		case "canceled":
+			canceled[key]++
+			if opts.IncludeCanceled && !excludeFromRate {
+				canceledInRate[key]++
+			}
+			m.CanceledCount++
+		}
+	}
+
+	// Streaks: canceled and in-progress builds neither extend nor break a run
+	sort.SliceStable(outcomes, func(i, j int) bool { return outcomes[i].finishedAt.Before(outcomes[j].finishedAt) })
+	for _, o := range outcomes {
+		if !o.passed {
+			m.CurrentStreak = 0
+			continue
+		}
+		m.CurrentStreak++
+		if m.CurrentStreak > m.LongestStreak {
+			m.LongestStreak = m.CurrentStreak
+		}
+	}
+
+	m.DurationBuckets = sortedKeys(durations)
+	m.AvgDurations = make([]float64, len(m.DurationBuckets))
+	m.P50Durations = make([]float64, len(m.DurationBuckets))
+	m.P90Durations = make([]float64, len(m.DurationBuckets))
+	for i, k := range m.DurationBuckets {
+		m.AvgDurations[i] = mean(durations[k])
+		m.P50Durations[i] = percentileValue(durations[k], 0.5)
+		m.P90Durations[i] = percentileValue(durations[k], 0.9)
+	}
+
+	rateKeys := make(map[string]struct{})
+	for k := range passed {
+		rateKeys[k] = struct{}{}
+	}
+	for k := range failed {
+		rateKeys[k] = struct{}{}
+	}
+	for k := range canceledInRate {
+		rateKeys[k] = struct{}{}
+	}
+	m.RateBuckets = sortedKeys(rateKeys)
+	m.FailureRates = make([]float64, len(m.RateBuckets))
+	m.Passed = make([]int, len(m.RateBuckets))
+	m.Failed = make([]int, len(m.RateBuckets))
+	for i, k := range m.RateBuckets {
+		m.Passed[i] = passed[k]
+		m.Failed[i] = failed[k]
+		if total := passed[k] + failed[k] + canceledInRate[k]; total > 0 {
+			m.FailureRates[i] = float64(failed[k]+canceledInRate[k]) / float64(total) * 100
+		}
+	}
+
+	m.CanceledBuckets = sortedKeys(canceled)
+	m.Canceled = make([]int, len(m.CanceledBuckets))
+	for i, k := range m.CanceledBuckets {
+		m.Canceled[i] = canceled[k]
+	}
+
+	m.FrequencyBuckets = sortedKeys(counts)
+	m.DeployCounts = make([]int, len(m.FrequencyBuckets))
+	for i, k := range m.FrequencyBuckets {
+		m.DeployCounts[i] = counts[k]
+	}
+	return m
+}
+
+// deploymentTimeJSON is the deployment_time section, keyed by bucketName ("weeks", "months" or "days").
+func (m buildkiteMetrics) deploymentTimeJSON(bucketName string) gin.H {
+	out := gin.H{
+		bucketName:          m.DurationBuckets,
+		"avg_duration_mins": m.AvgDurations,
+		"p50_duration_mins": m.P50Durations,
+		"p90_duration_mins": m.P90Durations,
+	}
+	buckets := m.reportedBuckets(m.DurationBuckets)
+	if m.GapBuckets != nil {
+		out[bucketName] = buckets
+		out["avg_duration_mins"] = alignFloats(m.DurationBuckets, m.AvgDurations, buckets)
+		out["p50_duration_mins"] = alignFloats(m.DurationBuckets, m.P50Durations, buckets)
+		out["p90_duration_mins"] = alignFloats(m.DurationBuckets, m.P90Durations, buckets)
+	}
+	addBucketRanges(out, bucketName, buckets)
+	return out
+}
+
+// failureRateJSON is the failure_rate section, keyed by bucketName ("weeks", "months" or "days"). Buckets with
+// only canceled deploys are listed too, with a null failure rate.
+func (m buildkiteMetrics) failureRateJSON(bucketName string) gin.H {
+	out := gin.H{
+		bucketName:     m.RateBuckets,
+		"failure_rate": m.FailureRates,
+		"passed":       m.Passed,
+		"failed":       m.Failed,
+		"canceled":     alignInts(m.CanceledBuckets, m.Canceled, m.RateBuckets),
+	}
+	buckets := m.reportedBuckets(unionKeys(m.RateBuckets, m.CanceledBuckets))
+	if len(buckets) != len(m.RateBuckets) {
+		out[bucketName] = buckets
+		out["failure_rate"] = alignFloats(m.RateBuckets, m.FailureRates, buckets)
+		out["passed"] = alignInts(m.RateBuckets, m.Passed, buckets)
+		out["failed"] = alignInts(m.RateBuckets, m.Failed, buckets)
+		out["canceled"] = alignInts(m.CanceledBuckets, m.Canceled, buckets)
+	}
+	addBucketRanges(out, bucketName, buckets)
+	return out
+}
+
+// frequencyJSON is the deploy-count section (finished deployment builds, any state), keyed by bucketName ("weeks", "months" or "days").
+func (m buildkiteMetrics) frequencyJSON(bucketName string) gin.H {
+	buckets := m.reportedBuckets(m.FrequencyBuckets)
+	out := gin.H{
+		bucketName:     buckets,
+		"deploy_count": alignInts(m.FrequencyBuckets, m.DeployCounts, buckets),
+	}
+	addBucketRanges(out, bucketName, buckets)
+	return out
+}
+
+// weekdayJSON reports metrics bucketed by weekdayKey as Monday–Sunday series; weekdays without deploys are zero.
+func (m buildkiteMetrics) weekdayJSON() gin.H {
+	index := func(keys []string) map[string]int {
+		idx := make(map[string]int, len(keys))
+		for i, k := range keys {
+			idx[k] = i
+		}
+		return idx
+	}
+	durations, rates, counts := index(m.DurationBuckets), index(m.RateBuckets), index(m.FrequencyBuckets)
+	canceledIdx := index(m.CanceledBuckets)
+	names := make([]string, len(weekdays))
+	avg := make([]float64, len(weekdays))
+	p50 := make([]float64, len(weekdays))
+	p90 := make([]float64, len(weekdays))
+	failureRate := make([]float64, len(weekdays))
+	passed := make([]int, len(weekdays))
+	failed := make([]int, len(weekdays))
+	canceled := make([]int, len(weekdays))
+	deployCount := make([]int, len(weekdays))
+	for i, wd := range weekdays {
+		day := wd.String()
+		names[i] = day
+		if j, ok := durations[day]; ok {
+			avg[i] = m.AvgDurations[j]
+			p50[i] = m.P50Durations[j]
+			p90[i] = m.P90Durations[j]
+		}
+		if j, ok := rates[day]; ok {
+			failureRate[i] = m.FailureRates[j]
+			passed[i] = m.Passed[j]
+			failed[i] = m.Failed[j]
+		}
+		if j, ok := canceledIdx[day]; ok {
+			canceled[i] = m.Canceled[j]
+		}
+		if j, ok := counts[day]; ok {
+			deployCount[i] = m.DeployCounts[j]
+		}
+	}
+	return gin.H{
+		"weekdays":          names,
+		"avg_duration_mins": avg,
+		"p50_duration_mins": p50,
+		"p90_duration_mins": p90,
+		"failure_rate":      failureRate,
+		"passed":            passed,
+		"failed":            failed,
+		"canceled":          canceled,
+		"deploy_count":      deployCount,
+	}
+}
+
+// streakJSON is the deploys-without-failures streak for meta.
+func (m buildkiteMetrics) streakJSON() gin.H {
+	return gin.H{
+		"current": m.CurrentStreak,
+		"longest": m.LongestStreak,
+		"note":    "consecutive passed deploys by finish time; canceled builds are ignored",
+	}
+}
+
+// addExclusionMeta records how many deploys exclude_first_of_day left out of the failure rate.
+func (m buildkiteMetrics) addExclusionMeta(meta gin.H, opts buildkiteAggOptions) {
+	if opts.ExcludeFirstOfDay {
+		meta["excluded_first_of_day"] = m.ExcludedFirstOfDay
+		meta["exclusion_note"] = "each day's first deploy (by start time) is left out of the failure rate; counts and durations include it"
+	}
+}
+
+// addFailureAccountingMeta spells out which build states the failure rate counts.
+func (m buildkiteMetrics) addFailureAccountingMeta(meta gin.H, opts buildkiteAggOptions) {
+	definition := "failed / (passed + failed) * 100; canceled reported separately, not in the rate"
+	if opts.IncludeCanceled {
+		definition = "(failed + canceled) / (passed + failed + canceled) * 100"
+	}
+	meta["failure_rate_definition"] = definition
+	meta["include_canceled"] = opts.IncludeCanceled
+	meta["canceled_builds"] = m.CanceledCount
+	meta["failure_rate_note"] = "running and scheduled builds are excluded until they finish; buckets with only canceled deploys are listed with a null failure rate"
+}
+
+// sortedKeys returns a map's keys in ascending order (nil when empty, matching the handlers' JSON).
+func sortedKeys[V any](m map[string]V) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func mean(vals []float64) float64 {
+	if len(vals) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, v := range vals {
+		sum += v
+	}
+	return sum / float64(len(vals))
+}
+
+// buildkiteSlugPattern matches BuildKite pipeline slugs (lowercase letters, digits, dashes).
+var buildkiteSlugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,99}$`)
+
+const buildkiteAdhocMaxPipelines = 10
+
+// GET /api/buildkite/adhoc?pipelines=foo,bar&weeks=8 – deployment metrics for an arbitrary pipeline list.
+// Bypasses the configured deployment pipelines for this request only.
+func buildkiteAdhoc(c *gin.Context) {
+	token, _, ok := buildkiteConfig()
+	if !ok {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error":   "BuildKite not configured",
+			"missing": buildkiteConfigMissing(),
+			"hint":    "Set BUILDKITE_TOKEN and BUILDKITE_ORG in .env",
+		})
+		return
+	}
+	orgs, err := selectBuildkiteOrgs(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	opts, err := buildkiteAggOptionsFromQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var pipelines []string
+	seen := make(map[string]struct{})
+	for _, p := range strings.Split(c.Query("pipelines"), ",") {
+		p = strings.TrimSpace(strings.ToLower(p))
+		if p == "" {
+			continue
+		}
+		if !buildkiteSlugPattern.MatchString(p) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid pipeline slug %q", p)})
+			return
+		}
+		if _, dup := seen[p]; !dup {
+			seen[p] = struct{}{}
+			pipelines = append(pipelines, p)
+		}
+	}
+	if len(pipelines) == 0 || len(pipelines) > buildkiteAdhocMaxPipelines {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("pipelines= must list 1–%d pipeline slugs", buildkiteAdhocMaxPipelines)})
+		return
+	}
+	weeks, err := strconv.Atoi(c.DefaultQuery("weeks", "8"))
+	if err != nil || weeks < 1 || weeks > 52 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "weeks must be an integer between 1 and 52"})
+		return
+	}
+
+	now := requestNow(c)
+	createdFrom := now.AddDate(0, 0, -7*weeks)
+	builds, cacheStatus, err := getCachedBuilds(c.Request.Context(), token, orgs, pipelines, createdFrom)
+	if err != nil {
+		c.JSON(upstreamFailureStatus(err), upstreamErrorBody("Failed to fetch builds: ", err))
+		return
+	}
+
+	opts.IsDeploy = func(b BuildkiteBuild) bool {
+		_, ok := seen[strings.ToLower(b.Pipeline.Slug)]
+		return ok
+	}
+	m := aggregateBuildkite(builds, opts)
+	fillGaps := parseFillGaps(c)
+	if fillGaps {
+		m.fillGaps(bucketGranularity(granularityWeek).keys(createdFrom, now))
+	}
+
+	meta := gin.H{
+		"source":            cacheStatus.source(),
+		"total_builds":      len(builds),
+		"deployment_builds": m.Deployments,
+		"passed_builds":     m.PassedCount,
+		"failed_builds":     m.FailedCount,
+		"date_range":        fmt.Sprintf("last %d weeks (from %s)", weeks, createdFrom.Format("2006-01-02")),
+		"fill_gaps":         fillGaps,
+		"cache_age_sec":     int(cacheStatus.Age.Seconds()),
+		"org":               strings.Join(orgs, ","),
+		"bucket_by":         opts.BucketBy,
+		"duration_basis":    opts.DurationBasis,
+		"branch_patterns":   opts.Branches.patterns(),
+		"bucket_timezone":   bucketLocation.String(),
+	}
+	addBuildkitePipelines(meta, cacheStatus.Pipelines)
+	m.addFailureAccountingMeta(meta, opts)
+	addBuildkitePartial(c, meta)
+	c.JSON(http.StatusOK, gin.H{
+		"deployment_time": m.deploymentTimeJSON("weeks"),
+		"failure_rate":    m.failureRateJSON("weeks"),
+		"frequency":       m.frequencyJSON("weeks"),
+		"meta":            meta,
+	})
+}
+
+// GET /api/buildkite/builds?start_date=&end_date=&pipeline=&state=passed,failed – the parsed builds behind the
+// BuildKite KPIs, for explaining a surprising week. Defaults to the last 30 days of the deployment pipelines;
+// ?pipeline= inspects any one pipeline instead. Each build carries its computed duration (per ?duration_basis=) and
+// whether it counts as a deployment (deployment pipeline, production branch).
+func buildkiteBuildsDebug(c *gin.Context) {
+	token, _, ok := buildkiteConfig()
+	if !ok {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error":   "BuildKite not configured",
+			"missing": buildkiteConfigMissing(),
+			"hint":    "Set BUILDKITE_TOKEN and BUILDKITE_ORG in .env",
+		})
+		return
+	}
+	orgs, err := selectBuildkiteOrgs(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	opts, err := buildkiteAggOptionsFromQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	endDate := requestNow(c) // exclusive
+	startDate := endDate.AddDate(0, 0, -30)
+	for name, dst := range map[string]*time.Time{"start_date": &startDate, "end_date": &endDate} {
+		if raw := c.Query(name); raw != "" {
+			t, err := time.ParseInLocation("2006-01-02", raw, bucketLocation)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": name + " must be YYYY-MM-DD"})
+				return
+			}
+			if name == "end_date" {
+				t = t.AddDate(0, 0, 1) // inclusive
+			}
+			*dst = t
+		}
+	}
+	if !endDate.After(startDate) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "end_date must be after start_date"})
+		return
+	}
+
+	pipelines := buildkiteDeploymentPipelines
+	if p := strings.TrimSpace(strings.ToLower(c.Query("pipeline"))); p != "" {
+		if !buildkiteSlugPattern.MatchString(p) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid pipeline slug %q", p)})
+			return
+		}
+		pipelines = []string{p}
+	}
+	states := make(map[string]bool)
+	for _, s := range strings.Split(c.Query("state"), ",") {
+		if s = strings.TrimSpace(strings.ToLower(s)); s != "" {
+			states[s] = true
+		}
+	}
+
+	builds, cacheStatus, err := getCachedBuilds(c.Request.Context(), token, orgs, pipelines, startDate)
+	if err != nil {
+		c.JSON(upstreamFailureStatus(err), upstreamErrorBody("Failed to fetch builds: ", err))
+		return
+	}
+
+	// Window on the KPIs' basis time (finish), falling back to creation for builds still running; newest first
+	type windowed struct {
+		build BuildkiteBuild
+		at    time.Time
+	}
+	var inWindow []windowed
+	for _, b := range builds {
+		if len(states) > 0 && !states[b.State] {
+			continue
+		}
+		at, ok := parseTime(b.FinishedAt)
+		if !ok {
+			at, ok = parseTime(b.CreatedAt)
+		}
+		if ok && !at.Before(startDate) && at.Before(endDate) {
+			inWindow = append(inWindow, windowed{b, at})
+		}
+	}
+	sort.SliceStable(inWindow, func(i, j int) bool { return inWindow[i].at.After(inWindow[j].at) })
+
+	out := make([]gin.H, 0, len(inWindow))
+	for _, w := range inWindow {
+		b := w.build
+		var duration *float64
+		if finished, ok := parseTime(b.FinishedAt); ok {
+			if from, ok := opts.durationStart(b); ok && finished.After(from) {
+				d := durationMins(finished.Sub(from))
+				duration = &d
+			}
+		}
+		out = append(out, gin.H{
+			"number":            b.Number,
+			"org":               b.Org,
+			"pipeline":          b.Pipeline.Slug,
+			"state":             b.State,
+			"branch":            b.Branch,
+			"created_at":        b.CreatedAt,
+			"scheduled_at":      b.ScheduledAt,
+			"started_at":        b.StartedAt,
+			"finished_at":       b.FinishedAt,
+			"duration_mins":     duration,
+			"is_deployment":     isDeploymentPipeline(b),
+			"production_branch": opts.Branches.matches(b.Branch),
+			"week":              weekKey(w.at),
+			"url":               buildkiteBuildURL(b.Org, b),
+			"commit":            b.Commit,
+		})
+	}
+
+	meta := gin.H{
+		"source":          cacheStatus.source(),
+		"count":           len(out),
+		"start_date":      startDate.In(bucketLocation).Format("2006-01-02"),
+		"end_date":        endDate.Add(-time.Nanosecond).In(bucketLocation).Format("2006-01-02"),
+		"states":          sortedKeys(states),
+		"cache_age_sec":   int(cacheStatus.Age.Seconds()),
+		"org":             strings.Join(orgs, ","),
+		"duration_basis":  opts.DurationBasis,
+		"branch_patterns": opts.Branches.patterns(),
+		"bucket_timezone": bucketLocation.String(),
+		"note":            "builds filtered by finished_at (created_at while running); is_deployment && production_branch is what the KPIs count",
+	}
+	addBuildkitePipelines(meta, cacheStatus.Pipelines)
+	addBuildkitePartial(c, meta)
+	c.JSON(http.StatusOK, gin.H{"builds": out, "meta": meta})
+}
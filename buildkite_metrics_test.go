@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestFailureRateListsAllCanceledWeeks(t *testing.T) {
+	deploy := func(state, finishedAt string) BuildkiteBuild {
+		b := BuildkiteBuild{State: state, StartedAt: finishedAt, FinishedAt: finishedAt}
+		b.Pipeline.Slug = defaultBuildkitePipelines[0]
+		return b
+	}
+	builds := []BuildkiteBuild{
+		deploy("passed", "2024-05-06T10:00:00Z"), // 2024-W19
+		deploy("failed", "2024-05-07T10:00:00Z"),
+		deploy("canceled", "2024-05-14T10:00:00Z"), // 2024-W20: only canceled
+		deploy("canceled", "2024-05-15T10:00:00Z"),
+	}
+	for _, includeCanceled := range []bool{false, true} {
+		m := aggregateBuildkite(builds, buildkiteAggOptions{IncludeCanceled: includeCanceled})
+		raw, err := json.Marshal(m.failureRateJSON("weeks"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got struct {
+			Weeks       []string   `json:"weeks"`
+			FailureRate []*float64 `json:"failure_rate"`
+			Canceled    []int      `json:"canceled"`
+		}
+		if err := json.Unmarshal(raw, &got); err != nil {
+			t.Fatal(err)
+		}
+		if len(got.Weeks) != 2 || got.Weeks[1] != "2024-W20" || len(got.Canceled) != 2 || got.Canceled[0] != 0 || got.Canceled[1] != 2 {
+			t.Fatalf("include_canceled=%v: %s", includeCanceled, raw)
+		}
+		if got.FailureRate[0] == nil || *got.FailureRate[0] != 50 {
+			t.Errorf("include_canceled=%v: W19 failure rate = %v, want 50", includeCanceled, got.FailureRate[0])
+		}
+		// Counted in the rate, two canceled deploys are a 100% week; otherwise the week has no rate
+		if includeCanceled != (got.FailureRate[1] != nil && *got.FailureRate[1] == 100) {
+			t.Errorf("include_canceled=%v: W20 failure rate = %v", includeCanceled, got.FailureRate[1])
+		}
+	}
+}
+
+func TestZeroDurationDeploysAreTimed(t *testing.T) {
+	deploy := func(startedAt, finishedAt string) BuildkiteBuild {
+		b := BuildkiteBuild{State: "passed", StartedAt: startedAt, FinishedAt: finishedAt}
+		b.Pipeline.Slug = defaultBuildkitePipelines[0]
+		return b
+	}
+	m := aggregateBuildkite([]BuildkiteBuild{
+		deploy("2024-05-06T10:00:00Z", "2024-05-06T10:00:00Z"), // no-op deploy: 0 minutes
+		deploy("2024-05-06T11:00:00Z", "2024-05-06T11:10:00Z"),
+		deploy("2024-05-06T12:10:00Z", "2024-05-06T12:00:00Z"), // started after it finished: skipped
+	}, buildkiteAggOptions{DurationBasis: durationBasisStarted})
+	if m.TimedCount != 2 {
+		t.Errorf("timed = %d, want 2 (the 0-minute deploy counts, the skewed one doesn't)", m.TimedCount)
+	}
+}
@@ -1,13 +1,15 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"net/url"
-	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -17,434 +19,558 @@ import (
 // Rate limiter for BuildKite API (200 req/min = ~3 req/sec)
 var buildkiteRateLimiter = time.NewTicker(350 * time.Millisecond) // ~2.85 req/sec to be safe
 
-// Cache for BuildKite data
+// buildkiteMaxInFlight caps concurrent BuildKite requests across all pipelines and pages.
+const buildkiteMaxInFlight = 6
+
+var buildkiteInFlight = make(chan struct{}, buildkiteMaxInFlight)
+
+// buildkiteThrottle waits for an in-flight slot and a rate-limiter tick. Call the returned func when the request is done.
+func buildkiteThrottle() (release func()) {
+	buildkiteInFlight <- struct{}{}
+	<-buildkiteRateLimiter.C
+	return func() { <-buildkiteInFlight }
+}
+
+// BuildKite build lists are cached for buildkiteCacheTTL (BUILDKITE_CACHE_TTL_SEC, default 5 minutes), keyed by
+// org list, pipeline list, and window start (see buildkiteCacheKey). Expired entries are kept until
+// buildkiteCacheMaxAge to serve when a refresh fails. POST /api/buildkite/cache/refresh clears it.
 var (
-	buildkiteCache      *BuildKiteCacheData
-	buildkiteCacheMutex sync.RWMutex
-	buildkiteCacheTTL   = 5 * time.Minute
+	buildkiteCacheTTL = 5 * time.Minute
+	// buildkiteCacheMaxAge is the hard limit for serving cached builds when a refresh fails.
+	// Past this age the data is treated as invalid rather than merely stale.
+	buildkiteCacheMaxAge = 30 * time.Minute
+	buildkiteCache       = newTTLCache[string, cachedBuilds](buildkiteCacheTTL, buildkiteCacheMaxAge)
 )
 
-type BuildKiteCacheData struct {
-	Builds    []BuildkiteBuild
-	FetchedAt time.Time
+// cachedBuilds is one complete fetch: the builds and each pipeline's outcome (build count, truncation).
+type cachedBuilds struct {
+	builds    []BuildkiteBuild
+	pipelines []buildkitePipelineStatus
 }
 
-func getCachedBuilds(c *gin.Context, token, org string, createdFrom time.Time) ([]BuildkiteBuild, error) {
-	buildkiteCacheMutex.RLock()
-	if buildkiteCache != nil && time.Since(buildkiteCache.FetchedAt) < buildkiteCacheTTL {
-		builds := buildkiteCache.Builds
-		buildkiteCacheMutex.RUnlock()
-		log.Printf("[BuildKite Cache] Using cached data (%d builds, age: %v)", len(builds), time.Since(buildkiteCache.FetchedAt))
-		return builds, nil
-	}
-	buildkiteCacheMutex.RUnlock()
+// errBuildkiteCacheTooOld is returned when BuildKite is unreachable and the cached builds are older than buildkiteCacheMaxAge.
+var errBuildkiteCacheTooOld = errors.New("BuildKite unavailable and cache too old")
 
-	// Cache miss or expired, fetch new data
-	builds, err := fetchBuildsParallel(c, token, org, createdFrom)
-	if err != nil {
-		return nil, err
-	}
+// buildkiteCacheStatus describes how getCachedBuilds satisfied a request.
+type buildkiteCacheStatus struct {
+	Age        time.Duration // age of the data returned (0 when freshly fetched)
+	Stale      bool          // true when a refresh failed and older cached data was served
+	RefreshErr error         // the refresh error behind a stale response
+	// Pipelines is the per-pipeline fetch outcome for meta.pipelines, as of the fetch that produced the data
+	Pipelines []buildkitePipelineStatus
+}
 
-	// Update cache
-	buildkiteCacheMutex.Lock()
-	buildkiteCache = &BuildKiteCacheData{
-		Builds:    builds,
-		FetchedAt: time.Now(),
-	}
-	buildkiteCacheMutex.Unlock()
-	log.Printf("[BuildKite Cache] Updated cache with %d builds", len(builds))
+// source is meta.source for builds returned with this status.
+func (s buildkiteCacheStatus) source() string {
+	return cacheSource(s.Age > 0, s.Stale)
+}
 
-	return builds, nil
+// buildkiteCacheKey identifies one fetch shape. The window start is truncated to the hour so repeated
+// loads share an entry while different windows (30 days vs 3 months) don't clobber each other.
+func buildkiteCacheKey(orgs, pipelines []string, createdFrom time.Time) string {
+	return strings.Join(orgs, ",") + "|" + strings.Join(pipelines, ",") + "|" + createdFrom.UTC().Truncate(time.Hour).Format(time.RFC3339)
 }
 
-// fetchBuildsFromPipeline fetches builds from a single pipeline with parallel pagination
-func fetchBuildsFromPipeline(c *gin.Context, token, org, pipeline string, createdFrom time.Time) ([]BuildkiteBuild, error) {
-	// First, fetch page 1 to check total count
-	firstPageURL := fmt.Sprintf("%s/organizations/%s/pipelines/%s/builds?created_from=%s&per_page=%d&page=1",
-		buildkiteBaseURL, org, pipeline, url.QueryEscape(createdFrom.Format(time.RFC3339)), buildkitePerPage)
+// getCachedBuilds returns builds created since createdFrom, from the cache when fresh. Under a ?now=
+// override, builds created after that time are dropped.
+func getCachedBuilds(ctx context.Context, token string, orgs, pipelines []string, createdFrom time.Time) ([]BuildkiteBuild, buildkiteCacheStatus, error) {
+	builds, status, err := cachedBuildsSince(ctx, token, orgs, pipelines, createdFrom)
+	return buildsBeforeNow(ctx, builds), status, err
+}
 
-	<-buildkiteRateLimiter.C // Rate limit
-	req, err := http.NewRequestWithContext(c.Request.Context(), http.MethodGet, firstPageURL, nil)
-	if err != nil {
-		return nil, err
+func cachedBuildsSince(ctx context.Context, token string, orgs, pipelines []string, createdFrom time.Time) ([]BuildkiteBuild, buildkiteCacheStatus, error) {
+	cacheKey := buildkiteCacheKey(orgs, pipelines, createdFrom)
+	if cached, age, ok := buildkiteCache.Peek(cacheKey); ok && age < buildkiteCacheTTL {
+		buildkiteCacheLookups.WithLabelValues("hit").Inc()
+		logf(ctx, "BuildKite Cache", "Using cached data (%d builds, age: %v)", len(cached.builds), age)
+		return cached.builds, buildkiteCacheStatus{Age: age, Pipelines: cached.pipelines}, nil
 	}
-	req.Header.Set("Authorization", "Bearer "+token)
-	req.Header.Set("Accept", "application/json")
 
-	resp, err := http.DefaultClient.Do(req)
+	// Cache miss or expired, fetch new data
+	buildkiteCacheLookups.WithLabelValues("miss").Inc()
+	builds, statuses, err := refreshCachedBuilds(ctx, token, orgs, pipelines, createdFrom)
 	if err != nil {
-		return nil, err
+		cached, age, ok := buildkiteCache.Peek(cacheKey)
+		if !ok {
+			return nil, buildkiteCacheStatus{Pipelines: statuses}, err
+		}
+		if age > buildkiteCacheMaxAge {
+			logf(ctx, "BuildKite Cache", "Refresh failed and cache is %v old (max %v): %v", age, buildkiteCacheMaxAge, err)
+			return nil, buildkiteCacheStatus{Age: age, RefreshErr: err, Pipelines: statuses}, errBuildkiteCacheTooOld
+		}
+		buildkiteCacheLookups.WithLabelValues("stale").Inc()
+		logf(ctx, "BuildKite Cache", "Refresh failed, serving stale data (age: %v): %v", age, err)
+		return cached.builds, buildkiteCacheStatus{Age: age, Stale: true, RefreshErr: err, Pipelines: cached.pipelines}, nil
 	}
-	body, _ := io.ReadAll(resp.Body)
-	resp.Body.Close()
+	return builds, buildkiteCacheStatus{Pipelines: statuses}, nil
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("BuildKite API returned %d: %s", resp.StatusCode, string(body))
+// refreshCachedBuilds fetches builds created since createdFrom and stores them in the cache. The entry is only
+// replaced by a complete fetch: on error or partial data the cache is left as it was.
+func refreshCachedBuilds(ctx context.Context, token string, orgs, pipelines []string, createdFrom time.Time) ([]BuildkiteBuild, []buildkitePipelineStatus, error) {
+	partialBefore := len(buildkitePartial(ctx))
+	builds, statuses, err := fetchBuildsAcrossOrgs(ctx, orgs, func(org string) ([]BuildkiteBuild, []buildkitePipelineStatus, error) {
+		return fetchBuildsParallel(ctx, token, org, pipelines, createdFrom)
+	})
+	if err != nil {
+		return nil, statuses, err
 	}
 
-	var firstPageBuilds []BuildkiteBuild
-	if err := json.Unmarshal(body, &firstPageBuilds); err != nil {
-		return nil, err
+	// Partial fetches aren't cached: later hits couldn't report what was missing
+	if len(buildkitePartial(ctx)) > partialBefore {
+		logf(ctx, "BuildKite Cache", "Not caching partial fetch (%d builds)", len(builds))
+		return builds, statuses, nil
 	}
+	buildkiteCache.Set(buildkiteCacheKey(orgs, pipelines, createdFrom), cachedBuilds{builds, statuses})
+	logf(ctx, "BuildKite Cache", "Updated cache with %d builds", len(builds))
+	return builds, statuses, nil
+}
 
-	if len(firstPageBuilds) < buildkitePerPage {
-		// Only one page
-		log.Printf("[BuildKite] Total builds fetched: %d (1 page)", len(firstPageBuilds))
-		return firstPageBuilds, nil
+// POST /api/buildkite/cache/refresh?org= – refetches the combined-all window (3 months of the deployment
+// pipelines) and replaces that window's cached builds, so a deploy that just finished shows up without waiting
+// out the cache TTL. The cache is only touched once the fetch succeeds; a failed refresh leaves it serving.
+// Needs ENV=dev or X-API-Key (DASHBOARD_API_KEY), as each call costs a full BuildKite fetch. Returns the
+// fetch duration and build count.
+func buildkiteCacheRefresh(c *gin.Context) {
+	if !apiKeyAllowed(c) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "cache refresh not allowed",
+			"hint":  "the refresh is allowed with ENV=dev or a matching X-API-Key header (DASHBOARD_API_KEY)",
+		})
+		return
 	}
-
-	// Determine how many pages to fetch (cap at buildkiteMaxPages)
-	totalPages := buildkiteMaxPages
-	if len(firstPageBuilds) < buildkitePerPage {
-		totalPages = 1
+	token, _, ok := buildkiteConfig()
+	if !ok {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error":   "BuildKite not configured",
+			"missing": buildkiteConfigMissing(),
+			"hint":    "Set BUILDKITE_TOKEN and BUILDKITE_ORG in .env",
+		})
+		return
 	}
-
-	// Fetch remaining pages in parallel
-	type pageResult struct {
-		page   int
-		builds []BuildkiteBuild
-		err    error
+	orgs, err := selectBuildkiteOrgs(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
-	results := make(chan pageResult, totalPages-1)
-	var wg sync.WaitGroup
-
-	for page := 2; page <= totalPages; page++ {
-		wg.Add(1)
-		go func(pageNum int) {
-			defer wg.Done()
-
-			query := url.Values{}
-			query.Set("created_from", createdFrom.Format(time.RFC3339))
-			query.Set("per_page", fmt.Sprintf("%d", buildkitePerPage))
-			query.Set("page", fmt.Sprintf("%d", pageNum))
-
-			pageURL := fmt.Sprintf("%s/organizations/%s/pipelines/%s/builds?%s",
-				buildkiteBaseURL, org, pipeline, query.Encode())
-
-			// Rate limit: wait for token
-			<-buildkiteRateLimiter.C
-
-			req, err := http.NewRequestWithContext(c.Request.Context(), http.MethodGet, pageURL, nil)
-			if err != nil {
-				results <- pageResult{page: pageNum, err: err}
-				return
-			}
-
-			req.Header.Set("Authorization", "Bearer "+token)
-			req.Header.Set("Accept", "application/json")
-
-			resp, err := http.DefaultClient.Do(req)
-			if err != nil {
-				results <- pageResult{page: pageNum, err: err}
-				return
-			}
-			defer resp.Body.Close()
-			body, _ := io.ReadAll(resp.Body)
-
-			if resp.StatusCode != http.StatusOK {
-				results <- pageResult{page: pageNum, err: fmt.Errorf("page %d: %d %s", pageNum, resp.StatusCode, string(body))}
-				return
-			}
-
-			var builds []BuildkiteBuild
-			if err := json.Unmarshal(body, &builds); err != nil {
-				results <- pageResult{page: pageNum, err: err}
-				return
-			}
+	logf(c.Request.Context(), "BuildKite Cache", "Refresh requested")
+	startTime := time.Now()
+	threeMonthsAgo := requestNow(c).AddDate(0, -3, 0)
+	builds, statuses, err := refreshCachedBuilds(c.Request.Context(), token, orgs, buildkiteDeploymentPipelines, threeMonthsAgo)
+	if err != nil {
+		c.JSON(upstreamFailureStatus(err), upstreamErrorBody("Failed to fetch builds (cache left unchanged): ", err))
+		return
+	}
+	builds = buildsBeforeNow(c.Request.Context(), builds)
 
-			results <- pageResult{page: pageNum, builds: builds}
-		}(page)
+	meta := gin.H{
+		"org":           strings.Join(orgs, ","),
+		"date_range":    fmt.Sprintf("last 3 months (from %s)", threeMonthsAgo.Format("2006-01-02")),
+		"cache_ttl_sec": int(buildkiteCacheTTL.Seconds()),
 	}
+	addBuildkitePipelines(meta, statuses)
+	addBuildkitePartial(c, meta)
+	c.JSON(http.StatusOK, gin.H{
+		"refreshed":          true,
+		"build_count":        len(builds),
+		"fetch_duration_sec": time.Since(startTime).Seconds(),
+		"meta":               meta,
+	})
+}
 
-	// Close results channel after all goroutines complete
-	go func() {
-		wg.Wait()
-		close(results)
-	}()
+// buildkitePageBatch is how many pages of one pipeline are requested at once after page 1 when BuildKite doesn't
+// say which page is last. Batching stops at the last page, so a pipeline with 150 builds costs 2 requests.
+const buildkitePageBatch = 3
+
+// buildkiteBuildsPageURL is one page of a pipeline's builds created since createdFrom.
+func buildkiteBuildsPageURL(org, pipeline string, createdFrom time.Time, page int) string {
+	query := url.Values{}
+	query.Set("created_from", createdFrom.Format(time.RFC3339))
+	query.Set("per_page", fmt.Sprintf("%d", buildkitePerPage))
+	query.Set("page", fmt.Sprintf("%d", page))
+	return fmt.Sprintf("%s/organizations/%s/pipelines/%s/builds?%s", buildkiteBaseURL, org, pipeline, query.Encode())
+}
 
-	// Collect results
-	allBuilds := make(map[int][]BuildkiteBuild)
-	allBuilds[1] = firstPageBuilds
+// buildkitePage is one page of builds plus what its Link header says about the rest.
+type buildkitePage struct {
+	Builds []BuildkiteBuild
+	More   bool // rel="next" is present (or, without a Link header, the page was full)
+	Last   int  // page number of rel="last"; 0 when BuildKite didn't send one
+}
 
-	for res := range results {
-		if res.err != nil {
-			log.Printf("[BuildKite] Error fetching page %d: %v", res.page, res.err)
-			continue
+// buildkiteGet GETs a BuildKite API URL through buildkiteThrottle, retrying 429 and 5xx responses up to
+// buildkiteMaxAttempts times with jittered exponential backoff, or after Retry-After when BuildKite sends one.
+// It returns the body and headers of a 200 response; any other status is an upstreamError.
+func buildkiteGet(ctx context.Context, token, apiURL string) ([]byte, http.Header, error) {
+	for attempt := 0; ; attempt++ {
+		body, header, retryAfter, err := getBuildkite(ctx, token, apiURL)
+		var ue *upstreamError
+		retryable := errors.As(err, &ue) && (ue.Status == http.StatusTooManyRequests || ue.Status >= 500)
+		if err == nil || !retryable || attempt+1 >= buildkiteMaxAttempts {
+			return body, header, err
 		}
-		if len(res.builds) == 0 {
-			break // No more pages
+		wait := backoffWithJitter(attempt, buildkiteBackoffBase, buildkiteBackoffMax)
+		if retryAfter > 0 {
+			wait = min(retryAfter, buildkiteRetryAfterMax)
 		}
-		allBuilds[res.page] = res.builds
-	}
-
-	// Combine all pages in order
-	var combined []BuildkiteBuild
-	for page := 1; page <= totalPages; page++ {
-		if builds, ok := allBuilds[page]; ok {
-			combined = append(combined, builds...)
+		logf(ctx, "BuildKite", "%v; retrying in %v (attempt %d/%d)", err, wait, attempt+2, buildkiteMaxAttempts)
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
 		}
 	}
-
-	log.Printf("[BuildKite] Total builds fetched from %s: %d (%d pages in parallel)", pipeline, len(combined), len(allBuilds))
-	return combined, nil
 }
 
-// fetchBuildsParallel fetches builds from both deployment pipelines
-func fetchBuildsParallel(c *gin.Context, token, org string, createdFrom time.Time) ([]BuildkiteBuild, error) {
-	pipelines := []string{
-		"core-stack-deployment-pipeline",
-		"core-stack-deployment-pipeline-legacy",
+// getBuildkite is one rate-limited attempt at apiURL. retryAfter is BuildKite's Retry-After, if it sent one.
+func getBuildkite(ctx context.Context, token, apiURL string) (body []byte, header http.Header, retryAfter time.Duration, err error) {
+	release := buildkiteThrottle()
+	defer release()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL, nil)
+	if err != nil {
+		return nil, nil, 0, err
 	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/json")
 
-	var allBuilds []BuildkiteBuild
-	for _, pipeline := range pipelines {
-		builds, err := fetchBuildsFromPipeline(c, token, org, pipeline, createdFrom)
-		if err != nil {
-			log.Printf("[BuildKite] Warning: Failed to fetch from %s: %v", pipeline, err)
-			continue // Continue with other pipelines even if one fails
-		}
-		allBuilds = append(allBuilds, builds...)
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, nil, 0, err
 	}
+	defer resp.Body.Close()
+	body, _ = io.ReadAll(resp.Body)
 
-	log.Printf("[BuildKite] Total builds fetched from all pipelines: %d", len(allBuilds))
-	return allBuilds, nil
+	if resp.StatusCode != http.StatusOK {
+		retryAfter, _ = retryAfterDelay(resp.Header.Get("Retry-After"))
+		return nil, nil, retryAfter, newUpstreamError(resp.StatusCode, "BuildKite API returned %d: %s", resp.StatusCode, string(body))
+	}
+	return body, resp.Header, 0, nil
 }
 
-// kpiBuildkiteCombinedAll returns both weekly and daily metrics in a single request
-func kpiBuildkiteCombinedAll(c *gin.Context) {
-	token, org, ok := buildkiteConfig()
-	if !ok {
-		missing := buildkiteConfigMissing()
-		c.JSON(http.StatusServiceUnavailable, gin.H{
-			"error":   "BuildKite not configured",
-			"missing": missing,
-			"hint":    "Set BUILDKITE_TOKEN and BUILDKITE_ORG in .env",
-		})
-		return
+// fetchBuildkitePage GETs one page of builds through buildkiteGet.
+func fetchBuildkitePage(ctx context.Context, token, pageURL string) (page buildkitePage, err error) {
+	body, header, err := buildkiteGet(ctx, token, pageURL)
+	if err != nil {
+		return page, err
 	}
+	if err := json.Unmarshal(body, &page.Builds); err != nil {
+		return page, err
+	}
+	link := header.Get("Link")
+	if link == "" {
+		page.More = len(page.Builds) == buildkitePerPage
+		return page, nil
+	}
+	links := parseLinkHeader(link)
+	page.More = links["next"] != ""
+	if last, err := url.Parse(links["last"]); err == nil {
+		page.Last, _ = strconv.Atoi(last.Query().Get("page"))
+	}
+	return page, nil
+}
 
-	// Fetch builds from last 3 months (fetch once, use for both weekly and daily)
-	threeMonthsAgo := time.Now().AddDate(0, -3, 0)
-	thirtyDaysAgo := time.Now().AddDate(0, 0, -30)
-	startTime := time.Now()
-
-	builds, err := getCachedBuilds(c, token, org, threeMonthsAgo)
+// fetchBuildsFromPipeline fetches builds from a single pipeline. Page 1's Link rel="last" gives the page count, so
+// the rest (up to buildkiteMaxPages) are fetched in parallel at once; without it, pages go buildkitePageBatch at a
+// time until there is no next page. truncated reports that pages remained after buildkiteMaxPages.
+func fetchBuildsFromPipeline(ctx context.Context, token, org, pipeline string, createdFrom time.Time) (combined []BuildkiteBuild, truncated bool, err error) {
+	first, err := fetchBuildkitePage(ctx, token, buildkiteBuildsPageURL(org, pipeline, createdFrom, 1))
 	if err != nil {
-		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to fetch builds: " + err.Error()})
-		return
+		return nil, false, err
+	}
+	combined, more, pages := first.Builds, first.More, 1
+	batchSize := buildkitePageBatch
+	if first.Last > 1 {
+		batchSize = max(1, min(first.Last, buildkiteMaxPages)-1)
 	}
 
-	fetchDuration := time.Since(startTime)
-	log.Printf("[BuildKite Combined] Processing %d builds", len(builds))
-
-	// Process data for weekly metrics
-	weekDurations := make(map[string][]float64)
-	weekPassed := make(map[string]int)
-	weekFailed := make(map[string]int)
-	weeklyDeploymentCount := 0
-	weeklyPassedCount := 0
-	weeklyFailedCount := 0
-
-	// Process data for daily metrics (last 30 days only)
-	dayDurations := make(map[string][]float64)
-	dayPassed := make(map[string]int)
-	dayFailed := make(map[string]int)
-	dailyDeploymentCount := 0
-	dailyPassedCount := 0
-	dailyFailedCount := 0
-
-	for _, build := range builds {
-		if !isDeploymentPipeline(build) {
-			continue
-		}
-
-		finishedAt, okFinish := parseTime(build.FinishedAt)
-		if !okFinish {
-			continue
-		}
-
-		week := weekKey(finishedAt)
-		day := dayKey(finishedAt)
-
-		// Process for weekly
-		weeklyDeploymentCount++
-		if build.State == "passed" {
-			startedAt, okStart := parseTime(build.StartedAt)
-			if okStart && finishedAt.After(startedAt) {
-				durationMinutes := finishedAt.Sub(startedAt).Minutes()
-				weekDurations[week] = append(weekDurations[week], durationMinutes)
-			}
-			weekPassed[week]++
-			weeklyPassedCount++
-		}
-		if build.State == "failed" {
-			weekFailed[week]++
-			weeklyFailedCount++
+	type pageResult struct {
+		page buildkitePage
+		err  error
+	}
+	for next := 2; more && next <= buildkiteMaxPages; next += batchSize {
+		batch := make([]pageResult, min(batchSize, buildkiteMaxPages-next+1))
+		var wg sync.WaitGroup
+		for i := range batch {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				page, err := fetchBuildkitePage(ctx, token, buildkiteBuildsPageURL(org, pipeline, createdFrom, next+i))
+				batch[i] = pageResult{page, err}
+			}(i)
 		}
+		wg.Wait()
 
-		// Process for daily (last 30 days only)
-		if finishedAt.After(thirtyDaysAgo) {
-			dailyDeploymentCount++
-			if build.State == "passed" {
-				startedAt, okStart := parseTime(build.StartedAt)
-				if okStart && finishedAt.After(startedAt) {
-					durationMinutes := finishedAt.Sub(startedAt).Minutes()
-					dayDurations[day] = append(dayDurations[day], durationMinutes)
-				}
-				dayPassed[day]++
-				dailyPassedCount++
+		// Combine in page order, stopping at the last page; a failed page leaves more as the page before it said
+		for i, res := range batch {
+			if res.err != nil {
+				// Retries are spent; the builds on this page are missing, so say so in the response meta
+				logf(ctx, "BuildKite", "Error fetching page %d: %v", next+i, res.err)
+				recordBuildkitePartial(ctx, "%s/%s page %d: %v", org, pipeline, next+i, res.err)
+				continue
 			}
-			if build.State == "failed" {
-				dayFailed[day]++
-				dailyFailedCount++
+			combined = append(combined, res.page.Builds...)
+			pages++
+			if more = res.page.More; !more {
+				break
 			}
 		}
 	}
-
-	// Calculate weekly metrics
-	var weeksWithDurations []string
-	for w := range weekDurations {
-		weeksWithDurations = append(weeksWithDurations, w)
+	if more {
+		logf(ctx, "BuildKite", "%s: stopped after %d pages (last page %d); older builds not fetched", pipeline, buildkiteMaxPages, first.Last)
 	}
-	sort.Strings(weeksWithDurations)
 
-	weeklyAvgDurations := make([]float64, len(weeksWithDurations))
-	for i, w := range weeksWithDurations {
-		durations := weekDurations[w]
-		if len(durations) > 0 {
-			var sum float64
-			for _, d := range durations {
-				sum += d
-			}
-			weeklyAvgDurations[i] = sum / float64(len(durations))
-		}
-	}
+	logf(ctx, "BuildKite", "Total builds fetched from %s: %d (%d pages)", pipeline, len(combined), pages)
+	return combined, more, nil
+}
 
-	weeksMap := make(map[string]struct{})
-	for w := range weekPassed {
-		weeksMap[w] = struct{}{}
+// fetchBuildsParallel fetches builds from the given pipelines concurrently, returning each pipeline's outcome.
+// Requests share buildkiteThrottle, so pipelines and pages together never exceed the rate limit or in-flight cap.
+func fetchBuildsParallel(ctx context.Context, token, org string, pipelines []string, createdFrom time.Time) ([]BuildkiteBuild, []buildkitePipelineStatus, error) {
+
+	type pipelineResult struct {
+		pipeline  string
+		builds    []BuildkiteBuild
+		truncated bool
+		err       error
+	}
+
+	results := make([]pipelineResult, len(pipelines))
+	tasks := make([]func() error, len(pipelines))
+	for i, pipeline := range pipelines {
+		i, pipeline := i, pipeline
+		tasks[i] = func() error {
+			builds, truncated, err := fetchBuildsFromPipeline(ctx, token, org, pipeline, createdFrom)
+			results[i] = pipelineResult{pipeline: pipeline, builds: builds, truncated: truncated, err: err}
+			return err
+		}
 	}
-	for w := range weekFailed {
-		weeksMap[w] = struct{}{}
+	// every pipeline at once; buildkiteThrottle is what caps in-flight requests
+	if err := runBounded(ctx, tasks, len(pipelines)); err != nil && ctx.Err() != nil {
+		return nil, nil, ctx.Err()
 	}
 
-	var weeksForFailureRate []string
-	for w := range weeksMap {
-		weeksForFailureRate = append(weeksForFailureRate, w)
+	var allBuilds []BuildkiteBuild
+	statuses := make([]buildkitePipelineStatus, 0, len(pipelines))
+	var lastErr error
+	failed := 0
+	for _, res := range results {
+		statuses = append(statuses, newPipelineStatus(ctx, org, res.pipeline, res.builds, res.truncated, res.err))
+		if res.err != nil {
+			lastErr = res.err
+			failed++
+			continue // Continue with other pipelines even if one fails
+		}
+		allBuilds = append(allBuilds, res.builds...)
+	}
+	if failed == len(pipelines) {
+		// Nothing came back; report the failure so callers don't mistake it for zero deployments
+		return nil, statuses, fmt.Errorf("all %d pipelines failed: %w", failed, lastErr)
 	}
-	sort.Strings(weeksForFailureRate)
-
-	weeklyFailureRates := make([]float64, len(weeksForFailureRate))
-	weeklyPassedCounts := make([]int, len(weeksForFailureRate))
-	weeklyFailedCounts := make([]int, len(weeksForFailureRate))
-
-	for i, w := range weeksForFailureRate {
-		passed := weekPassed[w]
-		failed := weekFailed[w]
-		total := passed + failed
 
-		weeklyPassedCounts[i] = passed
-		weeklyFailedCounts[i] = failed
+	allBuilds = dedupeBuilds(allBuilds)
+	logf(ctx, "BuildKite", "Total builds fetched from all pipelines: %d", len(allBuilds))
+	return allBuilds, statuses, nil
+}
 
-		if total > 0 {
-			weeklyFailureRates[i] = float64(failed) / float64(total) * 100
+// dedupeBuilds drops repeated builds (same org, pipeline and number), keeping the first occurrence.
+// Overlapping pages can return the same build twice when new builds shift pagination mid-fetch.
+func dedupeBuilds(builds []BuildkiteBuild) []BuildkiteBuild {
+	seen := make(map[string]struct{}, len(builds))
+	out := builds[:0:0]
+	for _, b := range builds {
+		key := fmt.Sprintf("%s/%s#%d", b.Org, b.Pipeline.Slug, b.Number)
+		if _, dup := seen[key]; dup {
+			continue
 		}
+		seen[key] = struct{}{}
+		out = append(out, b)
 	}
+	return out
+}
 
-	// Calculate daily metrics
-	var daysWithDurations []string
-	for d := range dayDurations {
-		daysWithDurations = append(daysWithDurations, d)
+// deployOverlayForWeeks counts passed production deploys per bucket (weekKey or monthKey), aligned to weeks, for
+// overlaying on other KPIs. It reuses the cached 3-month BuildKite fetch, so buckets before that window are
+// returned as null rather than 0.
+func deployOverlayForWeeks(c *gin.Context, weeks []string, bucket func(time.Time) string) ([]*int, gin.H, error) {
+	token, org, ok := buildkiteConfig()
+	if !ok {
+		return nil, nil, fmt.Errorf("BuildKite not configured (missing %s)", strings.Join(buildkiteConfigMissing(), ", "))
+	}
+	windowStart := requestNow(c).AddDate(0, -3, 0)
+	builds, cacheStatus, err := getCachedBuilds(c.Request.Context(), token, []string{org}, buildkiteDeploymentPipelines, windowStart)
+	if err != nil {
+		return nil, nil, err
 	}
-	sort.Strings(daysWithDurations)
 
-	dailyAvgDurations := make([]float64, len(daysWithDurations))
-	for i, d := range daysWithDurations {
-		durations := dayDurations[d]
-		if len(durations) > 0 {
-			var sum float64
-			for _, dur := range durations {
-				sum += dur
-			}
-			dailyAvgDurations[i] = sum / float64(len(durations))
+	perWeek := make(map[string]int)
+	for _, build := range builds {
+		if !isDeploymentPipeline(build) || !defaultBranchFilter.matches(build.Branch) || build.State != "passed" {
+			continue
+		}
+		if finishedAt, ok := parseTime(build.FinishedAt); ok {
+			perWeek[bucket(finishedAt)]++
 		}
 	}
 
-	daysMap := make(map[string]struct{})
-	for d := range dayPassed {
-		daysMap[d] = struct{}{}
+	firstWeek := bucket(windowStart)
+	counts := make([]*int, len(weeks))
+	for i, w := range weeks {
+		if w < firstWeek {
+			continue // outside the BuildKite window: unknown, not zero
+		}
+		n := perWeek[w]
+		counts[i] = &n
+	}
+	meta := gin.H{
+		"source":        cacheStatus.source(),
+		"provider":      "buildkite",
+		"org":           org,
+		"window_start":  windowStart.Format("2006-01-02"),
+		"definition":    "passed deployment-pipeline builds per week (by finish time); null = week outside the BuildKite window",
+		"cache_age_sec": int(cacheStatus.Age.Seconds()),
+	}
+	addBuildkitePipelines(meta, cacheStatus.Pipelines)
+	return counts, meta, nil
+}
+
+// kpiBuildkiteCombinedAll returns both weekly and daily metrics in a single request
+func kpiBuildkiteCombinedAll(c *gin.Context) {
+	token, _, ok := buildkiteConfig()
+	if !ok {
+		missing := buildkiteConfigMissing()
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error":   "BuildKite not configured",
+			"missing": missing,
+			"hint":    "Set BUILDKITE_TOKEN and BUILDKITE_ORG in .env",
+		})
+		return
 	}
-	for d := range dayFailed {
-		daysMap[d] = struct{}{}
+	orgs, err := selectBuildkiteOrgs(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
-
-	var daysForFailureRate []string
-	for d := range daysMap {
-		daysForFailureRate = append(daysForFailureRate, d)
+	opts, err := buildkiteAggOptionsFromQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	granularity, err := parseGranularity(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
-	sort.Strings(daysForFailureRate)
-
-	dailyFailureRates := make([]float64, len(daysForFailureRate))
-	dailyPassedCounts := make([]int, len(daysForFailureRate))
-	dailyFailedCounts := make([]int, len(daysForFailureRate))
-
-	for i, d := range daysForFailureRate {
-		passed := dayPassed[d]
-		failed := dayFailed[d]
-		total := passed + failed
 
-		dailyPassedCounts[i] = passed
-		dailyFailedCounts[i] = failed
+	// Fetch builds from last 3 months (fetch once, use for both weekly and daily)
+	now := requestNow(c) // read once so the fetch window and the filled buckets agree
+	threeMonthsAgo := now.AddDate(0, -3, 0)
+	thirtyDaysAgo := now.AddDate(0, 0, -30)
+	startTime := time.Now()
 
-		if total > 0 {
-			dailyFailureRates[i] = float64(failed) / float64(total) * 100
-		}
+	builds, cacheStatus, err := getCachedBuilds(c.Request.Context(), token, orgs, buildkiteDeploymentPipelines, threeMonthsAgo)
+	if errors.Is(err, errBuildkiteCacheTooOld) {
+		c.Header("Age", fmt.Sprintf("%d", int(cacheStatus.Age.Seconds())))
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error":           err.Error(),
+			"detail":          cacheStatus.RefreshErr.Error(),
+			"upstream_status": upstreamStatus(cacheStatus.RefreshErr),
+			"cache_age_sec":   int(cacheStatus.Age.Seconds()),
+			"max_age_sec":     int(buildkiteCacheMaxAge.Seconds()),
+		})
+		return
 	}
+	if err != nil {
+		c.JSON(upstreamFailureStatus(err), upstreamErrorBody("Failed to fetch builds: ", err))
+		return
+	}
+	c.Header("Age", fmt.Sprintf("%d", int(cacheStatus.Age.Seconds())))
 
-	log.Printf("[BuildKite Combined] Processed in %v total (weekly: %d builds, daily: %d builds)",
-		time.Since(startTime), weeklyDeploymentCount, dailyDeploymentCount)
-
-	c.JSON(http.StatusOK, gin.H{
-		"weekly": gin.H{
-			"deployment_time": gin.H{
-				"weeks":             weeksWithDurations,
-				"avg_duration_mins": weeklyAvgDurations,
-			},
-			"failure_rate": gin.H{
-				"weeks":        weeksForFailureRate,
-				"failure_rate": weeklyFailureRates,
-				"passed":       weeklyPassedCounts,
-				"failed":       weeklyFailedCounts,
-			},
+	fetchDuration := time.Since(startTime)
+	logf(c.Request.Context(), "BuildKite Combined", "Processing %d builds", len(builds))
+
+	includeFailures := c.Query("include_failures") == "1" || c.Query("include_failures") == "true"
+
+	// Weekly (or ?granularity=month) metrics over the full window; daily metrics over the last 30 days only
+	weeklyOpts, dailyOpts := opts, opts
+	weeklyOpts.Bucket = granularity.key
+	dailyOpts.Bucket = dayKey
+	dailyOpts.Include = func(at time.Time) bool { return at.After(thirtyDaysAgo) }
+	weekly := aggregateBuildkite(builds, weeklyOpts)
+	daily := aggregateBuildkite(builds, dailyOpts)
+	fillGaps := parseFillGaps(c)
+	if fillGaps {
+		weekly.fillGaps(granularity.keys(threeMonthsAgo, now))
+		daily.fillGaps(bucketGranularity(granularityDay).keys(thirtyDaysAgo, now))
+	}
+
+	logf(c.Request.Context(), "BuildKite Combined", "Processed in %v total (weekly: %d builds, daily: %d builds)",
+		time.Since(startTime), weekly.Deployments, daily.Deployments)
+
+	meta := gin.H{
+		"source":             cacheStatus.source(),
+		"total_builds":       len(builds),
+		"weekly_deployments": weekly.Deployments,
+		"daily_deployments":  daily.Deployments,
+		"date_range":         fmt.Sprintf("last 3 months (from %s)", threeMonthsAgo.Format("2006-01-02")),
+		"fetch_duration_sec": fetchDuration.Seconds(),
+		"cached":             fetchDuration.Seconds() < 0.1,
+		"cache_age_sec":      int(cacheStatus.Age.Seconds()),
+		"stale":              cacheStatus.Stale,
+		"org":                strings.Join(orgs, ","),
+		"by_org":             buildkiteDeploymentsByOrg(builds, opts.Branches),
+		"bucket_by":          opts.BucketBy,
+		"duration_basis":     opts.DurationBasis,
+		"branch_patterns":    opts.Branches.patterns(),
+		"bucket_timezone":    bucketLocation.String(),
+		"granularity":        granularity,
+		"fill_gaps":          fillGaps,
+		"target_status":      kpiTargetMeta("deployment_failure_rate", weekly.FailureRates),
+		"success_streak":     weekly.streakJSON(),
+	}
+	addCompleteness(meta, completenessSignal{Name: "deployments_with_timestamps", Expected: weekly.TerminalCount, Got: weekly.TerminalTimed})
+	weekly.addExclusionMeta(meta, opts)
+	weekly.addFailureAccountingMeta(meta, opts)
+	addBuildkitePipelines(meta, cacheStatus.Pipelines)
+	addBuildkitePartial(c, meta)
+	if cacheStatus.Stale {
+		meta["warning"] = fmt.Sprintf("BuildKite refresh failed; showing cached data from %d minutes ago", int(cacheStatus.Age.Minutes()))
+		meta["refresh_error"] = cacheStatus.RefreshErr.Error()
+	}
+	// The full-window section is "weekly", or "monthly" keyed by months with ?granularity=month
+	resp := gin.H{
+		granularity.adjective(): gin.H{
+			"deployment_time": weekly.deploymentTimeJSON(granularity.plural()),
+			"failure_rate":    weekly.failureRateJSON(granularity.plural()),
+			"frequency":       weekly.frequencyJSON(granularity.plural()),
 		},
 		"daily": gin.H{
-			"deployment_time": gin.H{
-				"days":              daysWithDurations,
-				"avg_duration_mins": dailyAvgDurations,
-			},
-			"failure_rate": gin.H{
-				"days":         daysForFailureRate,
-				"failure_rate": dailyFailureRates,
-				"passed":       dailyPassedCounts,
-				"failed":       dailyFailedCounts,
-			},
+			"deployment_time": daily.deploymentTimeJSON("days"),
+			"failure_rate":    daily.failureRateJSON("days"),
+			"frequency":       daily.frequencyJSON("days"),
 		},
-		"meta": gin.H{
-			"total_builds":         len(builds),
-			"weekly_deployments":   weeklyDeploymentCount,
-			"daily_deployments":    dailyDeploymentCount,
-			"date_range":           fmt.Sprintf("last 3 months (from %s)", threeMonthsAgo.Format("2006-01-02")),
-			"fetch_duration_sec":   fetchDuration.Seconds(),
-			"cached":               fetchDuration.Seconds() < 0.1,
-			"org":                  org,
-		},
-	})
+		"meta": meta,
+	}
+	// Optional: the same window pooled by day of week (e.g. are Friday deploys riskier?)
+	if opts.ByWeekday {
+		weekdayOpts := opts
+		weekdayOpts.Bucket = weekdayKey
+		resp["weekday"] = aggregateBuildkite(builds, weekdayOpts).weekdayJSON()
+	}
+	if includeFailures {
+		failures := []gin.H{}
+		for _, build := range weekly.FailedBuilds {
+			failures = append(failures, buildkiteFailureEntry(build.Org, build))
+		}
+		resp["failures"] = failures
+	}
+	jsonWithETag(c, resp, "fetch_duration_sec", "cached", "cache_age_sec", "source", "warning")
 }
 
 // kpiBuildkiteCombined returns both deployment time and failure rate in a single request (weekly only - DEPRECATED, use kpiBuildkiteCombinedAll)
 func kpiBuildkiteCombined(c *gin.Context) {
-	token, org, ok := buildkiteConfig()
+	token, _, ok := buildkiteConfig()
 	if !ok {
 		missing := buildkiteConfigMissing()
 		c.JSON(http.StatusServiceUnavailable, gin.H{
@@ -454,145 +580,86 @@ func kpiBuildkiteCombined(c *gin.Context) {
 		})
 		return
 	}
+	orgs, err := selectBuildkiteOrgs(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	opts, err := buildkiteAggOptionsFromQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	granularity, err := parseGranularity(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	// Fetch builds from last 3 months (only once!)
-	threeMonthsAgo := time.Now().AddDate(0, -3, 0)
+	now := requestNow(c)
+	threeMonthsAgo := now.AddDate(0, -3, 0)
 	startTime := time.Now()
 
-	builds, err := fetchBuildsParallel(c, token, org, threeMonthsAgo)
+	builds, pipelineStatuses, err := fetchBuildsAcrossOrgsBeforeNow(c.Request.Context(), orgs, func(org string) ([]BuildkiteBuild, []buildkitePipelineStatus, error) {
+		return fetchBuildsParallel(c.Request.Context(), token, org, buildkiteDeploymentPipelines, threeMonthsAgo)
+	})
 	if err != nil {
-		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to fetch builds: " + err.Error()})
+		c.JSON(upstreamFailureStatus(err), upstreamErrorBody("Failed to fetch builds: ", err))
 		return
 	}
 
 	fetchDuration := time.Since(startTime)
-	log.Printf("[BuildKite] Fetched %d builds in %v", len(builds), fetchDuration)
+	logf(c.Request.Context(), "BuildKite", "Fetched %d builds in %v", len(builds), fetchDuration)
 
 	// Process data for both metrics simultaneously
-	weekDurations := make(map[string][]float64)
-	weekPassed := make(map[string]int)
-	weekFailed := make(map[string]int)
-	deploymentCount := 0
-	passedCount := 0
-	failedCount := 0
-
-	for _, build := range builds {
-		if !isDeploymentPipeline(build) {
-			continue
-		}
-
-		finishedAt, okFinish := parseTime(build.FinishedAt)
-		if !okFinish {
-			continue
-		}
-
-		week := weekKey(finishedAt)
-		deploymentCount++
-
-		// For deployment time: only count passed builds
-		if build.State == "passed" {
-			startedAt, okStart := parseTime(build.StartedAt)
-			if okStart && finishedAt.After(startedAt) {
-				durationMinutes := finishedAt.Sub(startedAt).Minutes()
-				weekDurations[week] = append(weekDurations[week], durationMinutes)
-			}
-			weekPassed[week]++
-			passedCount++
-		}
-
-		// For failure rate: count passed and failed
-		if build.State == "failed" {
-			weekFailed[week]++
-			failedCount++
-		}
-	}
-
-	// Calculate metrics - separate weeks for deployment time (only successful) vs failure rate (all)
-	// For deployment time: only include weeks with successful deployments
-	var weeksWithDurations []string
-	for w := range weekDurations {
-		weeksWithDurations = append(weeksWithDurations, w)
-	}
-	sort.Strings(weeksWithDurations)
-
-	avgDurations := make([]float64, len(weeksWithDurations))
-	for i, w := range weeksWithDurations {
-		durations := weekDurations[w]
-		if len(durations) > 0 {
-			var sum float64
-			for _, d := range durations {
-				sum += d
-			}
-			avgDurations[i] = sum / float64(len(durations))
-		}
-	}
-
-	// For failure rate: include all weeks with any deployments
-	weeksMap := make(map[string]struct{})
-	for w := range weekPassed {
-		weeksMap[w] = struct{}{}
-	}
-	for w := range weekFailed {
-		weeksMap[w] = struct{}{}
-	}
-
-	var weeksForFailureRate []string
-	for w := range weeksMap {
-		weeksForFailureRate = append(weeksForFailureRate, w)
-	}
-	sort.Strings(weeksForFailureRate)
-
-	failureRates := make([]float64, len(weeksForFailureRate))
-	passedCounts := make([]int, len(weeksForFailureRate))
-	failedCounts := make([]int, len(weeksForFailureRate))
-
-	for i, w := range weeksForFailureRate {
-		passed := weekPassed[w]
-		failed := weekFailed[w]
-		total := passed + failed
-
-		passedCounts[i] = passed
-		failedCounts[i] = failed
-
-		if total > 0 {
-			failureRates[i] = float64(failed) / float64(total) * 100
-		}
-	}
-
-	log.Printf("[BuildKite] Processed %d deployment builds (%d passed, %d failed) in %v total",
-		deploymentCount, passedCount, failedCount, time.Since(startTime))
-
+	opts.Bucket = granularity.key
+	m := aggregateBuildkite(builds, opts)
+	fillGaps := parseFillGaps(c)
+	if fillGaps {
+		m.fillGaps(granularity.keys(threeMonthsAgo, now))
+	}
+
+	logf(c.Request.Context(), "BuildKite", "Processed %d deployment builds (%d passed, %d failed) in %v total",
+		m.Deployments, m.PassedCount, m.FailedCount, time.Since(startTime))
+
+	meta := gin.H{
+		"source":             sourceLive,
+		"total_builds":       len(builds),
+		"deployment_builds":  m.Deployments,
+		"passed_builds":      m.PassedCount,
+		"failed_builds":      m.FailedCount,
+		"date_range":         fmt.Sprintf("last 3 months (from %s)", threeMonthsAgo.Format("2006-01-02")),
+		"fetch_duration_sec": fetchDuration.Seconds(),
+		"org":                strings.Join(orgs, ","),
+		"by_org":             buildkiteDeploymentsByOrg(builds, opts.Branches),
+		"bucket_by":          opts.BucketBy,
+		"duration_basis":     opts.DurationBasis,
+		"branch_patterns":    opts.Branches.patterns(),
+		"bucket_timezone":    bucketLocation.String(),
+		"fill_gaps":          fillGaps,
+		"granularity":        granularity,
+		"success_streak":     m.streakJSON(),
+	}
+	addBuildkitePipelines(meta, pipelineStatuses)
+	m.addFailureAccountingMeta(meta, opts)
+	addBuildkitePartial(c, meta)
 	c.JSON(http.StatusOK, gin.H{
-		"deployment_time": gin.H{
-			"weeks":             weeksWithDurations,
-			"avg_duration_mins": avgDurations,
-		},
-		"failure_rate": gin.H{
-			"weeks":        weeksForFailureRate,
-			"failure_rate": failureRates,
-			"passed":       passedCounts,
-			"failed":       failedCounts,
-		},
-		"meta": gin.H{
-			"total_builds":       len(builds),
-			"deployment_builds":  deploymentCount,
-			"passed_builds":      passedCount,
-			"failed_builds":      failedCount,
-			"date_range":         fmt.Sprintf("last 3 months (from %s)", threeMonthsAgo.Format("2006-01-02")),
-			"fetch_duration_sec": fetchDuration.Seconds(),
-			"org":                org,
-		},
+		"deployment_time": m.deploymentTimeJSON(granularity.plural()),
+		"failure_rate":    m.failureRateJSON(granularity.plural()),
+		"frequency":       m.frequencyJSON(granularity.plural()),
+		"meta":            meta,
 	})
 }
 
-// dayKey returns YYYY-MM-DD for a given time
+// dayKey returns YYYY-MM-DD for a given time in bucketLocation
 func dayKey(t time.Time) string {
-	return t.Format("2006-01-02")
+	return bucketTime(t).Format("2006-01-02")
 }
 
 // kpiBuildkiteCombinedDaily returns daily deployment time and failure rate for last 30 days
 func kpiBuildkiteCombinedDaily(c *gin.Context) {
-	token, org, ok := buildkiteConfig()
+	token, _, ok := buildkiteConfig()
 	if !ok {
 		missing := buildkiteConfigMissing()
 		c.JSON(http.StatusServiceUnavailable, gin.H{
@@ -602,133 +669,67 @@ func kpiBuildkiteCombinedDaily(c *gin.Context) {
 		})
 		return
 	}
+	orgs, err := selectBuildkiteOrgs(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	opts, err := buildkiteAggOptionsFromQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	// Fetch builds from last 30 days
-	thirtyDaysAgo := time.Now().AddDate(0, 0, -30)
+	now := requestNow(c)
+	thirtyDaysAgo := now.AddDate(0, 0, -30)
 	startTime := time.Now()
 
-	builds, err := fetchBuildsParallel(c, token, org, thirtyDaysAgo)
+	builds, pipelineStatuses, err := fetchBuildsAcrossOrgsBeforeNow(c.Request.Context(), orgs, func(org string) ([]BuildkiteBuild, []buildkitePipelineStatus, error) {
+		return fetchBuildsParallel(c.Request.Context(), token, org, buildkiteDeploymentPipelines, thirtyDaysAgo)
+	})
 	if err != nil {
-		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to fetch builds: " + err.Error()})
+		c.JSON(upstreamFailureStatus(err), upstreamErrorBody("Failed to fetch builds: ", err))
 		return
 	}
 
 	fetchDuration := time.Since(startTime)
-	log.Printf("[BuildKite Daily] Fetched %d builds in %v", len(builds), fetchDuration)
+	logf(c.Request.Context(), "BuildKite Daily", "Fetched %d builds in %v", len(builds), fetchDuration)
 
 	// Process data for both metrics by day
-	dayDurations := make(map[string][]float64)
-	dayPassed := make(map[string]int)
-	dayFailed := make(map[string]int)
-	deploymentCount := 0
-	passedCount := 0
-	failedCount := 0
-
-	for _, build := range builds {
-		if !isDeploymentPipeline(build) {
-			continue
-		}
-
-		finishedAt, okFinish := parseTime(build.FinishedAt)
-		if !okFinish {
-			continue
-		}
-
-		day := dayKey(finishedAt)
-		deploymentCount++
-
-		// For deployment time: only count passed builds
-		if build.State == "passed" {
-			startedAt, okStart := parseTime(build.StartedAt)
-			if okStart && finishedAt.After(startedAt) {
-				durationMinutes := finishedAt.Sub(startedAt).Minutes()
-				dayDurations[day] = append(dayDurations[day], durationMinutes)
-			}
-			dayPassed[day]++
-			passedCount++
-		}
-
-		// For failure rate: count passed and failed
-		if build.State == "failed" {
-			dayFailed[day]++
-			failedCount++
-		}
-	}
-
-	// Calculate metrics - separate days for deployment time (only successful) vs failure rate (all)
-	// For deployment time: only include days with successful deployments
-	var daysWithDurations []string
-	for d := range dayDurations {
-		daysWithDurations = append(daysWithDurations, d)
-	}
-	sort.Strings(daysWithDurations)
-
-	avgDurations := make([]float64, len(daysWithDurations))
-	for i, d := range daysWithDurations {
-		durations := dayDurations[d]
-		if len(durations) > 0 {
-			var sum float64
-			for _, dur := range durations {
-				sum += dur
-			}
-			avgDurations[i] = sum / float64(len(durations))
-		}
-	}
-
-	// For failure rate: include all days with any deployments
-	daysMap := make(map[string]struct{})
-	for d := range dayPassed {
-		daysMap[d] = struct{}{}
-	}
-	for d := range dayFailed {
-		daysMap[d] = struct{}{}
-	}
-
-	var daysForFailureRate []string
-	for d := range daysMap {
-		daysForFailureRate = append(daysForFailureRate, d)
-	}
-	sort.Strings(daysForFailureRate)
-
-	failureRates := make([]float64, len(daysForFailureRate))
-	passedCounts := make([]int, len(daysForFailureRate))
-	failedCounts := make([]int, len(daysForFailureRate))
-
-	for i, d := range daysForFailureRate {
-		passed := dayPassed[d]
-		failed := dayFailed[d]
-		total := passed + failed
-
-		passedCounts[i] = passed
-		failedCounts[i] = failed
-
-		if total > 0 {
-			failureRates[i] = float64(failed) / float64(total) * 100
-		}
-	}
-
-	log.Printf("[BuildKite Daily] Processed %d deployment builds (%d passed, %d failed) in %v total",
-		deploymentCount, passedCount, failedCount, time.Since(startTime))
-
+	opts.Bucket = dayKey
+	m := aggregateBuildkite(builds, opts)
+	fillGaps := parseFillGaps(c)
+	if fillGaps {
+		m.fillGaps(bucketGranularity(granularityDay).keys(thirtyDaysAgo, now))
+	}
+
+	logf(c.Request.Context(), "BuildKite Daily", "Processed %d deployment builds (%d passed, %d failed) in %v total",
+		m.Deployments, m.PassedCount, m.FailedCount, time.Since(startTime))
+
+	meta := gin.H{
+		"source":             sourceLive,
+		"total_builds":       len(builds),
+		"deployment_builds":  m.Deployments,
+		"passed_builds":      m.PassedCount,
+		"failed_builds":      m.FailedCount,
+		"date_range":         fmt.Sprintf("last 30 days (from %s)", thirtyDaysAgo.Format("2006-01-02")),
+		"fetch_duration_sec": fetchDuration.Seconds(),
+		"org":                strings.Join(orgs, ","),
+		"by_org":             buildkiteDeploymentsByOrg(builds, opts.Branches),
+		"bucket_by":          opts.BucketBy,
+		"duration_basis":     opts.DurationBasis,
+		"branch_patterns":    opts.Branches.patterns(),
+		"bucket_timezone":    bucketLocation.String(),
+		"fill_gaps":          fillGaps,
+	}
+	addBuildkitePipelines(meta, pipelineStatuses)
+	m.addFailureAccountingMeta(meta, opts)
+	addBuildkitePartial(c, meta)
 	c.JSON(http.StatusOK, gin.H{
-		"deployment_time": gin.H{
-			"days":              daysWithDurations,
-			"avg_duration_mins": avgDurations,
-		},
-		"failure_rate": gin.H{
-			"days":         daysForFailureRate,
-			"failure_rate": failureRates,
-			"passed":       passedCounts,
-			"failed":       failedCounts,
-		},
-		"meta": gin.H{
-			"total_builds":       len(builds),
-			"deployment_builds":  deploymentCount,
-			"passed_builds":      passedCount,
-			"failed_builds":      failedCount,
-			"date_range":         fmt.Sprintf("last 30 days (from %s)", thirtyDaysAgo.Format("2006-01-02")),
-			"fetch_duration_sec": fetchDuration.Seconds(),
-			"org":                org,
-		},
+		"deployment_time": m.deploymentTimeJSON("days"),
+		"failure_rate":    m.failureRateJSON("days"),
+		"frequency":       m.frequencyJSON("days"),
+		"meta":            meta,
 	})
 }
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFetchBuildsParallelFetchesPipelinesConcurrentlyAndDedupes(t *testing.T) {
+	setForTest(t, &buildkiteMaxAttempts, 1)
+	// Each good pipeline's page 1 waits until all three have been requested, so a serial fetch times out
+	var mu sync.Mutex
+	arrived, allArrived := 0, make(chan struct{})
+	stubUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(r.URL.Path, "/") // /v2/organizations/org/pipelines/<slug>/builds
+		slug := parts[len(parts)-2]
+		if slug == "broken" {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		mu.Lock()
+		if arrived++; arrived == 3 {
+			close(allArrived)
+		}
+		mu.Unlock()
+		select {
+		case <-allArrived:
+		case <-time.After(5 * time.Second):
+			http.Error(w, "pipelines were not fetched concurrently", http.StatusInternalServerError)
+			return
+		}
+		// "alpha" repeats build 2, as overlapping pages do when new builds shift pagination mid-fetch
+		numbers := map[string][]int{"alpha": {1, 2, 2}, "beta": {1}, "gamma": {5, 6}}[slug]
+		var builds []string
+		for _, n := range numbers {
+			builds = append(builds, fmt.Sprintf(`{"number": %d, "state": "passed", "pipeline": {"slug": %q}}`, n, slug))
+		}
+		fmt.Fprintf(w, "[%s]", strings.Join(builds, ","))
+	})
+
+	ctx := withRequestValues(context.Background())
+	builds, statuses, err := fetchBuildsParallel(ctx, "token", "org", []string{"alpha", "beta", "gamma", "broken"}, time.Now().AddDate(0, -3, 0))
+	if err != nil {
+		t.Fatalf("fetchBuildsParallel: %v", err)
+	}
+
+	var got []string
+	for _, b := range builds {
+		got = append(got, fmt.Sprintf("%s#%d", b.Pipeline.Slug, b.Number))
+	}
+	sort.Strings(got)
+	want := []string{"alpha#1", "alpha#2", "beta#1", "gamma#5", "gamma#6"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("builds = %v, want %v", got, want)
+	}
+
+	if len(statuses) != 4 {
+		t.Fatalf("statuses = %+v, want one per pipeline", statuses)
+	}
+	for _, s := range statuses {
+		if wantOK := s.Pipeline != "broken"; s.OK != wantOK {
+			t.Errorf("%s: ok = %v (error %q), want %v", s.Pipeline, s.OK, s.Error, wantOK)
+		}
+	}
+	if statuses[0].BuildCount != 3 {
+		t.Errorf("alpha build_count = %d, want 3 (counted before dedupe)", statuses[0].BuildCount)
+	}
+}
+
+func TestFetchBuildsParallelFailsWhenEveryPipelineFails(t *testing.T) {
+	setForTest(t, &buildkiteMaxAttempts, 1)
+	stubUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+	})
+
+	_, statuses, err := fetchBuildsParallel(withRequestValues(context.Background()), "token", "org", []string{"a", "b"}, time.Now())
+	if err == nil || !strings.Contains(err.Error(), "all 2 pipelines failed") {
+		t.Fatalf("err = %v, want all pipelines failed", err)
+	}
+	if len(statuses) != 2 || statuses[0].OK || statuses[1].OK {
+		t.Errorf("statuses = %+v, want both failed", statuses)
+	}
+}
+
+func TestBuildkiteCacheRefreshKeepsCacheUntilFetchSucceeds(t *testing.T) {
+	t.Setenv("ENV", "")
+	t.Setenv("DASHBOARD_API_KEY", "secret")
+	t.Setenv("BUILDKITE_TOKEN", "token")
+	t.Setenv("BUILDKITE_ORG", "org")
+	setForTest(t, &buildkiteMaxAttempts, 1)
+	setForTest(t, &buildkiteCache, newTTLCache[string, cachedBuilds](time.Minute, time.Hour))
+	now := time.Date(2024, 5, 15, 12, 0, 0, 0, time.UTC)
+	pinNow(t, now)
+	key := buildkiteCacheKey([]string{"org"}, buildkiteDeploymentPipelines, now.AddDate(0, -3, 0))
+	buildkiteCache.Set(key, cachedBuilds{builds: []BuildkiteBuild{{Number: 1}}})
+
+	fail := true
+	calls := stubUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		if fail {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, `[{"number": 2, "state": "passed"}]`)
+	})
+	refresh := func(apiKey string) int {
+		c, rec := testContext("/api/buildkite/cache/refresh")
+		c.Request.Method = http.MethodPost
+		c.Request.Header.Set("X-API-Key", apiKey)
+		c.Request = c.Request.WithContext(withRequestValues(c.Request.Context()))
+		buildkiteCacheRefresh(c)
+		return rec.Code
+	}
+	cachedNumber := func() int {
+		cached, _, ok := buildkiteCache.Peek(key)
+		if !ok || len(cached.builds) == 0 {
+			return 0
+		}
+		return cached.builds[0].Number
+	}
+
+	if code := refresh("wrong"); code != http.StatusForbidden || calls.Load() != 0 {
+		t.Fatalf("without the API key: status %d after %d calls, want 403 before any fetch", code, calls.Load())
+	}
+	if code := refresh("secret"); code != http.StatusBadGateway || cachedNumber() != 1 {
+		t.Fatalf("failed refresh: status %d, cached build %d; want 502 and the old entry kept", code, cachedNumber())
+	}
+	fail = false
+	if code := refresh("secret"); code != http.StatusOK || cachedNumber() != 2 {
+		t.Fatalf("refresh: status %d, cached build %d; want 200 and the entry replaced", code, cachedNumber())
+	}
+}
+
+func TestCachedBuildsSource(t *testing.T) {
+	setForTest(t, &buildkiteMaxAttempts, 1)
+	setForTest(t, &buildkiteCacheTTL, time.Millisecond)
+	setForTest(t, &buildkiteCacheMaxAge, time.Hour)
+	setForTest(t, &buildkiteCache, newTTLCache[string, cachedBuilds](buildkiteCacheTTL, buildkiteCacheMaxAge))
+	fail := false
+	stubUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		if fail {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, `[{"number": 1, "state": "passed"}]`)
+	})
+	from := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	fetch := func() string {
+		_, status, err := cachedBuildsSince(withRequestValues(context.Background()), "token", []string{"org"}, []string{"pipe"}, from)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return status.source()
+	}
+
+	if got := fetch(); got != sourceLive {
+		t.Errorf("first fetch source = %s, want %s", got, sourceLive)
+	}
+	setForTest(t, &buildkiteCacheTTL, time.Hour)
+	if got := fetch(); got != sourceCache {
+		t.Errorf("fresh hit source = %s, want %s", got, sourceCache)
+	}
+	setForTest(t, &buildkiteCacheTTL, time.Nanosecond)
+	fail = true
+	if got := fetch(); got != sourceStaleCache {
+		t.Errorf("after a failed refresh source = %s, want %s", got, sourceStaleCache)
+	}
+}
+
+// Handlers that fetch uncached apply the ?now= cutoff too: a build created after the pinned time isn't counted.
+func TestBuildkiteHandlersDropBuildsAfterPinnedNow(t *testing.T) {
+	t.Setenv("ENV", "dev")
+	t.Setenv("BUILDKITE_TOKEN", "token")
+	t.Setenv("BUILDKITE_ORG", "org")
+	setForTest(t, &buildkiteMaxAttempts, 1)
+	stubUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(r.URL.Path, "/") // /v2/organizations/org/pipelines/<slug>/builds
+		slug := parts[len(parts)-2]
+		fmt.Fprintf(w, `[
+			{"number": 1, "state": "passed", "pipeline": {"slug": %[1]q}, "created_at": "2024-05-10T10:00:00Z", "started_at": "2024-05-10T10:00:00Z", "finished_at": "2024-05-10T10:05:00Z"},
+			{"number": 2, "state": "passed", "pipeline": {"slug": %[1]q}, "created_at": "2024-05-20T10:00:00Z", "started_at": "2024-05-20T10:00:00Z", "finished_at": "2024-05-20T10:05:00Z"}
+		]`, slug)
+	})
+
+	for _, path := range []string{"/api/kpi/buildkite-deployment-time", "/api/kpi/buildkite-combined-daily"} {
+		rec := httptest.NewRecorder()
+		newRouter().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path+"?now=2024-05-15T00:00:00Z", nil))
+
+		var body struct {
+			Meta struct {
+				TotalBuilds int `json:"total_builds"`
+			} `json:"meta"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || rec.Code != http.StatusOK {
+			t.Fatalf("%s: status %d: %s", path, rec.Code, rec.Body.String())
+		}
+		if want := len(buildkiteDeploymentPipelines); body.Meta.TotalBuilds != want {
+			t.Errorf("%s: total_builds = %d, want %d (one build per pipeline before the pinned time)", path, body.Meta.TotalBuilds, want)
+		}
+	}
+}
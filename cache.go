@@ -0,0 +1,97 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// ttlCache is a small in-memory cache whose entries are fresh for ttl. Expired entries stay readable through
+// Peek (so callers can serve stale data when a refresh fails) until they are older than keep, when Set prunes them.
+// With a limit, Set also evicts the oldest entries so keys built from request input can't grow it without bound.
+type ttlCache[K comparable, V any] struct {
+	mu      sync.RWMutex
+	ttl     time.Duration
+	keep    time.Duration
+	limit   int // max entries; 0 means unbounded
+	entries map[K]ttlEntry[V]
+}
+
+type ttlEntry[V any] struct {
+	value    V
+	storedAt time.Time
+}
+
+// newTTLCache returns a cache with the given freshness ttl, keeping entries for at least keep (and never less than ttl).
+func newTTLCache[K comparable, V any](ttl, keep time.Duration) *ttlCache[K, V] {
+	if keep < ttl {
+		keep = ttl
+	}
+	return &ttlCache[K, V]{ttl: ttl, keep: keep, entries: make(map[K]ttlEntry[V])}
+}
+
+// newBoundedTTLCache is newTTLCache holding at most limit entries.
+func newBoundedTTLCache[K comparable, V any](ttl, keep time.Duration, limit int) *ttlCache[K, V] {
+	c := newTTLCache[K, V](ttl, keep)
+	c.limit = limit
+	return c
+}
+
+// Get returns the value for key if it is still fresh.
+func (c *ttlCache[K, V]) Get(key K) (V, bool) {
+	v, age, ok := c.Peek(key)
+	if !ok || age >= c.ttl {
+		var zero V
+		return zero, false
+	}
+	return v, true
+}
+
+// Peek returns the value for key and its age regardless of freshness.
+func (c *ttlCache[K, V]) Peek(key K) (V, time.Duration, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	e, ok := c.entries[key]
+	if !ok {
+		var zero V
+		return zero, 0, false
+	}
+	return e.value, time.Since(e.storedAt), true
+}
+
+// Set stores value under key, prunes entries older than keep and, at the limit, evicts the oldest.
+func (c *ttlCache[K, V]) Set(key K, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	for k, e := range c.entries {
+		if now.Sub(e.storedAt) > c.keep {
+			delete(c.entries, k)
+		}
+	}
+	delete(c.entries, key)
+	for c.limit > 0 && len(c.entries) >= c.limit {
+		var oldest K
+		var oldestAt time.Time
+		for k, e := range c.entries {
+			if oldestAt.IsZero() || e.storedAt.Before(oldestAt) {
+				oldest, oldestAt = k, e.storedAt
+			}
+		}
+		delete(c.entries, oldest)
+	}
+	c.entries[key] = ttlEntry[V]{value: value, storedAt: now}
+}
+
+// Clear drops every entry, stale ones included.
+func (c *ttlCache[K, V]) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	clear(c.entries)
+}
+
+// Delete drops key so the next Get misses.
+func (c *ttlCache[K, V]) Delete(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key)
+}
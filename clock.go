@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// nowFunc is the process clock (swap it to pin "now" for every request). Handlers read the time through
+// requestNow, once per request, so a request can pin it and its windows and buckets agree.
+var nowFunc = time.Now
+
+// nowOverrideCtxKey is the request context key holding the pinned time.Time from ?now=.
+type nowOverrideCtxKey struct{}
+
+// requestNow is "now" for this request: the ?now= override when one was accepted, otherwise nowFunc().
+func requestNow(c *gin.Context) time.Time {
+	if t, ok := nowOverride(c.Request.Context()); ok {
+		return t
+	}
+	return nowFunc()
+}
+
+// nowOverride returns the ?now= time carried on ctx, so upstream helpers see it without *gin.Context.
+func nowOverride(ctx context.Context) (time.Time, bool) {
+	t, ok := ctx.Value(nowOverrideCtxKey{}).(time.Time)
+	return t, ok
+}
+
+// apiKeyAllowed reports whether this request may use the privileged knobs (?now=, the BuildKite cache refresh):
+// always in dev (ENV=dev), otherwise only when DASHBOARD_API_KEY is set and the request sends it in X-API-Key.
+func apiKeyAllowed(c *gin.Context) bool {
+	if os.Getenv("ENV") == "dev" {
+		return true
+	}
+	key := strings.TrimSpace(os.Getenv("DASHBOARD_API_KEY"))
+	if key == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(c.GetHeader("X-API-Key")), []byte(key)) == 1
+}
+
+// nowOverrideMiddleware accepts ?now=<RFC 3339> so an export re-run later yields the same windows and buckets.
+//
+// Only the reference time moves: week ranges, "last N months" windows and BuildKite created_from are computed
+// from it, JIRA created windows become absolute dates ending at it, and BuildKite builds created after it are
+// dropped. Upstream state is still read live, so an issue resolved or re-labelled since then reports its
+// current fields, and the BuildKite cache is shared with real-time requests. The accepted time is echoed in
+// the X-Dashboard-Now response header.
+func nowOverrideMiddleware(c *gin.Context) {
+	raw := strings.TrimSpace(c.Query("now"))
+	if raw == "" {
+		c.Next()
+		return
+	}
+	if !apiKeyAllowed(c) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"error": "now override not allowed",
+			"hint":  "?now= is honored with ENV=dev or a matching X-API-Key header (DASHBOARD_API_KEY)",
+		})
+		return
+	}
+	t, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid now %q (want RFC 3339, e.g. 2024-05-10T00:00:00Z)", raw)})
+		return
+	}
+	c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), nowOverrideCtxKey{}, t))
+	c.Header("X-Dashboard-Now", t.Format(time.RFC3339))
+	c.Next()
+}
+
+// buildsBeforeNow drops builds created after the request's ?now= override, if it has one (builds with an
+// unparseable created_at are kept). Every BuildKite read goes through it, cached or not.
+func buildsBeforeNow(ctx context.Context, builds []BuildkiteBuild) []BuildkiteBuild {
+	t, ok := nowOverride(ctx)
+	if !ok {
+		return builds
+	}
+	out := make([]BuildkiteBuild, 0, len(builds))
+	for _, b := range builds {
+		if created, ok := parseTime(b.CreatedAt); ok && created.After(t) {
+			continue
+		}
+		out = append(out, b)
+	}
+	return out
+}
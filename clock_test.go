@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// pinNow fixes nowFunc at t for the rest of the test.
+func pinNow(t *testing.T, now time.Time) {
+	t.Helper()
+	prev := nowFunc
+	nowFunc = func() time.Time { return now }
+	t.Cleanup(func() { nowFunc = prev })
+}
+
+// withBucketLocation sets bucketLocation for the rest of the test.
+func withBucketLocation(t *testing.T, loc *time.Location) {
+	t.Helper()
+	prev := bucketLocation
+	bucketLocation = loc
+	t.Cleanup(func() { bucketLocation = prev })
+}
+
+// testContext is a gin context for a GET of target with a recorder behind it.
+func testContext(target string) (*gin.Context, *httptest.ResponseRecorder) {
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+	c.Request = httptest.NewRequest("GET", target, nil)
+	return c, rec
+}
+
+// isoWeeks lists ISO week keys from..to inclusive within one year.
+func isoWeeks(year, from, to int) []string {
+	var out []string
+	for w := from; w <= to; w++ {
+		out = append(out, weekKey(time.Date(year, time.January, 4, 0, 0, 0, 0, time.UTC).AddDate(0, 0, 7*(w-1))))
+	}
+	return out
+}
+
+// The JIRA KPIs take their window from requestNow: with the clock pinned, the response buckets and the
+// JQL date bounds sent upstream both end in the pinned week (or month).
+func TestWeekWindowsWithPinnedNow(t *testing.T) {
+	withBucketLocation(t, time.UTC)
+	pinNow(t, time.Date(2024, time.May, 15, 12, 0, 0, 0, time.UTC)) // Wednesday of 2024-W20
+	setJIRAEnv(t)
+	t.Setenv("NEURON_API_TOKEN", "")
+	var mu sync.Mutex
+	var starts []string // the created >= bound of every JQL the stub receives
+	createdStart := regexp.MustCompile(`created >= '([0-9-]+)' AND created < '([0-9-]+)'`)
+	stubUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		if m := createdStart.FindStringSubmatch(r.URL.Query().Get("jql")); m != nil {
+			mu.Lock()
+			starts = append(starts, m[1]+".."+m[2])
+			mu.Unlock()
+		}
+		fmt.Fprint(w, `{"issues": [{"key": "VOS-1", "fields": {}}], "total": 1}`)
+	})
+
+	tests := []struct {
+		name        string
+		target      string
+		handler     gin.HandlerFunc
+		bucketsKey  string
+		want        []string
+		first, last string
+	}{
+		{"vos", "/api/kpi/vos-tickets", kpiVOSTickets, "weeks", isoWeeks(2024, 11, 20), "2024-03-11..2024-03-18", "2024-05-13..2024-05-20"},
+		{"build_bugs", "/api/kpi/build-bugs", kpiBuildBugs, "weeks", isoWeeks(2024, 11, 20), "2024-03-11..2024-03-18", "2024-05-13..2024-05-20"},
+		{"mtbf", "/api/kpi/mtbf", kpiMTBF, "weeks", isoWeeks(2024, 7, 20), "2024-02-12..2024-02-19", "2024-05-13..2024-05-20"},
+		{"vos_monthly", "/api/kpi/vos-tickets?granularity=month", kpiVOSTickets, "months", []string{"2024-03", "2024-04", "2024-05"}, "2024-03-01..2024-04-01", "2024-05-01..2024-06-01"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			starts = nil
+			c, rec := testContext(tt.target)
+			c.Request = c.Request.WithContext(withRequestValues(c.Request.Context()))
+			tt.handler(c)
+			var body map[string]json.RawMessage
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || rec.Code != http.StatusOK {
+				t.Fatalf("status %d: %s", rec.Code, rec.Body.String())
+			}
+			var got []string
+			if err := json.Unmarshal(body[tt.bucketsKey], &got); err != nil || !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("%s = %s, want %v", tt.bucketsKey, body[tt.bucketsKey], tt.want)
+			}
+			sort.Strings(starts)
+			if len(starts) != len(tt.want) || starts[0] != tt.first || starts[len(starts)-1] != tt.last {
+				t.Errorf("created bounds = %v, want %d buckets from %s to %s", starts, len(tt.want), tt.first, tt.last)
+			}
+		})
+	}
+}
+
+func TestBuildkiteDailyWindowWithPinnedNow(t *testing.T) {
+	withBucketLocation(t, time.UTC)
+	pinNow(t, time.Date(2024, time.May, 15, 12, 0, 0, 0, time.UTC))
+	c, _ := testContext("/api/kpi/buildkite-combined-all")
+	now := requestNow(c)
+
+	days := bucketGranularity(granularityDay).keys(now.AddDate(0, 0, -30), now)
+	if len(days) != 31 || days[0] != "2024-04-15" || days[30] != "2024-05-15" {
+		t.Fatalf("days = %v (%d), want 2024-04-15..2024-05-15", days, len(days))
+	}
+}
+
+func TestWeekWindowsFollowBucketLocation(t *testing.T) {
+	la, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Skip(err)
+	}
+	withBucketLocation(t, la)
+	// Monday 03:00 UTC is still Sunday evening in Los Angeles, so the current week is 2024-W19
+	pinNow(t, time.Date(2024, time.May, 13, 3, 0, 0, 0, time.UTC))
+	c, _ := testContext("/api/kpi/mtbf")
+	now := requestNow(c).In(bucketLocation)
+
+	got := bucketGranularity(granularityWeek).keys(now.AddDate(0, -mtbfWindowMonths, 0), now)
+	if want := isoWeeks(2024, 7, 19); !reflect.DeepEqual(got, want) {
+		t.Fatalf("keys = %v, want %v", got, want)
+	}
+}
+
+func TestRequestNowPrefersOverride(t *testing.T) {
+	pinNow(t, time.Date(2024, time.May, 15, 12, 0, 0, 0, time.UTC))
+	override := time.Date(2023, time.January, 2, 0, 0, 0, 0, time.UTC)
+	c, _ := testContext("/api/kpi/vos-tickets?now=2023-01-02T00:00:00Z")
+	t.Setenv("ENV", "dev")
+	nowOverrideMiddleware(c)
+	if got := requestNow(c); !got.Equal(override) {
+		t.Fatalf("requestNow = %v, want %v", got, override)
+	}
+}
+
+// ?now= in UTC still yields JIRA bounds in bucketLocation, the zone the bucket keys use.
+func TestCreatedWindowJQLUsesBucketLocation(t *testing.T) {
+	la, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Skip(err)
+	}
+	withBucketLocation(t, la)
+	c, _ := testContext("/api/kpi/time-in-build")
+	now := time.Date(2024, 5, 15, 3, 0, 0, 0, time.UTC) // 2024-05-14 20:00 in Los Angeles
+	c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), nowOverrideCtxKey{}, now))
+
+	start := now.In(la).AddDate(0, 0, -kpiCreatedDays).Format("2006-01-02 15:04")
+	want := "created >= '" + start + "' AND created < '2024-05-14 20:00'"
+	if got := createdWindowJQL(c); got != want {
+		t.Errorf("createdWindowJQL = %s, want %s", got, want)
+	}
+}
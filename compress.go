@@ -0,0 +1,104 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// gzipLevel is the compression level for API and frontend responses from GZIP_LEVEL: 1 (fastest) to 9 (smallest),
+// default 6; 0 turns compression off, and gzip's -1 (its default) and -2 (Huffman only) are accepted too.
+var gzipLevel = defaultGzipLevel
+
+const defaultGzipLevel = 6
+
+func loadGzipLevel() int {
+	raw := envString("GZIP_LEVEL", "")
+	if raw == "" {
+		return defaultGzipLevel
+	}
+	// gzip.NewWriterLevel rejects anything below HuffmanOnly, which would fail every compressed response
+	level, err := strconv.Atoi(raw)
+	if err != nil || level < gzip.HuffmanOnly {
+		log.Printf("[Config] Ignoring invalid GZIP_LEVEL=%q (want %d to %d); using %d", raw, gzip.HuffmanOnly, gzip.BestCompression, defaultGzipLevel)
+		return defaultGzipLevel
+	}
+	if level > gzip.BestCompression {
+		log.Printf("[Config] GZIP_LEVEL=%d is above %d; using %d", level, gzip.BestCompression, gzip.BestCompression)
+		level = gzip.BestCompression
+	}
+	return level
+}
+
+// precompressedExts are asset types that are already compressed, so gzipping them only costs CPU.
+var precompressedExts = map[string]bool{
+	".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".webp": true, ".avif": true, ".ico": true,
+	".woff": true, ".woff2": true, ".gz": true, ".br": true, ".zip": true, ".mp4": true, ".webm": true,
+}
+
+// gzipMiddleware compresses the response when the client accepts gzip. Range requests and precompressed
+// asset types are passed through untouched.
+func gzipMiddleware(c *gin.Context) {
+	if gzipLevel == 0 ||
+		!strings.Contains(c.GetHeader("Accept-Encoding"), "gzip") ||
+		c.GetHeader("Range") != "" ||
+		precompressedExts[strings.ToLower(path.Ext(c.Request.URL.Path))] {
+		c.Next()
+		return
+	}
+	c.Header("Vary", "Accept-Encoding")
+	w := &gzipResponseWriter{ResponseWriter: c.Writer}
+	c.Writer = w
+	defer w.close(c.Request.Context())
+	c.Next()
+}
+
+// gzipResponseWriter starts compressing on the first body write, so bodiless responses (304, 204) go out
+// without a Content-Encoding or a stray gzip footer.
+type gzipResponseWriter struct {
+	gin.ResponseWriter
+	gz *gzip.Writer
+}
+
+func (w *gzipResponseWriter) Write(b []byte) (int, error) {
+	if w.gz == nil {
+		h := w.Header()
+		if h.Get("Content-Encoding") != "" {
+			return w.ResponseWriter.Write(b) // handler encoded the body itself
+		}
+		h.Set("Content-Encoding", "gzip")
+		h.Del("Content-Length")
+		gz, err := gzip.NewWriterLevel(w.ResponseWriter, gzipLevel)
+		if err != nil {
+			return 0, err
+		}
+		w.gz = gz
+	}
+	return w.gz.Write(b)
+}
+
+func (w *gzipResponseWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *gzipResponseWriter) Flush() {
+	if w.gz != nil {
+		_ = w.gz.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+func (w *gzipResponseWriter) close(ctx context.Context) {
+	if w.gz != nil {
+		if err := w.gz.Close(); err != nil && !errors.Is(err, http.ErrBodyNotAllowed) {
+			logf(ctx, "Gzip", "Failed to finish response: %v", err)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"compress/gzip"
+	"testing"
+)
+
+func TestLoadGzipLevel(t *testing.T) {
+	for raw, want := range map[string]int{
+		"":   defaultGzipLevel,
+		"0":  0,
+		"1":  gzip.BestSpeed,
+		"12": gzip.BestCompression,
+		"-2": gzip.HuffmanOnly,
+		"-3": defaultGzipLevel,
+	} {
+		t.Setenv("GZIP_LEVEL", raw)
+		if got := loadGzipLevel(); got != want {
+			t.Errorf("GZIP_LEVEL=%q: level %d, want %d", raw, got, want)
+		}
+		if _, err := gzip.NewWriterLevel(nil, loadGzipLevel()); err != nil {
+			t.Errorf("GZIP_LEVEL=%q: %v", raw, err)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// httpClient sends every JIRA, BuildKite, Fleetio and Neuron request. Tests can replace it with a client whose
+// Transport points at an httptest.Server or a stub RoundTripper. Timeout (HTTP_CLIENT_TIMEOUT_SEC, default 30s)
+// applies per request, on top of the request context's cancellation. metricsTransport records upstream_* metrics.
+var httpClient = &http.Client{
+	Timeout:   30 * time.Second,
+	Transport: metricsTransport{base: http.DefaultTransport},
+}
+
+// loadConfig applies the env-tunable settings. main calls it right after godotenv.Load: package-level
+// initializers run before main, so settings read there would never see .env. Each setting's declaration holds
+// its default, which is also what code running without main (tests) gets.
+func loadConfig() {
+	httpClient.Timeout = envSeconds("HTTP_CLIENT_TIMEOUT_SEC", httpClient.Timeout)
+	gzipLevel = loadGzipLevel()
+	corsAllowedOrigins = loadCORSOrigins()
+	bucketLocation = loadBucketLocation()
+
+	// BuildKite
+	buildkiteDeploymentPipelines = loadBuildkitePipelines()
+	defaultBranchFilter = loadBranchFilter()
+	buildkiteMaxPages = max(1, envInt("BUILDKITE_MAX_PAGES", buildkiteMaxPages))
+	buildkiteMaxAttempts = max(1, envInt("BUILDKITE_MAX_ATTEMPTS", buildkiteMaxAttempts))
+	buildkiteCacheTTL = envSeconds("BUILDKITE_CACHE_TTL_SEC", buildkiteCacheTTL)
+	buildkiteCacheMaxAge = envSeconds("BUILDKITE_CACHE_MAX_AGE_SEC", buildkiteCacheMaxAge)
+	buildkiteCache = newTTLCache[string, cachedBuilds](buildkiteCacheTTL, buildkiteCacheMaxAge)
+
+	// JIRA
+	timeInBuildFilter = loadJIRAQuerySetting("JIRA_TIME_IN_BUILD_FILTER_ID", kpiFilterIDDefault)
+	vosJQLSetting = loadJIRAQuerySetting("JIRA_VOS_JQL", vosTicketsJQL)
+	buildBugsSetting = loadJIRAQuerySetting("JIRA_BUILD_BUGS_JQL", buildBugsJQL)
+	mtbfJQLSetting = loadJIRAQuerySetting("JIRA_MTBF_JQL", mtbfJQL)
+	jiraPortfolioParent = loadPortfolioParent()
+	statusStages = loadStatusStages()
+	vehiclePrograms = loadVehiclePrograms()
+	vehicleAliases = loadVehicleAliases()
+	priorityWeights = loadPriorityWeights()
+	jiraRetryBudget = envInt("JIRA_RETRY_BUDGET", jiraRetryBudget)
+	jiraWeekConcurrency = max(1, envInt("JIRA_WEEK_CONCURRENCY", jiraWeekConcurrency))
+	customJQLMaxLen = envInt("JIRA_CUSTOM_JQL_MAX_LEN", customJQLMaxLen)
+	jiraExportMaxIssues = envInt("JIRA_EXPORT_MAX_ISSUES", jiraExportMaxIssues)
+	jiraFilterCache = newTTLCache[string, string](envSeconds("JIRA_FILTER_CACHE_TTL_SEC", jiraFilterCache.ttl), 0)
+	vosWindowMonths = loadKPIWindowMonths("KPI_VOS_MONTHS", vosWindowMonths)
+	buildBugsWindowMonths = loadKPIWindowMonths("KPI_BUILD_BUGS_MONTHS", buildBugsWindowMonths)
+	mtbfWindowMonths = loadKPIWindowMonths("KPI_MTBF_MONTHS", mtbfWindowMonths)
+	vosResponseCache = newBoundedTTLCache[string, cachedResponse](envSeconds("KPI_VOS_CACHE_TTL_SEC", vosResponseCache.ttl), 0, responseCacheMaxEntries)
+	buildBugsResponseCache = newBoundedTTLCache[string, cachedResponse](envSeconds("KPI_BUILD_BUGS_CACHE_TTL_SEC", buildBugsResponseCache.ttl), 0, responseCacheMaxEntries)
+	mtbfResponseCache = newBoundedTTLCache[string, cachedResponse](envSeconds("KPI_MTBF_CACHE_TTL_SEC", mtbfResponseCache.ttl), 0, responseCacheMaxEntries)
+	kpiTargets = loadKPITargets()
+
+	// Fleetio
+	fleetioMaxPages = max(1, envInt("FLEETIO_MAX_PAGES", fleetioMaxPages))
+}
+
+// envSeconds reads a duration in whole seconds from env, falling back to def when unset or invalid.
+func envSeconds(name string, def time.Duration) time.Duration {
+	raw := strings.TrimSpace(os.Getenv(name))
+	if raw == "" {
+		return def
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 0 {
+		log.Printf("[Config] Ignoring invalid %s=%q (want whole seconds); using %v", name, raw, def)
+		return def
+	}
+	return time.Duration(n) * time.Second
+}
+
+// envString reads a trimmed string from env, falling back to def when unset or blank.
+func envString(name, def string) string {
+	raw, set := os.LookupEnv(name)
+	if !set {
+		return def
+	}
+	if v := strings.TrimSpace(raw); v != "" {
+		return v
+	}
+	log.Printf("[Config] Ignoring blank %s; using the default", name)
+	return def
+}
+
+// envInt reads a non-negative integer from env, falling back to def when unset or invalid.
+func envInt(name string, def int) int {
+	raw := strings.TrimSpace(os.Getenv(name))
+	if raw == "" {
+		return def
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 0 {
+		log.Printf("[Config] Ignoring invalid %s=%q (want a non-negative integer); using %d", name, raw, def)
+		return def
+	}
+	return n
+}
+
+// integrationChecks are the integrations validateConfig reports on, by the names REQUIRED_INTEGRATIONS uses.
+var integrationChecks = []struct {
+	name    string
+	missing func() []string
+}{
+	{"jira", jiraConfigMissing},
+	{"buildkite", buildkiteConfigMissing},
+	{"fleetio", fleetioConfigMissing},
+	{"neuron", neuronConfigMissing},
+	{"lakehouse", lakehouseConfigMissing},
+}
+
+// buildkiteOrgSlug is the shape of a BuildKite organization slug, to catch a typo'd BUILDKITE_ORG at startup.
+var buildkiteOrgSlug = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// validateConfig logs which integrations are configured and what each is missing. Integrations named in
+// REQUIRED_INTEGRATIONS (comma-separated, e.g. "jira,buildkite") that are unconfigured, or a malformed
+// BUILDKITE_ORG, are warnings by default and stop startup with STRICT_CONFIG=true.
+func validateConfig() {
+	strict := strings.EqualFold(strings.TrimSpace(os.Getenv("STRICT_CONFIG")), "true")
+	required := make(map[string]bool)
+	for _, name := range strings.Split(os.Getenv("REQUIRED_INTEGRATIONS"), ",") {
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			required[name] = true
+		}
+	}
+
+	var problems []string
+	for _, check := range integrationChecks {
+		missing := check.missing()
+		switch {
+		case len(missing) == 0:
+			log.Printf("[Config] %s: configured", check.name)
+		case required[check.name]:
+			problems = append(problems, fmt.Sprintf("required integration %s is missing %s", check.name, strings.Join(missing, ", ")))
+		default:
+			log.Printf("[Config] %s: not configured (missing %s)", check.name, strings.Join(missing, ", "))
+		}
+		delete(required, check.name)
+	}
+	for name := range required {
+		problems = append(problems, fmt.Sprintf("REQUIRED_INTEGRATIONS names unknown integration %q", name))
+	}
+	for _, org := range buildkiteOrgs() {
+		if !buildkiteOrgSlug.MatchString(org) {
+			problems = append(problems, fmt.Sprintf("BUILDKITE_ORG %q is not an organization slug (lowercase letters, digits, dashes)", org))
+		}
+	}
+
+	if len(problems) == 0 {
+		return
+	}
+	sort.Strings(problems)
+	for _, p := range problems {
+		log.Printf("[Config] Warning: %s", p)
+	}
+	if strict {
+		log.Fatalf("[Config] STRICT_CONFIG=true: refusing to start with %d configuration problem(s)", len(problems))
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+// The commented samples in .env.example are copied into real .env files, so they must be the built-in defaults.
+func TestEnvExampleJQLMatchesDefaults(t *testing.T) {
+	raw, err := os.ReadFile(".env.example")
+	if err != nil {
+		t.Fatal(err)
+	}
+	samples := map[string]string{}
+	for _, line := range strings.Split(string(raw), "\n") {
+		if name, value, ok := strings.Cut(strings.TrimPrefix(line, "# "), "="); ok {
+			samples[name] = value
+		}
+	}
+	for name, want := range map[string]string{
+		"JIRA_VOS_JQL":        vosTicketsJQL,
+		"JIRA_BUILD_BUGS_JQL": buildBugsJQL,
+		"JIRA_MTBF_JQL":       mtbfJQL,
+	} {
+		if samples[name] != want {
+			t.Errorf(".env.example %s=%s\nwant %s", name, samples[name], want)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// devCORSOrigins are the Vite dev server origins allowed by default with ENV=dev.
+var devCORSOrigins = []string{"http://localhost:3000", "http://127.0.0.1:3000"}
+
+// corsAllowedOrigins is from CORS_ALLOWED_ORIGINS (comma-separated, "*" for any), else devCORSOrigins with
+// ENV=dev. Empty means same-origin only, which is all the embedded frontend needs.
+var corsAllowedOrigins []string
+
+func loadCORSOrigins() []string {
+	if raw := strings.TrimSpace(os.Getenv("CORS_ALLOWED_ORIGINS")); raw != "" {
+		var origins []string
+		for _, o := range strings.Split(raw, ",") {
+			if o = strings.TrimRight(strings.TrimSpace(o), "/"); o != "" {
+				origins = append(origins, o)
+			}
+		}
+		return origins
+	}
+	if os.Getenv("ENV") == "dev" {
+		return devCORSOrigins
+	}
+	return nil
+}
+
+const (
+	corsAllowMethods = "GET, HEAD, POST, OPTIONS"
+	// Request headers the frontend sends: API key for ?now=, request IDs, and conditional GETs
+	corsAllowHeaders = "Accept, Content-Type, X-API-Key, X-Request-ID, If-None-Match"
+	// Response headers the frontend reads
+	corsExposeHeaders = "ETag, Age, X-Cache, X-Request-ID, X-Dashboard-Now"
+)
+
+// corsOriginAllowed reports whether origin is in corsAllowedOrigins.
+func corsOriginAllowed(origin string) bool {
+	for _, o := range corsAllowedOrigins {
+		if o == "*" || strings.EqualFold(o, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// corsMiddleware adds CORS headers for allowed cross-origin callers and answers their preflight requests.
+// Requests without an Origin, or from origins not allowed, pass through untouched (same-origin only).
+func corsMiddleware(c *gin.Context) {
+	origin := c.GetHeader("Origin")
+	if origin == "" || !corsOriginAllowed(origin) {
+		c.Next()
+		return
+	}
+	h := c.Writer.Header()
+	h.Set("Access-Control-Allow-Origin", origin)
+	h.Add("Vary", "Origin")
+	h.Set("Access-Control-Expose-Headers", corsExposeHeaders)
+	if c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != "" {
+		h.Set("Access-Control-Allow-Methods", corsAllowMethods)
+		h.Set("Access-Control-Allow-Headers", corsAllowHeaders)
+		h.Set("Access-Control-Max-Age", "600")
+		c.AbortWithStatus(http.StatusNoContent)
+		return
+	}
+	c.Next()
+}
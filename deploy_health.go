@@ -0,0 +1,197 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Deploy health index: one 0–100 number per week blending three BuildKite metrics.
+//
+// Each component is normalized to 0–100 where 100 is best:
+//   - failure:   100 − failure rate (%), so 0% failures = 100 and 100% failures = 0
+//   - frequency: deploys that week / busiest week in the window × 100
+//   - duration:  fastest weekly average in the window / that week's average × 100
+//
+// The index is the weighted mean of the components available that week; when a week has no passed deploy
+// (no duration) or no passed/failed deploy (no failure rate), the remaining weights are rescaled to sum to 1.
+// Frequency and duration are relative to the window, so the index compares weeks against each other, not
+// against an absolute standard.
+
+type deployHealthWeights struct {
+	Failure   float64 `json:"failure"`
+	Frequency float64 `json:"frequency"`
+	Duration  float64 `json:"duration"`
+}
+
+var defaultDeployHealthWeights = deployHealthWeights{Failure: 0.5, Frequency: 0.25, Duration: 0.25}
+
+// parseDeployHealthWeights parses "failure=0.5,frequency=0.25,duration=0.25"; omitted components get weight 0.
+func parseDeployHealthWeights(raw string) (deployHealthWeights, error) {
+	var w deployHealthWeights
+	for _, pair := range strings.Split(raw, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		name, val, found := strings.Cut(pair, "=")
+		f, err := strconv.ParseFloat(strings.TrimSpace(val), 64)
+		if !found || err != nil || f < 0 || math.IsInf(f, 0) || math.IsNaN(f) {
+			return w, fmt.Errorf("invalid weight %q (want component=non-negative number)", pair)
+		}
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "failure":
+			w.Failure = f
+		case "frequency":
+			w.Frequency = f
+		case "duration":
+			w.Duration = f
+		default:
+			return w, fmt.Errorf("unknown weight component %q (want failure, frequency, duration)", name)
+		}
+	}
+	if w.Failure+w.Frequency+w.Duration == 0 {
+		return w, errors.New("at least one weight must be positive")
+	}
+	return w, nil
+}
+
+// deployHealthWeightsFromEnv reads DEPLOY_HEALTH_WEIGHTS, falling back to the defaults when unset or invalid.
+func deployHealthWeightsFromEnv() deployHealthWeights {
+	raw := strings.TrimSpace(os.Getenv("DEPLOY_HEALTH_WEIGHTS"))
+	if raw == "" {
+		return defaultDeployHealthWeights
+	}
+	w, err := parseDeployHealthWeights(raw)
+	if err != nil {
+		log.Printf("[Config] Ignoring DEPLOY_HEALTH_WEIGHTS=%q: %v", raw, err)
+		return defaultDeployHealthWeights
+	}
+	return w
+}
+
+// GET /api/kpi/deploy-health?weights=failure=0.5,frequency=0.25,duration=0.25 – weekly deploy health index.
+func kpiDeployHealth(c *gin.Context) {
+	token, _, ok := buildkiteConfig()
+	if !ok {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error":   "BuildKite not configured",
+			"missing": buildkiteConfigMissing(),
+			"hint":    "Set BUILDKITE_TOKEN and BUILDKITE_ORG in .env",
+		})
+		return
+	}
+	orgs, err := selectBuildkiteOrgs(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	opts, err := buildkiteAggOptionsFromQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	weights := deployHealthWeightsFromEnv()
+	if raw := strings.TrimSpace(c.Query("weights")); raw != "" {
+		if weights, err = parseDeployHealthWeights(raw); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	threeMonthsAgo := requestNow(c).AddDate(0, -3, 0)
+	builds, cacheStatus, err := getCachedBuilds(c.Request.Context(), token, orgs, buildkiteDeploymentPipelines, threeMonthsAgo)
+	if err != nil {
+		c.JSON(upstreamFailureStatus(err), upstreamErrorBody("Failed to fetch builds: ", err))
+		return
+	}
+	opts.Bucket = weekKey
+	m := aggregateBuildkite(builds, opts)
+
+	failureByWeek := make(map[string]float64)
+	for i, w := range m.RateBuckets {
+		failureByWeek[w] = 100 - m.FailureRates[i]
+	}
+	maxDeploys := 0
+	for _, n := range m.DeployCounts {
+		if n > maxDeploys {
+			maxDeploys = n
+		}
+	}
+	minDuration := math.Inf(1)
+	for _, d := range m.AvgDurations {
+		if d > 0 && d < minDuration {
+			minDuration = d
+		}
+	}
+	durationByWeek := make(map[string]float64)
+	for i, w := range m.DurationBuckets {
+		if d := m.AvgDurations[i]; d > 0 {
+			durationByWeek[w] = minDuration / d * 100
+		}
+	}
+
+	round1 := func(v float64) float64 { return math.Round(v*10) / 10 }
+	weeks := m.FrequencyBuckets
+	index := make([]float64, len(weeks))
+	components := make([]gin.H, len(weeks))
+	for i, w := range weeks {
+		type part struct {
+			name   string
+			score  float64
+			weight float64
+		}
+		parts := []part{{"frequency", float64(m.DeployCounts[i]) / float64(maxDeploys) * 100, weights.Frequency}}
+		if v, ok := failureByWeek[w]; ok {
+			parts = append(parts, part{"failure", v, weights.Failure})
+		}
+		if v, ok := durationByWeek[w]; ok {
+			parts = append(parts, part{"duration", v, weights.Duration})
+		}
+		var totalWeight float64
+		for _, p := range parts {
+			totalWeight += p.weight
+		}
+		detail := gin.H{}
+		var score float64
+		for _, p := range parts {
+			contribution := 0.0
+			if totalWeight > 0 {
+				contribution = p.score * p.weight / totalWeight
+			}
+			score += contribution
+			detail[p.name] = gin.H{"score": round1(p.score), "contribution": round1(contribution)}
+		}
+		index[i] = round1(score)
+		components[i] = detail
+	}
+
+	meta := gin.H{
+		"source":          cacheStatus.source(),
+		"weights":         weights,
+		"normalization":   "failure = 100 - failure rate; frequency = deploys / busiest week x 100; duration = fastest weekly avg / week avg x 100; weights rescaled over components present that week",
+		"date_range":      fmt.Sprintf("last 3 months (from %s)", threeMonthsAgo.Format("2006-01-02")),
+		"deployments":     m.Deployments,
+		"cache_age_sec":   int(cacheStatus.Age.Seconds()),
+		"stale":           cacheStatus.Stale,
+		"org":             strings.Join(orgs, ","),
+		"bucket_by":       opts.BucketBy,
+		"duration_basis":  opts.DurationBasis,
+		"branch_patterns": opts.Branches.patterns(),
+		"bucket_timezone": bucketLocation.String(),
+	}
+	addBuildkitePipelines(meta, cacheStatus.Pipelines)
+	c.JSON(http.StatusOK, gin.H{
+		"weeks":       weeks,
+		"week_ranges": weekRangeLabels(weeks),
+		"index":       index,
+		"components":  components,
+		"meta":        meta,
+	})
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Timeline event types, in the order they usually happen.
+const (
+	eventEpicCreated         = "epic_created"
+	eventChildInProgress     = "child_in_progress"
+	eventChildDone           = "child_done"
+	eventReleaseToFleetClose = "release_to_fleet_done" // the release-to-fleet child reaching Done
+	eventEpicDone            = "epic_done"
+)
+
+type epicTimelineEvent struct {
+	Type      string    `json:"type"`
+	IssueKey  string    `json:"issue_key"`
+	Summary   string    `json:"summary"`
+	Timestamp time.Time `json:"timestamp"`
+	VBUILD    bool      `json:"is_vbuild,omitempty"`
+}
+
+type epicTimelineSpan struct {
+	Name string  `json:"name"`
+	From string  `json:"from"`
+	To   string  `json:"to"`
+	Days float64 `json:"days"`
+	Note string  `json:"note"`
+}
+
+// GET /api/kpi/epic-timeline/:key – one epic's build as a chronological event list plus the derived spans,
+// for attaching to a retro. Uses the same child lookup and In Progress/Done rules as /kpi/debug-epic.
+func kpiEpicTimeline(c *gin.Context) {
+	baseURL, email, token, ok := jiraConfig()
+	if !ok {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "JIRA not configured", "missing": jiraConfigMissing()})
+		return
+	}
+	key := strings.TrimSpace(strings.ToUpper(c.Param("key")))
+	if !issueKeyPattern.MatchString(key) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid epic key %q (want an issue key like VBUILD-5762)", c.Param("key"))})
+		return
+	}
+	ctx := c.Request.Context()
+
+	epic, err := getIssue(ctx, baseURL, email, token, key, "changelog")
+	if err != nil {
+		body := upstreamErrorBody("fetch epic: ", err)
+		body["epic_key"] = key
+		c.JSON(upstreamFailureStatus(err), body)
+		return
+	}
+	summary := getFieldString(epic, "fields.summary")
+	children, childJQL, childErrs := fetchEpicChildren(ctx, baseURL, email, token, key)
+	// With every child query failed the timeline would look like an epic with no work, so report the failure
+	if len(children) == 0 && len(childErrs) == len(epicChildQueries(key)) {
+		c.JSON(http.StatusBadGateway, gin.H{
+			"error":           "fetch epic children: " + strings.Join(childErrs, "; "),
+			"epic_key":        key,
+			"children_errors": childErrs,
+		})
+		return
+	}
+
+	var events []epicTimelineEvent
+	if t, ok := getFieldTime(epic, "fields.created"); ok {
+		events = append(events, epicTimelineEvent{Type: eventEpicCreated, IssueKey: key, Summary: summary, Timestamp: t})
+	}
+	epicDone, hasEpicDone := getFieldTime(epic, "fields.resolutiondate")
+	if !hasEpicDone {
+		epicDone, hasEpicDone = statusTransitionFromChangelogAny(epic, statusesForStage(stageDone))
+	}
+	if hasEpicDone {
+		events = append(events, epicTimelineEvent{Type: eventEpicDone, IssueKey: key, Summary: summary, Timestamp: epicDone})
+	}
+
+	var firstInProgress, lastDone, releaseDone time.Time
+	var fetchErrs []string
+	for _, ch := range children {
+		childKey, _ := ch["key"].(string)
+		if childKey == "" {
+			continue
+		}
+		issue, err := getIssue(ctx, baseURL, email, token, childKey, "changelog")
+		if err != nil {
+			fetchErrs = append(fetchErrs, childKey+": "+err.Error())
+			continue
+		}
+		chSummary := getFieldString(issue, "fields.summary")
+		isVbuild := isVBUILD(issue)
+		if t, ok := statusTransitionFromChangelogAny(issue, statusesForStage(stageInProgress)); ok {
+			events = append(events, epicTimelineEvent{Type: eventChildInProgress, IssueKey: childKey, Summary: chSummary, Timestamp: t, VBUILD: isVbuild})
+			if isVbuild && (firstInProgress.IsZero() || t.Before(firstInProgress)) {
+				firstInProgress = t
+			}
+		}
+		if t, ok := statusTransitionFromChangelogAny(issue, statusesForStage(stageDone)); ok {
+			eventType := eventChildDone
+			if isReleaseToFleet(issue) {
+				eventType = eventReleaseToFleetClose
+				if t.After(releaseDone) {
+					releaseDone = t
+				}
+			}
+			events = append(events, epicTimelineEvent{Type: eventType, IssueKey: childKey, Summary: chSummary, Timestamp: t, VBUILD: isVbuild})
+			if isVbuild && t.After(lastDone) {
+				lastDone = t
+			}
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Timestamp.Before(events[j].Timestamp) })
+
+	epicCreated, hasEpicCreated := getFieldTime(epic, "fields.created")
+	spans := []epicTimelineSpan{}
+	addSpan := func(name string, from, to time.Time, note string) {
+		if from.IsZero() || to.IsZero() || !to.After(from) {
+			return
+		}
+		spans = append(spans, epicTimelineSpan{name, formatTime(from), formatTime(to), math.Round(to.Sub(from).Hours()/24*10) / 10, note})
+	}
+	addSpan("vbuild_build", firstInProgress, lastDone, "first VBUILD child In Progress → last VBUILD child Done (Rogue/MachE build time)")
+	if hasEpicCreated {
+		addSpan("epic_lifetime", epicCreated, epicDone, "epic created → epic resolved (Other build time)")
+		addSpan("to_release_to_fleet", epicCreated, releaseDone, "epic created → release-to-fleet ticket Done")
+	}
+
+	// build_days follows /kpi/debug-epic: the VBUILD span for a vehicle program, the epic lifetime for Other
+	var buildDays interface{}
+	buildSpan := "vbuild_build"
+	program, classifiedBy := epicProgram(epic)
+	if program == programOther {
+		buildSpan = "epic_lifetime"
+	}
+	for _, s := range spans {
+		if s.Name == buildSpan {
+			buildDays = s.Days
+		}
+	}
+
+	if events == nil {
+		events = []epicTimelineEvent{}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"epic_key":      key,
+		"summary":       summary,
+		"vehicle":       extractVehicleName(summary),
+		"is_rogue":      program == programRogue,
+		"is_mach_e":     program == programMachE,
+		"program":       program,
+		"classified_by": classifiedBy,
+		"events":        events,
+		"spans":         spans,
+		"build_days":    buildDays,
+		"build_span":    buildSpan,
+		"meta": gin.H{
+			"source":          sourceLive,
+			"children_count":  len(children),
+			"children_query":  childJQL,
+			"children_errors": append(childErrs, fetchErrs...),
+			"url":             jiraBrowseURL(key),
+		},
+	})
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestEpicTimelineErrors(t *testing.T) {
+	setJIRAEnv(t)
+	calls := stubUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "/search") {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"errorMessages": ["parent is not a valid field"]}`)
+			return
+		}
+		fmt.Fprint(w, `{"key": "VBUILD-1", "fields": {"summary": "ROG-01 build", "created": "2024-05-01T00:00:00.000+0000"}}`)
+	})
+	timeline := func(key string) (int, string) {
+		c, rec := testContext("/api/kpi/epic-timeline/" + url.PathEscape(key))
+		c.Params = gin.Params{{Key: "key", Value: key}}
+		c.Request = c.Request.WithContext(withRequestValues(c.Request.Context()))
+		kpiEpicTimeline(c)
+		return rec.Code, rec.Body.String()
+	}
+
+	if code, body := timeline("VBUILD-1 OR 1=1"); code != http.StatusBadRequest || calls.Load() != 0 {
+		t.Errorf("malformed key: status %d after %d calls: %s; want 400 before any JIRA call", code, calls.Load(), body)
+	}
+	if code, body := timeline("vbuild-1"); code != http.StatusBadGateway || !strings.Contains(body, "fetch epic children") {
+		t.Errorf("all child queries failed: status %d: %s; want 502", code, body)
+	}
+}
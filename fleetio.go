@@ -1,13 +1,16 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -56,9 +59,9 @@ func fleetioMe(c *gin.Context) {
 	req.Header.Set("Account-Token", accountToken)
 	req.Header.Set("Accept", "application/json")
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := httpClient.Do(req)
 	if err != nil {
-		c.JSON(http.StatusBadGateway, gin.H{"error": "Fleetio request failed: " + err.Error()})
+		c.JSON(upstreamFailureStatus(err), upstreamErrorBody("Fleetio request failed: ", err))
 		return
 	}
 	defer resp.Body.Close()
@@ -66,8 +69,9 @@ func fleetioMe(c *gin.Context) {
 
 	if resp.StatusCode != http.StatusOK {
 		c.JSON(resp.StatusCode, gin.H{
-			"error":  fmt.Sprintf("Fleetio API returned %d", resp.StatusCode),
-			"detail": string(body),
+			"error":           fmt.Sprintf("Fleetio API returned %d", resp.StatusCode),
+			"detail":          string(body),
+			"upstream_status": resp.StatusCode,
 		})
 		return
 	}
@@ -112,9 +116,9 @@ func fleetioVehicles(c *gin.Context) {
 	req.Header.Set("Account-Token", accountToken)
 	req.Header.Set("Accept", "application/json")
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := httpClient.Do(req)
 	if err != nil {
-		c.JSON(http.StatusBadGateway, gin.H{"error": "Fleetio request failed: " + err.Error()})
+		c.JSON(upstreamFailureStatus(err), upstreamErrorBody("Fleetio request failed: ", err))
 		return
 	}
 	defer resp.Body.Close()
@@ -122,8 +126,9 @@ func fleetioVehicles(c *gin.Context) {
 
 	if resp.StatusCode != http.StatusOK {
 		c.JSON(resp.StatusCode, gin.H{
-			"error":  fmt.Sprintf("Fleetio API returned %d", resp.StatusCode),
-			"detail": string(body),
+			"error":           fmt.Sprintf("Fleetio API returned %d", resp.StatusCode),
+			"detail":          string(body),
+			"upstream_status": resp.StatusCode,
 		})
 		return
 	}
@@ -146,3 +151,166 @@ func fleetioVehicles(c *gin.Context) {
 		"current_page": currentPage,
 	})
 }
+
+// fleetioMaxPages caps how many pages /api/fleetio/vehicles/all follows (FLEETIO_MAX_PAGES).
+var fleetioMaxPages = 50
+
+// fleetioAllPerPage is the page size used when following pagination server-side (Fleetio's maximum).
+const fleetioAllPerPage = 100
+
+// fleetioGet issues an authenticated GET against the Fleetio API and returns the response with its body read.
+func fleetioGet(ctx context.Context, accountToken, apiKey, path string) (*http.Response, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fleetioBaseURL+path, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Authorization", "Token "+apiKey)
+	req.Header.Set("Account-Token", accountToken)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	return resp, body, err
+}
+
+// GET /api/fleetio/vehicles/all – every vehicle, following X-Pagination-Total-Pages server-side
+// (up to FLEETIO_MAX_PAGES pages; "truncated" is set when the cap cut the list short).
+func fleetioVehiclesAll(c *gin.Context) {
+	accountToken, apiKey, ok := fleetioConfig()
+	if !ok {
+		missing := fleetioConfigMissing()
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error":   "Fleetio not configured",
+			"missing": missing,
+			"hint":    "Set FLEETIO_ACCOUNT_TOKEN and FLEETIO_API_KEY in .env or environment",
+		})
+		return
+	}
+
+	ctx := c.Request.Context()
+	vehicles := []map[string]interface{}{}
+	totalPages, pagesFetched := 1, 0
+	for page := 1; page <= totalPages && page <= fleetioMaxPages; page++ {
+		if err := ctx.Err(); err != nil {
+			c.JSON(upstreamFailureStatus(err), upstreamErrorBody("Fleetio request cancelled: ", err))
+			return
+		}
+		path := fmt.Sprintf("/vehicles?per_page=%d&page=%d", fleetioAllPerPage, page)
+		resp, body, err := fleetioGet(ctx, accountToken, apiKey, path)
+		if err != nil {
+			c.JSON(upstreamFailureStatus(err), upstreamErrorBody("Fleetio request failed: ", err))
+			return
+		}
+		if resp.StatusCode != http.StatusOK {
+			c.JSON(resp.StatusCode, gin.H{
+				"error":           fmt.Sprintf("Fleetio API returned %d on page %d", resp.StatusCode, page),
+				"detail":          string(body),
+				"upstream_status": resp.StatusCode,
+			})
+			return
+		}
+		var data []map[string]interface{}
+		if err := json.Unmarshal(body, &data); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid Fleetio response: " + err.Error()})
+			return
+		}
+		pagesFetched++
+		vehicles = append(vehicles, data...)
+		if len(data) == 0 {
+			break
+		}
+		if n, err := strconv.Atoi(resp.Header.Get("X-Pagination-Total-Pages")); err == nil {
+			totalPages = n
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"vehicles":      vehicles,
+		"total_count":   len(vehicles),
+		"total_pages":   totalPages,
+		"pages_fetched": pagesFetched,
+		"truncated":     totalPages > fleetioMaxPages,
+		"max_pages":     fleetioMaxPages,
+	})
+}
+
+// fleetioMeterEntry is one odometer/engine-hours reading from /vehicles/{id}/meter_entries.
+type fleetioMeterEntry struct {
+	ID        int64   `json:"id"`
+	VehicleID int64   `json:"vehicle_id"`
+	Date      string  `json:"date"`
+	Value     float64 `json:"value"`
+	MeterType string  `json:"meter_type"` // "primary" (odometer) or "secondary" (usually engine hours)
+	Void      bool    `json:"void"`
+	CreatedAt string  `json:"created_at"`
+}
+
+// GET /api/fleetio/vehicles/:id/meters – a vehicle's meter entries (odometer and engine hours), a stand-in
+// drive-hours source for MTBF while Neuron is unavailable. Query: start_date, end_date (YYYY-MM-DD), page, per_page.
+// An unknown vehicle id returns Fleetio's 404 and detail.
+func fleetioVehicleMeters(c *gin.Context) {
+	accountToken, apiKey, ok := fleetioConfig()
+	if !ok {
+		missing := fleetioConfigMissing()
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error":   "Fleetio not configured",
+			"missing": missing,
+			"hint":    "Set FLEETIO_ACCOUNT_TOKEN and FLEETIO_API_KEY in .env or environment",
+		})
+		return
+	}
+
+	id := c.Param("id")
+	if _, err := strconv.Atoi(id); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "vehicle id must be numeric"})
+		return
+	}
+	q := url.Values{}
+	q.Set("per_page", c.DefaultQuery("per_page", "100"))
+	q.Set("page", c.DefaultQuery("page", "1"))
+	// Fleetio list endpoints filter with ransack-style q[...] params
+	for name, filter := range map[string]string{"start_date": "q[date_gteq]", "end_date": "q[date_lteq]"} {
+		if raw := c.Query(name); raw != "" {
+			if _, err := time.Parse("2006-01-02", raw); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": name + " must be YYYY-MM-DD"})
+				return
+			}
+			q.Set(filter, raw)
+		}
+	}
+
+	resp, body, err := fleetioGet(c.Request.Context(), accountToken, apiKey, "/vehicles/"+id+"/meter_entries?"+q.Encode())
+	if err != nil {
+		c.JSON(upstreamFailureStatus(err), upstreamErrorBody("Fleetio request failed: ", err))
+		return
+	}
+	if resp.StatusCode != http.StatusOK {
+		c.JSON(resp.StatusCode, gin.H{
+			"error":           fmt.Sprintf("Fleetio API returned %d", resp.StatusCode),
+			"detail":          string(body),
+			"upstream_status": resp.StatusCode,
+			"vehicle_id":      id,
+		})
+		return
+	}
+
+	var entries []fleetioMeterEntry
+	if err := json.Unmarshal(body, &entries); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid Fleetio response: " + err.Error()})
+		return
+	}
+	if entries == nil {
+		entries = []fleetioMeterEntry{}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"vehicle_id":   id,
+		"entries":      entries,
+		"total_count":  resp.Header.Get("X-Pagination-Total-Count"),
+		"total_pages":  resp.Header.Get("X-Pagination-Total-Pages"),
+		"current_page": resp.Header.Get("X-Pagination-Current-Page"),
+	})
+}
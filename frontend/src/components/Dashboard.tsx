@@ -140,7 +140,7 @@ const Dashboard = () => {
   const [mtbfData, setMtbfData] = useState<{ week: string; failures: number }[]>([])
   const [mtbfLoading, setMtbfLoading] = useState(true)
   const [mtbfError, setMtbfError] = useState<string | null>(null)
-  const [mtbfMeta, setMtbfMeta] = useState<{ jql_used?: string; failures_seen?: number; date_filter?: string; note?: string; drive_hours?: string; data_available?: string } | null>(null)
+  const [mtbfMeta, setMtbfMeta] = useState<{ jql_used?: string; failures_seen?: number; date_filter?: string; note?: string; drive_hours?: { source: string | null; entries_skipped?: number }; data_available?: string } | null>(null)
 
   useEffect(() => {
     setLoading(true)
@@ -236,7 +236,7 @@ const Dashboard = () => {
     setMtbfError(null)
     fetch('/api/kpi/mtbf')
       .then(async (r) => {
-        const body = await r.json().catch(() => ({})) as { error?: string; weeks?: string[]; failures?: number[]; meta?: { jql_used?: string; failures_seen?: number; date_filter?: string; note?: string; drive_hours?: string; data_available?: string } }
+        const body = await r.json().catch(() => ({})) as { error?: string; weeks?: string[]; failures?: number[]; meta?: { jql_used?: string; failures_seen?: number; date_filter?: string; note?: string; drive_hours?: { source: string | null; entries_skipped?: number }; data_available?: string } }
         if (!r.ok) {
           const msg = (body && typeof body.error === 'string') ? body.error : `HTTP ${r.status}`
           throw new Error(msg)
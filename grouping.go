@@ -0,0 +1,249 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ?group_by= splits a JIRA KPI's weekly series by team, program, vehicle, assignee, issue type or priority.
+// "team" and "program" read an issue field chosen by JIRA_TEAM_FIELD / JIRA_PROGRAM_FIELD ("labels",
+// "components" or a custom field id such as "customfield_10201"); multi-value fields use their first value.
+const (
+	groupByTeam     = "team"
+	groupByProgram  = "program"
+	groupByVehicle  = "vehicle"
+	groupByAssignee = "assignee"
+	groupByType     = "type"
+	groupByPriority = "priority"
+
+	groupOther = "Other"  // groups past the limit are summed/averaged into this one
+	groupNone  = "(none)" // issue has no value for the grouping field
+
+	defaultGroupLimit = 8
+	maxGroupLimit     = 50
+)
+
+type issueGrouping struct {
+	by    string
+	field string // JIRA field the group value is read from
+	limit int    // max groups returned, including Other
+}
+
+// groupFieldFromEnv reads the JIRA field backing a team/program grouping, falling back to def when unset.
+func groupFieldFromEnv(name, def string) string {
+	if v := strings.TrimSpace(os.Getenv(name)); v != "" {
+		return v
+	}
+	return def
+}
+
+// parseGroupBy reads ?group_by= and ?group_limit=; it returns nil when no grouping was requested.
+func parseGroupBy(c *gin.Context) (*issueGrouping, error) {
+	by := strings.ToLower(strings.TrimSpace(c.Query("group_by")))
+	if by == "" {
+		return nil, nil
+	}
+	g := &issueGrouping{by: by, limit: defaultGroupLimit}
+	switch by {
+	case groupByTeam:
+		g.field = groupFieldFromEnv("JIRA_TEAM_FIELD", "components")
+	case groupByProgram:
+		g.field = groupFieldFromEnv("JIRA_PROGRAM_FIELD", "labels")
+	case groupByVehicle:
+		g.field = "summary"
+	case groupByAssignee:
+		g.field = "assignee"
+	case groupByType:
+		g.field = "issuetype"
+	case groupByPriority:
+		g.field = "priority"
+	default:
+		return nil, fmt.Errorf("invalid group_by %q (want team, program, vehicle, assignee, type, priority)", by)
+	}
+	if raw := strings.TrimSpace(c.Query("group_limit")); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 2 || n > maxGroupLimit {
+			return nil, fmt.Errorf("invalid group_limit %q (want 2-%d)", raw, maxGroupLimit)
+		}
+		g.limit = n
+	}
+	return g, nil
+}
+
+// searchFields returns base plus the field the grouping needs. A nil grouping returns base unchanged.
+func (g *issueGrouping) searchFields(base []string) []string {
+	if g == nil {
+		return base
+	}
+	for _, f := range base {
+		if f == g.field {
+			return base
+		}
+	}
+	return append(append([]string{}, base...), g.field)
+}
+
+// key returns the issue's group value, or groupNone when the field is empty.
+func (g *issueGrouping) key(issue map[string]interface{}) string {
+	var v string
+	switch g.by {
+	case groupByVehicle:
+		v = extractVehicleName(getFieldString(issue, "fields.summary"))
+	case groupByAssignee:
+		v = getFieldString(issue, "fields.assignee.displayName")
+	case groupByType:
+		v = getFieldString(issue, "fields.issuetype.name")
+	case groupByPriority:
+		v = getFieldString(issue, "fields.priority.name")
+	default:
+		fields, _ := issue["fields"].(map[string]interface{})
+		v = groupFieldValue(fields[g.field])
+	}
+	if v = strings.TrimSpace(v); v == "" {
+		return groupNone
+	}
+	return v
+}
+
+// groupFieldValue flattens a JIRA field value: a string, an option/component/user object, or the first
+// element of a list of either.
+func groupFieldValue(raw interface{}) string {
+	switch v := raw.(type) {
+	case string:
+		return v
+	case []interface{}:
+		if len(v) > 0 {
+			return groupFieldValue(v[0])
+		}
+	case map[string]interface{}:
+		for _, k := range []string{"name", "value", "displayName"} {
+			if s, ok := v[k].(string); ok {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+// withBuckets copies seriesJSON's groups, adding the bucket list (under bucketsKey, e.g. "weeks") to each so a
+// group can be charted on its own.
+func withBuckets(groups gin.H, bucketsKey string, buckets []string) gin.H {
+	out := gin.H{}
+	for name, series := range groups {
+		entry := gin.H{bucketsKey: buckets}
+		for k, v := range series.(gin.H) {
+			entry[k] = v
+		}
+		out[name] = entry
+	}
+	return out
+}
+
+// groupedSeries holds raw values per group and week: group → week → values.
+type groupedSeries map[string]map[string][]float64
+
+func (s groupedSeries) add(group, week string, v float64) {
+	if s[group] == nil {
+		s[group] = make(map[string][]float64)
+	}
+	s[group][week] = append(s[group][week], v)
+}
+
+func sumValues(vals []float64) float64 {
+	var sum float64
+	for _, v := range vals {
+		sum += v
+	}
+	return sum
+}
+
+func meanValues(vals []float64) float64 {
+	if len(vals) == 0 {
+		return 0
+	}
+	return sumValues(vals) / float64(len(vals))
+}
+
+// percentileValue is the nearest-rank percentile (p in 0..1) of vals: the value at index ceil(p*n)-1 once sorted.
+// A single value is every percentile of itself.
+func percentileValue(vals []float64, p float64) float64 {
+	if len(vals) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), vals...)
+	sort.Float64s(sorted)
+	i := int(math.Ceil(p*float64(len(sorted)))) - 1
+	return sorted[min(max(i, 0), len(sorted)-1)]
+}
+
+// seriesJSON nests each named series under its group, e.g. {"Team A": {"created": [...], "resolved": [...]}},
+// aligned to weeks. Groups are ranked by how many values they hold across all series; past g.limit the
+// smallest are merged into Other before reduce runs, so Other's averages are true averages.
+func (g *issueGrouping) seriesJSON(weeks []string, reduce func([]float64) float64, series map[string]groupedSeries) (gin.H, gin.H) {
+	sizes := make(map[string]int)
+	for _, s := range series {
+		for group, byWeek := range s {
+			for _, vals := range byWeek {
+				sizes[group] += len(vals)
+			}
+		}
+	}
+	ranked := make([]string, 0, len(sizes))
+	for group := range sizes {
+		ranked = append(ranked, group)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if sizes[ranked[i]] != sizes[ranked[j]] {
+			return sizes[ranked[i]] > sizes[ranked[j]]
+		}
+		return ranked[i] < ranked[j]
+	})
+	target := make(map[string]string, len(ranked))
+	rolledUp := 0
+	for i, group := range ranked {
+		if len(ranked) > g.limit && i >= g.limit-1 {
+			target[group] = groupOther
+			rolledUp++
+		} else {
+			target[group] = group
+		}
+	}
+
+	groups := gin.H{}
+	for name, s := range series {
+		merged := make(map[string]map[string][]float64)
+		for _, group := range ranked {
+			out := target[group]
+			if merged[out] == nil {
+				merged[out] = make(map[string][]float64)
+			}
+			for week, vals := range s[group] {
+				merged[out][week] = append(merged[out][week], vals...)
+			}
+		}
+		for out, byWeek := range merged {
+			values := make([]float64, len(weeks))
+			for i, w := range weeks {
+				values[i] = reduce(byWeek[w])
+			}
+			if groups[out] == nil {
+				groups[out] = gin.H{}
+			}
+			groups[out].(gin.H)[name] = values
+		}
+	}
+	meta := gin.H{
+		"group_by":         g.by,
+		"group_field":      g.field,
+		"group_limit":      g.limit,
+		"groups_total":     len(ranked),
+		"groups_rolled_up": rolledUp,
+	}
+	return groups, meta
+}
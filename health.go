@@ -0,0 +1,271 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// version and buildTime are set at build time (make build):
+// go build -ldflags "-X main.version=<git describe> -X main.buildTime=<RFC 3339>".
+var (
+	version   = ""
+	buildTime = ""
+)
+
+// buildInfo returns version and build time, falling back to the VCS stamp Go embeds when ldflags weren't set.
+func buildInfo() (ver, built string) {
+	ver, built = version, buildTime
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range info.Settings {
+			switch {
+			case s.Key == "vcs.revision" && ver == "":
+				ver = s.Value
+			case s.Key == "vcs.time" && built == "":
+				built = s.Value
+			}
+		}
+	}
+	if ver == "" {
+		ver = "dev"
+	}
+	return ver, built
+}
+
+// listenPort is the HTTP port from PORT (default 8082).
+func listenPort() string {
+	if port := os.Getenv("PORT"); port != "" {
+		return port
+	}
+	return "8082"
+}
+
+// GET /api/health – readiness probe: which integrations are configured (env present), without calling them.
+// Always 200 while the server is up; "configured": false means missing config, not an outage.
+func health(c *gin.Context) {
+	integration := func(missing []string) gin.H {
+		if missing == nil {
+			missing = []string{}
+		}
+		return gin.H{"configured": len(missing) == 0, "missing": missing}
+	}
+	ver, built := buildInfo()
+	c.JSON(http.StatusOK, gin.H{
+		"status": "ok",
+		"integrations": gin.H{
+			"jira":      integration(jiraConfigMissing()),
+			"buildkite": integration(buildkiteConfigMissing()),
+			"fleetio":   integration(fleetioConfigMissing()),
+			"neuron":    integration(neuronConfigMissing()),
+		},
+		"version":    ver,
+		"build_time": built,
+		"port":       listenPort(),
+	})
+}
+
+// dotenvLoaded records whether main found and loaded a .env file; /api/config reports it.
+var dotenvLoaded bool
+
+// envVarsSet maps each env var name to whether it is set to a non-blank value. Values are never included.
+func envVarsSet(names ...string) gin.H {
+	out := gin.H{}
+	for _, name := range names {
+		out[name] = strings.TrimSpace(os.Getenv(name)) != ""
+	}
+	return out
+}
+
+// urlHost returns just the host of rawURL (no scheme, path or credentials), or "" when it doesn't parse.
+func urlHost(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
+
+// GET /api/config – what configuration the process resolved, for "is my .env loaded?" debugging. Secrets are
+// only ever reported as set/unset; URLs are reduced to their host.
+func configDump(c *gin.Context) {
+	jiraAPIURL, _, _, jiraOK := jiraConfig()
+	jiraMode, _ := jiraAuthMode()
+	jiraHost := "" // from JIRA_DOMAIN alone, so a half-configured JIRA still shows which site it would call
+	if domain := strings.TrimSpace(os.Getenv("JIRA_DOMAIN")); domain != "" {
+		jiraHost = domain + ".atlassian.net"
+	}
+	neuronURL, _, neuronOK := neuronConfig()
+	lakehouseURL, _, lakehouseOK := lakehouseConfig()
+	_, _, fleetioOK := fleetioConfig()
+	_, _, bkOK := buildkiteConfig()
+	env := os.Getenv("ENV")
+	if env == "" {
+		env = "production"
+	}
+	ver, built := buildInfo()
+	c.JSON(http.StatusOK, gin.H{
+		"env":             env,
+		"port":            listenPort(),
+		"dotenv_loaded":   dotenvLoaded,
+		"version":         ver,
+		"build_time":      built,
+		"bucket_timezone": bucketLocation.String(),
+		"integrations": gin.H{
+			"jira": gin.H{
+				"configured": jiraOK,
+				"auth_mode":  jiraMode,
+				"env":        envVarsSet("JIRA_DOMAIN", "JIRA_AUTH_MODE", "JIRA_EMAIL", "JIRA_API_TOKEN", "JIRA_BEARER_TOKEN", "JIRA_CLOUD_ID"),
+				"host":       jiraHost,
+				"api_host":   urlHost(jiraAPIURL), // the site in basic mode, the API gateway in bearer mode
+			},
+			"buildkite": gin.H{
+				"configured": bkOK,
+				"env":        envVarsSet("BUILDKITE_TOKEN", "BUILDKITE_ORG", "BUILDKITE_PIPELINES"),
+				"orgs":       buildkiteOrgs(),
+				"pipelines":  buildkiteDeploymentPipelines,
+			},
+			"fleetio": gin.H{
+				"configured": fleetioOK,
+				"env":        envVarsSet("FLEETIO_ACCOUNT_TOKEN", "FLEETIO_API_KEY"),
+			},
+			"neuron": gin.H{
+				"configured": neuronOK,
+				"env":        envVarsSet("NEURON_API_URL", "NEURON_API_TOKEN"),
+				"host":       urlHost(neuronURL),
+			},
+			"lakehouse": gin.H{
+				"configured": lakehouseOK,
+				"env":        envVarsSet("LAKEHOUSE_API_URL", "LAKEHOUSE_API_TOKEN"),
+				"host":       urlHost(lakehouseURL),
+			},
+		},
+	})
+}
+
+// healthDeepTimeout bounds each upstream call in the deep health check.
+const healthDeepTimeout = 5 * time.Second
+
+// healthProbe is one deep-check call: configured reports whether to run it, call issues the request. A nil
+// call means there is no known endpoint to verify the credentials against yet.
+type healthProbe struct {
+	configured bool
+	call       func(ctx context.Context) (*http.Response, error)
+}
+
+// getWithHeaders issues a GET with the given headers and drains the body so the connection can be reused.
+func getWithHeaders(ctx context.Context, rawURL string, headers map[string]string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	return resp, nil
+}
+
+// healthProbes builds one lightweight authenticated call per integration.
+func healthProbes() map[string]healthProbe {
+	jiraURL, jiraEmail, jiraToken, jiraOK := jiraConfig()
+	bkToken, bkOrg, bkOK := buildkiteConfig()
+	fleetioAccount, fleetioKey, fleetioOK := fleetioConfig()
+	_, _, neuronOK := neuronConfig()
+	return map[string]healthProbe{
+		"jira": {jiraOK, func(ctx context.Context) (*http.Response, error) {
+			resp, _, err := jiraAPIReq(ctx, jiraURL, jiraEmail, jiraToken, http.MethodGet, "/rest/api/3/myself", nil)
+			return resp, err
+		}},
+		"buildkite": {bkOK, func(ctx context.Context) (*http.Response, error) {
+			return getWithHeaders(ctx, buildkiteBaseURL+"/organizations/"+bkOrg, map[string]string{
+				"Authorization": "Bearer " + bkToken,
+				"Accept":        "application/json",
+			})
+		}},
+		"fleetio": {fleetioOK, func(ctx context.Context) (*http.Response, error) {
+			return getWithHeaders(ctx, fleetioBaseURL+"/users/me", map[string]string{
+				"Authorization": "Token " + fleetioKey,
+				"Account-Token": fleetioAccount,
+				"Accept":        "application/json",
+			})
+		}},
+		// Neuron's auth check path isn't known yet (see docs/neuron-api-discovery.md); probing a guessed one
+		// would report the integration down forever, so it's reported "unverified" until one is found
+		"neuron": {neuronOK, nil},
+	}
+}
+
+// GET /api/health/deep – calls each configured integration concurrently (5s timeout each) and reports
+// status, HTTP status and latency. Unconfigured integrations are "skipped", and configured ones without a
+// check endpoint (Neuron) "unverified"; neither degrades the result. Returns 503 when any check fails,
+// so expired tokens show up before a dashboard load fails.
+func healthDeep(c *gin.Context) {
+	results := gin.H{}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	failed := false
+	for name, probe := range healthProbes() {
+		if !probe.configured {
+			mu.Lock()
+			results[name] = gin.H{"status": "skipped"}
+			mu.Unlock()
+			continue
+		}
+		if probe.call == nil {
+			mu.Lock()
+			results[name] = gin.H{"status": "unverified", "note": "configured, but there is no known endpoint to check the credentials against"}
+			mu.Unlock()
+			continue
+		}
+		wg.Add(1)
+		go func(name string, probe healthProbe) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(c.Request.Context(), healthDeepTimeout)
+			defer cancel()
+			start := time.Now()
+			resp, err := probe.call(ctx)
+			result := gin.H{"latency_ms": time.Since(start).Milliseconds(), "http_status": nil}
+			switch {
+			case err != nil:
+				result["status"] = "error"
+				result["error"] = err.Error()
+			case resp.StatusCode != http.StatusOK:
+				result["status"] = "error"
+				result["http_status"] = resp.StatusCode
+			default:
+				result["status"] = "ok"
+				result["http_status"] = resp.StatusCode
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			results[name] = result
+			if result["status"] == "error" {
+				failed = true
+			}
+		}(name, probe)
+	}
+	wg.Wait()
+
+	status, code := "ok", http.StatusOK
+	if failed {
+		status, code = "degraded", http.StatusServiceUnavailable
+	}
+	c.JSON(code, gin.H{
+		"status":       status,
+		"integrations": results,
+		"timeout_sec":  int(healthDeepTimeout.Seconds()),
+	})
+}
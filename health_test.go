@@ -0,0 +1,33 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestHealthDeepReportsNeuronUnverified(t *testing.T) {
+	for _, name := range []string{"JIRA_DOMAIN", "BUILDKITE_TOKEN", "FLEETIO_API_KEY"} {
+		t.Setenv(name, "")
+	}
+	t.Setenv("NEURON_API_TOKEN", "token")
+	calls := stubUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	})
+	c, rec := testContext("/api/health/deep")
+	healthDeep(c)
+
+	var body struct {
+		Status       string
+		Integrations map[string]struct{ Status string }
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusOK || body.Status != "ok" || body.Integrations["neuron"].Status != "unverified" {
+		t.Errorf("status %d: %s; want ok with neuron unverified", rec.Code, rec.Body.String())
+	}
+	if got := calls.Load(); got != 0 {
+		t.Errorf("upstream calls = %d, want none", got)
+	}
+}
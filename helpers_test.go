@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	os.Exit(m.Run())
+}
+
+// stubTransport sends every request to target, whatever host it was for, and counts them.
+type stubTransport struct {
+	target *url.URL
+	calls  *atomic.Int32
+}
+
+func (t stubTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.calls.Add(1)
+	req = req.Clone(req.Context())
+	req.URL.Scheme, req.URL.Host = t.target.Scheme, t.target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// stubUpstream serves every upstream call (JIRA, BuildKite, Neuron...) from handler for the rest of the test by
+// swapping httpClient. The returned counter is the number of requests sent.
+func stubUpstream(t *testing.T, handler http.HandlerFunc) *atomic.Int32 {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	target, _ := url.Parse(srv.URL)
+	calls := &atomic.Int32{}
+	prev := httpClient
+	httpClient = &http.Client{Transport: stubTransport{target: target, calls: calls}}
+	t.Cleanup(func() { httpClient = prev })
+	return calls
+}
+
+// setForTest sets *p to v for the rest of the test.
+func setForTest[T any](t *testing.T, p *T, v T) {
+	t.Helper()
+	prev := *p
+	*p = v
+	t.Cleanup(func() { *p = prev })
+}
@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -17,8 +18,9 @@ import (
 // https://developer.atlassian.com/cloud/jira/platform/rest/v3/api-group-issue-search/
 
 type jiraSearchResponse struct {
-	Issues []jiraIssue `json:"issues"`
-	Total  int         `json:"total"`
+	Issues          []jiraIssue `json:"issues"`
+	Total           int         `json:"total"`
+	WarningMessages []string    `json:"warningMessages"` // JIRA can return 200 with warnings (e.g. unknown field/value in JQL)
 }
 
 type jiraIssue struct {
@@ -46,31 +48,124 @@ type JIRAIssue struct {
 	Updated  string `json:"updated"`
 }
 
+// JIRA_AUTH_MODE picks how requests authenticate: "basic" (default) sends JIRA_EMAIL and JIRA_API_TOKEN,
+// "bearer" sends JIRA_BEARER_TOKEN (an OAuth 2.0 access token) as Authorization: Bearer. OAuth tokens are only
+// accepted by the Atlassian API gateway, so bearer mode also needs JIRA_CLOUD_ID.
+const (
+	jiraAuthBasic  = "basic"
+	jiraAuthBearer = "bearer"
+)
+
+// jiraAuthMode is JIRA_AUTH_MODE lowercased; ok is false for anything but basic or bearer.
+func jiraAuthMode() (mode string, ok bool) {
+	mode = strings.ToLower(strings.TrimSpace(os.Getenv("JIRA_AUTH_MODE")))
+	if mode == "" {
+		return jiraAuthBasic, true
+	}
+	return mode, mode == jiraAuthBasic || mode == jiraAuthBearer
+}
+
+// jiraGatewayURL is the Atlassian API gateway that OAuth 2.0 (bearer) requests go through, followed by the cloud ID.
+const jiraGatewayURL = "https://api.atlassian.com/ex/jira/"
+
+// jiraConfig returns the JIRA REST API base URL and credentials. In basic mode the base URL is the site; in
+// bearer mode it is the API gateway for JIRA_CLOUD_ID, email is empty and token is the bearer token.
+// jiraAuthorization turns them into the header either way. Links for people use jiraBrowseURL instead.
 func jiraConfig() (baseURL, email, token string, ok bool) {
 	domain := strings.TrimSpace(os.Getenv("JIRA_DOMAIN"))
-	email = strings.TrimSpace(os.Getenv("JIRA_EMAIL"))
-	token = strings.TrimSpace(os.Getenv("JIRA_API_TOKEN"))
-	if domain == "" || email == "" || token == "" {
+	mode, modeOK := jiraAuthMode()
+	cloudID := strings.TrimSpace(os.Getenv("JIRA_CLOUD_ID"))
+	if mode == jiraAuthBearer {
+		token = strings.TrimSpace(os.Getenv("JIRA_BEARER_TOKEN"))
+	} else {
+		email = strings.TrimSpace(os.Getenv("JIRA_EMAIL"))
+		token = strings.TrimSpace(os.Getenv("JIRA_API_TOKEN"))
+	}
+	if domain == "" || !modeOK || token == "" || (mode == jiraAuthBasic && email == "") || (mode == jiraAuthBearer && cloudID == "") {
 		return "", "", "", false
 	}
-	baseURL = "https://" + domain + ".atlassian.net"
-	return baseURL, email, token, true
+	if mode == jiraAuthBearer {
+		return jiraGatewayURL + url.PathEscape(cloudID), email, token, true
+	}
+	return jiraSiteURL(), email, token, true
+}
+
+// jiraSiteURL is the JIRA site people browse (https://<JIRA_DOMAIN>.atlassian.net), whatever the auth mode.
+func jiraSiteURL() string {
+	return "https://" + strings.TrimSpace(os.Getenv("JIRA_DOMAIN")) + ".atlassian.net"
 }
 
+// jiraBrowseURL is the link to an issue on the JIRA site.
+func jiraBrowseURL(key string) string {
+	return jiraSiteURL() + "/browse/" + key
+}
+
+// jiraConfigMissing lists the env vars the current JIRA_AUTH_MODE still needs (JIRA_AUTH_MODE itself when invalid).
 func jiraConfigMissing() []string {
 	var missing []string
 	if strings.TrimSpace(os.Getenv("JIRA_DOMAIN")) == "" {
 		missing = append(missing, "JIRA_DOMAIN")
 	}
-	if strings.TrimSpace(os.Getenv("JIRA_EMAIL")) == "" {
-		missing = append(missing, "JIRA_EMAIL")
-	}
-	if strings.TrimSpace(os.Getenv("JIRA_API_TOKEN")) == "" {
-		missing = append(missing, "JIRA_API_TOKEN")
+	mode, ok := jiraAuthMode()
+	switch {
+	case !ok:
+		missing = append(missing, "JIRA_AUTH_MODE")
+	case mode == jiraAuthBearer:
+		if strings.TrimSpace(os.Getenv("JIRA_BEARER_TOKEN")) == "" {
+			missing = append(missing, "JIRA_BEARER_TOKEN")
+		}
+		if strings.TrimSpace(os.Getenv("JIRA_CLOUD_ID")) == "" {
+			missing = append(missing, "JIRA_CLOUD_ID")
+		}
+	default:
+		if strings.TrimSpace(os.Getenv("JIRA_EMAIL")) == "" {
+			missing = append(missing, "JIRA_EMAIL")
+		}
+		if strings.TrimSpace(os.Getenv("JIRA_API_TOKEN")) == "" {
+			missing = append(missing, "JIRA_API_TOKEN")
+		}
 	}
 	return missing
 }
 
+// jiraAuthorization is the Authorization header value for credentials from jiraConfig.
+func jiraAuthorization(email, token string) string {
+	if mode, _ := jiraAuthMode(); mode == jiraAuthBearer {
+		return "Bearer " + token
+	}
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(email+":"+token))
+}
+
+// jiraSearchDefaultFields are the fields behind the typed JIRAIssue shape.
+var jiraSearchDefaultFields = []string{"summary", "status", "created", "updated"}
+
+// parseSearchFields reads ?fields= (comma-separated). custom is false when it is omitted or names exactly the
+// default fields, so the typed JIRAIssue response is kept.
+func parseSearchFields(c *gin.Context) (fields []string, custom bool) {
+	seen := make(map[string]bool)
+	for _, f := range strings.Split(c.Query("fields"), ",") {
+		if f = strings.TrimSpace(f); f != "" && !seen[f] {
+			seen[f] = true
+			fields = append(fields, f)
+		}
+	}
+	if len(fields) == 0 {
+		return jiraSearchDefaultFields, false
+	}
+	if len(fields) != len(jiraSearchDefaultFields) {
+		return fields, true
+	}
+	for _, f := range jiraSearchDefaultFields {
+		if !seen[f] {
+			return fields, true
+		}
+	}
+	return fields, false
+}
+
+// GET /api/jira/search?jql=&maxResults=&startAt=&fields= – ad-hoc JQL search. Without ?fields= (or with exactly
+// the defaults) issues have the typed JIRAIssue shape; other fields come back as {key, fields} with JIRA's raw
+// field values. total is JIRA's match count, for paging with startAt.
 func jiraSearch(c *gin.Context) {
 	baseURL, email, token, ok := jiraConfig()
 	if !ok {
@@ -78,7 +173,7 @@ func jiraSearch(c *gin.Context) {
 		c.JSON(http.StatusServiceUnavailable, gin.H{
 			"error":   "JIRA not configured",
 			"missing": missing,
-			"hint":    "Export JIRA_DOMAIN, JIRA_EMAIL, and JIRA_API_TOKEN in the same terminal before running the backend",
+			"hint":    "Export JIRA_DOMAIN and JIRA_EMAIL/JIRA_API_TOKEN (or JIRA_AUTH_MODE=bearer with JIRA_BEARER_TOKEN) in the same terminal before running the backend",
 		})
 		return
 	}
@@ -86,12 +181,19 @@ func jiraSearch(c *gin.Context) {
 	// Default JQL must include a restriction (e.g. date or project); unbounded queries return 400
 	jql := c.DefaultQuery("jql", "created >= -180d order by created DESC")
 	maxResults := c.DefaultQuery("maxResults", "50")
+	startAt, err := strconv.Atoi(c.DefaultQuery("startAt", "0"))
+	if err != nil || startAt < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid startAt %q (want a non-negative integer)", c.Query("startAt"))})
+		return
+	}
+	fields, customFields := parseSearchFields(c)
 
 	// Use /rest/api/3/search/jql (old /rest/api/3/search removed, CHANGE-2046)
 	apiURL := baseURL + "/rest/api/3/search/jql?" + url.Values{
 		"jql":        {jql},
 		"maxResults": {maxResults},
-		"fields":     {"summary,status,created,updated"},
+		"startAt":    {strconv.Itoa(startAt)},
+		"fields":     {strings.Join(fields, ",")},
 	}.Encode()
 
 	req, err := http.NewRequestWithContext(c.Request.Context(), http.MethodGet, apiURL, nil)
@@ -102,12 +204,11 @@ func jiraSearch(c *gin.Context) {
 
 	req.Header.Set("Accept", "application/json")
 	req.Header.Set("Content-Type", "application/json")
-	auth := base64.StdEncoding.EncodeToString([]byte(email + ":" + token))
-	req.Header.Set("Authorization", "Basic "+auth)
+	req.Header.Set("Authorization", jiraAuthorization(email, token))
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := httpClient.Do(req)
 	if err != nil {
-		c.JSON(http.StatusBadGateway, gin.H{"error": "JIRA request failed: " + err.Error()})
+		c.JSON(upstreamFailureStatus(err), upstreamErrorBody("JIRA request failed: ", err))
 		return
 	}
 	defer resp.Body.Close()
@@ -120,8 +221,9 @@ func jiraSearch(c *gin.Context) {
 
 	if resp.StatusCode != http.StatusOK {
 		c.JSON(resp.StatusCode, gin.H{
-			"error":  fmt.Sprintf("JIRA API returned %d", resp.StatusCode),
-			"detail": string(body),
+			"error":           fmt.Sprintf("JIRA API returned %d", resp.StatusCode),
+			"detail":          string(body),
+			"upstream_status": resp.StatusCode,
 		})
 		return
 	}
@@ -132,19 +234,124 @@ func jiraSearch(c *gin.Context) {
 		return
 	}
 
-	issues := make([]JIRAIssue, 0, len(search.Issues))
-	for _, i := range search.Issues {
-		issues = append(issues, JIRAIssue{
-			Key:     i.Key,
-			Summary: i.Fields.Summary,
-			Status:  i.Fields.Status.Name,
-			Created: i.Fields.Created,
-			Updated: i.Fields.Updated,
-		})
+	out := gin.H{
+		"total":   search.Total,
+		"startAt": startAt,
+	}
+	if customFields {
+		var raw struct {
+			Issues []struct {
+				Key    string                 `json:"key"`
+				Fields map[string]interface{} `json:"fields"`
+			} `json:"issues"`
+		}
+		if err := json.Unmarshal(body, &raw); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid JIRA response: " + err.Error()})
+			return
+		}
+		issues := make([]gin.H, 0, len(raw.Issues))
+		for _, i := range raw.Issues {
+			issues = append(issues, gin.H{"key": i.Key, "fields": i.Fields})
+		}
+		out["issues"] = issues
+		out["fields"] = fields
+	} else {
+		issues := make([]JIRAIssue, 0, len(search.Issues))
+		for _, i := range search.Issues {
+			issues = append(issues, JIRAIssue{
+				Key:     i.Key,
+				Summary: i.Fields.Summary,
+				Status:  i.Fields.Status.Name,
+				Created: i.Fields.Created,
+				Updated: i.Fields.Updated,
+			})
+		}
+		out["issues"] = issues
+	}
+	if len(search.WarningMessages) > 0 {
+		logf(c.Request.Context(), "JIRA", "Search returned 200 with warnings for JQL %q: %s", jql, strings.Join(search.WarningMessages, "; "))
+		out["warnings"] = search.WarningMessages
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// jiraFilterPageSize and jiraFilterMaxPages bound /api/jira/filters' walk through JIRA's filter search.
+const (
+	jiraFilterPageSize = 100
+	jiraFilterMaxPages = 20
+)
+
+// JIRAFilter is a saved filter as returned by /api/jira/filters
+type JIRAFilter struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	JQL   string `json:"jql"`
+	Owner string `json:"owner,omitempty"`
+}
+
+// GET /api/jira/filters?query= – saved filters the configured user can see (id, name, jql), for picking a
+// filter_id. query is passed to JIRA as a name filter; all pages of the filter search are followed.
+func jiraFilters(c *gin.Context) {
+	baseURL, email, token, ok := jiraConfig()
+	if !ok {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "JIRA not configured", "missing": jiraConfigMissing()})
+		return
+	}
+
+	filters := []JIRAFilter{}
+	truncated := false
+	for page, startAt := 0, 0; ; page++ {
+		if page == jiraFilterMaxPages {
+			truncated = true
+			break
+		}
+		q := url.Values{}
+		q.Set("expand", "jql,owner")
+		q.Set("startAt", fmt.Sprintf("%d", startAt))
+		q.Set("maxResults", fmt.Sprintf("%d", jiraFilterPageSize))
+		if name := strings.TrimSpace(c.Query("query")); name != "" {
+			q.Set("filterName", name)
+		}
+		resp, body, err := jiraAPIReq(c.Request.Context(), baseURL, email, token, http.MethodGet, "/rest/api/3/filter/search", q)
+		if err != nil {
+			c.JSON(upstreamFailureStatus(err), upstreamErrorBody("filter search: ", err))
+			return
+		}
+		if resp.StatusCode != http.StatusOK {
+			c.JSON(resp.StatusCode, gin.H{
+				"error":           fmt.Sprintf("JIRA API returned %d", resp.StatusCode),
+				"detail":          string(body),
+				"upstream_status": resp.StatusCode,
+			})
+			return
+		}
+		var result struct {
+			Values []struct {
+				ID    string `json:"id"`
+				Name  string `json:"name"`
+				JQL   string `json:"jql"`
+				Owner struct {
+					DisplayName string `json:"displayName"`
+				} `json:"owner"`
+			} `json:"values"`
+			IsLast bool `json:"isLast"`
+		}
+		if err := json.Unmarshal(body, &result); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid JIRA response: " + err.Error()})
+			return
+		}
+		for _, f := range result.Values {
+			filters = append(filters, JIRAFilter{ID: f.ID, Name: f.Name, JQL: f.JQL, Owner: f.Owner.DisplayName})
+		}
+		if result.IsLast || len(result.Values) == 0 {
+			break
+		}
+		startAt += len(result.Values)
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"total":  search.Total,
-		"issues": issues,
+		"filters":   filters,
+		"total":     len(filters),
+		"truncated": truncated,
 	})
 }
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// jiraExportMaxIssues bounds one export (JIRA_EXPORT_MAX_ISSUES, default 20000); past it the trailer reports truncated.
+var jiraExportMaxIssues = 20000
+
+// jiraExportWindowMonths is each jql_key's default ?months=, matching its KPI.
+func jiraExportWindowMonths() map[string]int {
+	return map[string]int{"vos": vosWindowMonths, "build_bugs": buildBugsWindowMonths, "mtbf": mtbfWindowMonths}
+}
+
+// GET /api/jira/export?jql_key=vos|build_bugs|mtbf[&months=1-12] – every issue the KPI's base JQL created in the
+// window, streamed as NDJSON: one issue per line, written and flushed page by page so memory stays bounded and
+// the client can start before the last page. The final line is {"meta": {...}} with the count, whether the
+// export was truncated, and any error that cut it short (the status is already 200 by then).
+func jiraExport(c *gin.Context) {
+	baseURL, email, token, ok := jiraConfig()
+	if !ok {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "JIRA not configured", "missing": jiraConfigMissing()})
+		return
+	}
+	jqlKey := c.Query("jql_key")
+	setting, ok := jiraQuerySettingsByKey()[jqlKey]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid jql_key %q (want vos, build_bugs, mtbf)", jqlKey)})
+		return
+	}
+	months, err := parseWindowMonths(c, jiraExportWindowMonths()[jqlKey])
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	baseJQL, portfolioParent, err := withPortfolioParent(c, setting.value)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	from := requestNow(c).In(bucketLocation).AddDate(0, -months, 0)
+	window := fmt.Sprintf("created >= '%s'", from.Format("2006-01-02"))
+	// Under ?now= the window also ends at the pinned time (in bucketLocation, as createdWindowJQL writes it), so
+	// re-running the export later doesn't pick up issues created since
+	createdBefore := ""
+	if now, ok := nowOverride(ctx); ok {
+		createdBefore = bucketTime(now).Format("2006-01-02 15:04")
+		window += fmt.Sprintf(" AND created < '%s'", createdBefore)
+	}
+	jql := fmt.Sprintf("(%s) AND %s ORDER BY created ASC, key ASC", baseJQL, window)
+	fields := []string{"key", "summary", "status", "created", "resolutiondate"}
+	fetchPage := func(startAt int) (page []map[string]interface{}, total *int, err error) {
+		err = retryJIRA(ctx, func() error {
+			var err error
+			page, total, err = searchJQLWithTotal(ctx, baseURL, email, token, jql, fields, vosTicketsMaxResults, startAt, "")
+			return err
+		})
+		return page, total, err
+	}
+
+	// The first page decides the status: failing before anything is written still gets a JSON error
+	page, total, err := fetchPage(0)
+	if err != nil {
+		c.JSON(upstreamFailureStatus(err), upstreamErrorBody("issue search: ", err))
+		return
+	}
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s-export.ndjson", jqlKey))
+	c.Status(http.StatusOK)
+	enc := json.NewEncoder(c.Writer)
+
+	count, truncated := 0, false
+	for startAt := 0; ; {
+		for _, issue := range page {
+			key, _ := issue["key"].(string)
+			if err = enc.Encode(weekIssue{
+				Key:            key,
+				Summary:        getFieldString(issue, "fields.summary"),
+				Status:         getFieldString(issue, "fields.status.name"),
+				Created:        getFieldString(issue, "fields.created"),
+				ResolutionDate: getFieldString(issue, "fields.resolutiondate"),
+				URL:            jiraBrowseURL(key),
+			}); err != nil {
+				break
+			}
+			count++
+		}
+		if err != nil {
+			break
+		}
+		c.Writer.Flush()
+		startAt += len(page)
+		if len(page) < vosTicketsMaxResults || (total != nil && startAt >= *total) {
+			break
+		}
+		if count >= jiraExportMaxIssues {
+			truncated = true
+			break
+		}
+		if err = ctx.Err(); err != nil {
+			break
+		}
+		if page, total, err = fetchPage(startAt); err != nil {
+			break
+		}
+	}
+
+	meta := gin.H{
+		"jql_key":          jqlKey,
+		"jql_used":         jql,
+		"portfolio_parent": portfolioParent,
+		"window_months":    months,
+		"count":            count,
+		"truncated":        truncated,
+		"max_issues":       jiraExportMaxIssues,
+	}
+	if createdBefore != "" {
+		meta["created_before"] = createdBefore
+	}
+	if err != nil {
+		logf(ctx, "Export", "Stopped %s export after %d issues: %v", jqlKey, count, err)
+		meta["error"] = err.Error()
+	}
+	addJIRAWarnings(c, meta)
+	if ctx.Err() == nil {
+		_ = enc.Encode(gin.H{"meta": meta})
+		c.Writer.Flush()
+	}
+}
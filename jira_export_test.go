@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Under ?now= the export window ends at the pinned time, so a re-run later streams the same issues.
+func TestJIRAExportEndsAtPinnedNow(t *testing.T) {
+	t.Setenv("ENV", "dev")
+	setJIRAEnv(t)
+	la, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Skip(err)
+	}
+	withBucketLocation(t, la)
+	var jql string
+	stubUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		jql = r.URL.Query().Get("jql")
+		w.Write([]byte(`{"issues": [{"key": "VOS-1", "fields": {"summary": "s", "created": "2024-05-01T00:00:00.000+0000"}}], "total": 1}`))
+	})
+
+	rec := httptest.NewRecorder()
+	newRouter().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/jira/export?jql_key=vos&now=2024-05-15T03:00:00Z", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body.String())
+	}
+	if !strings.Contains(jql, "created < '2024-05-14 20:00'") {
+		t.Errorf("jql = %s, want it to end at the pinned time in the bucket zone", jql)
+	}
+	lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+	var last struct {
+		Meta struct {
+			CreatedBefore string `json:"created_before"`
+			Count         int    `json:"count"`
+		} `json:"meta"`
+	}
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &last); err != nil {
+		t.Fatal(err)
+	}
+	if last.Meta.CreatedBefore != "2024-05-14 20:00" || last.Meta.Count != 1 {
+		t.Errorf("meta = %+v, want created_before 2024-05-14 20:00 and 1 issue", last.Meta)
+	}
+}
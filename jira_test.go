@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// setJIRAEnv configures basic-auth JIRA for the rest of the test.
+func setJIRAEnv(t *testing.T) {
+	t.Helper()
+	t.Setenv("JIRA_DOMAIN", "example")
+	t.Setenv("JIRA_EMAIL", "dev@example.com")
+	t.Setenv("JIRA_API_TOKEN", "token")
+	t.Setenv("JIRA_AUTH_MODE", "")
+}
+
+const warningSearchResponse = `{
+	"issues": [{"key": "VOS-1", "fields": {"summary": "s", "status": {"name": "Open"}, "created": "2024-05-01T00:00:00.000+0000"}}],
+	"total": 1,
+	"warningMessages": ["The value 'NOPE' does not exist for the field 'project'."]
+}`
+
+func TestSearchWarningsSurfaceInMeta(t *testing.T) {
+	stubUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(warningSearchResponse))
+	})
+	c, _ := testContext("/api/kpi/vos-tickets")
+	c.Request = c.Request.WithContext(withRequestValues(c.Request.Context()))
+
+	// Two searches with the same warning report it once
+	for i := 0; i < 2; i++ {
+		issues, err := searchJQL(c.Request.Context(), "https://example.atlassian.net", "e", "t", "project in (NOPE, VOS)", nil, 50, 0, "")
+		if err != nil || len(issues) != 1 {
+			t.Fatalf("searchJQL = %d issues, %v", len(issues), err)
+		}
+	}
+	meta := gin.H{}
+	addJIRAWarnings(c, meta)
+	want := []string{"The value 'NOPE' does not exist for the field 'project'."}
+	if !reflect.DeepEqual(meta["jira_warnings"], want) {
+		t.Errorf("jira_warnings = %v, want %v", meta["jira_warnings"], want)
+	}
+}
+
+func TestSearchWithoutWarningsLeavesMetaAlone(t *testing.T) {
+	stubUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"issues": [{"key": "VOS-2", "fields": {}}], "total": 1}`))
+	})
+	ctx := withRequestValues(context.Background())
+	if _, _, err := searchJQLWithTotal(ctx, "https://example.atlassian.net", "e", "t", "project = VOS", nil, 50, 0, ""); err != nil {
+		t.Fatal(err)
+	}
+	c, _ := testContext("/api/kpi/vos-tickets")
+	c.Request = c.Request.WithContext(ctx)
+	meta := gin.H{}
+	addJIRAWarnings(c, meta)
+	if _, ok := meta["jira_warnings"]; ok {
+		t.Errorf("meta = %v, want no jira_warnings", meta)
+	}
+}
+
+func TestJIRASearchEndpointReturnsWarnings(t *testing.T) {
+	setJIRAEnv(t)
+	stubUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(warningSearchResponse))
+	})
+	c, rec := testContext("/api/jira/search?jql=project+in+(NOPE,VOS)")
+	jiraSearch(c)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body.String())
+	}
+	var body struct {
+		Warnings []string `json:"warnings"`
+		Total    int      `json:"total"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Warnings) != 1 || body.Total != 1 {
+		t.Errorf("warnings = %v, total = %d", body.Warnings, body.Total)
+	}
+}
+
+func TestJIRABearerModeCallsTheAPIGateway(t *testing.T) {
+	t.Setenv("JIRA_DOMAIN", "example")
+	t.Setenv("JIRA_AUTH_MODE", "bearer")
+	t.Setenv("JIRA_BEARER_TOKEN", "oauth-token")
+	t.Setenv("JIRA_CLOUD_ID", "")
+	if _, _, _, ok := jiraConfig(); ok {
+		t.Fatal("bearer mode without JIRA_CLOUD_ID reported configured")
+	}
+	if missing := jiraConfigMissing(); !reflect.DeepEqual(missing, []string{"JIRA_CLOUD_ID"}) {
+		t.Errorf("missing = %v, want [JIRA_CLOUD_ID]", missing)
+	}
+
+	t.Setenv("JIRA_CLOUD_ID", "abc-123")
+	var path, auth string
+	stubUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		path, auth = r.URL.Path, r.Header.Get("Authorization")
+		w.Write([]byte(warningSearchResponse))
+	})
+	c, rec := testContext("/api/jira/search?jql=project+%3D+VOS")
+	jiraSearch(c)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body.String())
+	}
+	if path != "/ex/jira/abc-123/rest/api/3/search/jql" || auth != "Bearer oauth-token" {
+		t.Errorf("request %s with %q, want the gateway path with the bearer token", path, auth)
+	}
+	if got := jiraBrowseURL("VOS-1"); got != "https://example.atlassian.net/browse/VOS-1" {
+		t.Errorf("browse URL = %s, want the site", got)
+	}
+}
@@ -1,18 +1,24 @@
 package main
 
 import (
-	"encoding/base64"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"math"
 	"net/http"
 	"net/url"
+	"os"
+	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
+	_ "time/tzdata" // TZ_LOCATION works on images without a zoneinfo database
+	"unicode"
 
 	"github.com/gin-gonic/gin"
 )
@@ -30,19 +36,19 @@ const (
 )
 
 // jiraAPI runs an authenticated request to JIRA.
-func jiraAPIReq(c *gin.Context, baseURL, email, token, method, path string, query url.Values) (*http.Response, []byte, error) {
+func jiraAPIReq(ctx context.Context, baseURL, email, token, method, path string, query url.Values) (*http.Response, []byte, error) {
 	rawURL := baseURL + path
 	if len(query) > 0 {
 		rawURL += "?" + query.Encode()
 	}
-	req, err := http.NewRequestWithContext(c.Request.Context(), method, rawURL, nil)
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, nil)
 	if err != nil {
 		return nil, nil, err
 	}
 	req.Header.Set("Accept", "application/json")
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(email+":"+token)))
-	resp, err := http.DefaultClient.Do(req)
+	req.Header.Set("Authorization", jiraAuthorization(email, token))
+	resp, err := httpClient.Do(req)
 	if err != nil {
 		return resp, nil, err
 	}
@@ -52,20 +58,20 @@ func jiraAPIReq(c *gin.Context, baseURL, email, token, method, path string, quer
 }
 
 // jiraAPIReqPost sends a POST request with JSON body (e.g. for /rest/api/3/search to avoid URL length limits).
-func jiraAPIReqPost(c *gin.Context, baseURL, email, token, path string, body interface{}) (*http.Response, []byte, error) {
+func jiraAPIReqPost(ctx context.Context, baseURL, email, token, path string, body interface{}) (*http.Response, []byte, error) {
 	rawURL := baseURL + path
 	jsonBody, err := json.Marshal(body)
 	if err != nil {
 		return nil, nil, err
 	}
-	req, err := http.NewRequestWithContext(c.Request.Context(), http.MethodPost, rawURL, strings.NewReader(string(jsonBody)))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, rawURL, strings.NewReader(string(jsonBody)))
 	if err != nil {
 		return nil, nil, err
 	}
 	req.Header.Set("Accept", "application/json")
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(email+":"+token)))
-	resp, err := http.DefaultClient.Do(req)
+	req.Header.Set("Authorization", jiraAuthorization(email, token))
+	resp, err := httpClient.Do(req)
 	if err != nil {
 		return resp, nil, err
 	}
@@ -74,14 +80,77 @@ func jiraAPIReqPost(c *gin.Context, baseURL, email, token, path string, body int
 	return resp, respBody, nil
 }
 
-// getFilter returns the JQL for a saved filter.
-func getFilter(c *gin.Context, baseURL, email, token, filterID string) (jql string, err error) {
-	resp, body, err := jiraAPIReq(c, baseURL, email, token, http.MethodGet, "/rest/api/3/filter/"+filterID, nil)
+// jiraWarningsKey is the request value key holding the request's *jiraWarningSet.
+const jiraWarningsKey = "jira_warnings"
+
+// jiraWarningSet collects the warningMessages JIRA returned with 200 responses during one request
+// (e.g. "The value 'X' does not exist for the field 'project'."), so the handler can surface them in meta.
+type jiraWarningSet struct {
+	mu   sync.Mutex
+	msgs []string
+}
+
+// recordJIRAWarnings logs any warningMessages in a search response body and keeps them (deduplicated) on the request.
+func recordJIRAWarnings(ctx context.Context, jql string, body []byte) {
+	var w struct {
+		WarningMessages []string `json:"warningMessages"`
+	}
+	if err := json.Unmarshal(body, &w); err != nil || len(w.WarningMessages) == 0 {
+		return
+	}
+	logf(ctx, "JIRA", "Search returned 200 with warnings for JQL %q: %s", jql, strings.Join(w.WarningMessages, "; "))
+
+	set := requestValue(ctx, jiraWarningsKey, func() *jiraWarningSet { return &jiraWarningSet{} })
+	set.mu.Lock()
+	defer set.mu.Unlock()
+	for _, msg := range w.WarningMessages {
+		dup := false
+		for _, existing := range set.msgs {
+			if existing == msg {
+				dup = true
+				break
+			}
+		}
+		if !dup {
+			set.msgs = append(set.msgs, msg)
+		}
+	}
+}
+
+// jiraWarnings returns the warnings recorded for this request (nil when JIRA did not complain).
+func jiraWarnings(c *gin.Context) []string {
+	set, ok := lookupRequestValue[jiraWarningSet](c.Request.Context(), jiraWarningsKey)
+	if !ok {
+		return nil
+	}
+	set.mu.Lock()
+	defer set.mu.Unlock()
+	return append([]string(nil), set.msgs...)
+}
+
+// addJIRAWarnings records jira_warnings in meta when JIRA returned warningMessages for any search in this request.
+func addJIRAWarnings(c *gin.Context, meta gin.H) {
+	if w := jiraWarnings(c); len(w) > 0 {
+		meta["jira_warnings"] = w
+	}
+}
+
+// jiraFilterCache holds saved-filter JQL by JIRA site and filter ID (JIRA_FILTER_CACHE_TTL_SEC, default 10 minutes);
+// saved filters rarely change, and every time-in-build load would otherwise refetch it.
+var jiraFilterCache = newTTLCache[string, string](10*time.Minute, 0)
+
+// getFilter returns the JQL for a saved filter, from jiraFilterCache when fresh.
+func getFilter(ctx context.Context, baseURL, email, token, filterID string) (jql string, err error) {
+	cacheKey := baseURL + "|" + filterID
+	if jql, ok := jiraFilterCache.Get(cacheKey); ok {
+		return jql, nil
+	}
+	resp, body, err := jiraAPIReq(ctx, baseURL, email, token, http.MethodGet, "/rest/api/3/filter/"+filterID, nil)
 	if err != nil {
 		return "", err
 	}
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("filter %s: %d %s", filterID, resp.StatusCode, string(body))
+		return "", newUpstreamError(resp.StatusCode, "filter %s: %d %s", filterID, resp.StatusCode, string(body))
 	}
 	var f struct {
 		JQL string `json:"jql"`
@@ -89,12 +158,18 @@ func getFilter(c *gin.Context, baseURL, email, token, filterID string) (jql stri
 	if err := json.Unmarshal(body, &f); err != nil {
 		return "", err
 	}
+	jiraFilterCache.Set(cacheKey, f.JQL)
 	return f.JQL, nil
 }
 
+// forgetFilter drops filterID's cached JQL so the next getFilter refetches it (?refresh=1).
+func forgetFilter(baseURL, filterID string) {
+	jiraFilterCache.Delete(baseURL + "|" + filterID)
+}
+
 // searchJQL returns issues from /rest/api/3/search/jql with requested fields and expand.
 // startAt is the 0-based index for pagination (use 0 for first page).
-func searchJQL(c *gin.Context, baseURL, email, token, jql string, fields []string, maxResults, startAt int, expand string) ([]map[string]interface{}, error) {
+func searchJQL(ctx context.Context, baseURL, email, token, jql string, fields []string, maxResults, startAt int, expand string) ([]map[string]interface{}, error) {
 	q := url.Values{}
 	q.Set("jql", jql)
 	q.Set("maxResults", fmt.Sprintf("%d", maxResults))
@@ -107,13 +182,14 @@ func searchJQL(c *gin.Context, baseURL, email, token, jql string, fields []strin
 	if expand != "" {
 		q.Set("expand", expand)
 	}
-	resp, body, err := jiraAPIReq(c, baseURL, email, token, http.MethodGet, "/rest/api/3/search/jql", q)
+	resp, body, err := jiraAPIReq(ctx, baseURL, email, token, http.MethodGet, "/rest/api/3/search/jql", q)
 	if err != nil {
 		return nil, err
 	}
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("search: %d %s", resp.StatusCode, string(body))
+		return nil, newUpstreamError(resp.StatusCode, "search: %d %s", resp.StatusCode, string(body))
 	}
+	recordJIRAWarnings(ctx, jql, body)
 	var withIssues struct {
 		Issues []map[string]interface{} `json:"issues"`
 	}
@@ -130,7 +206,7 @@ func searchJQL(c *gin.Context, baseURL, email, token, jql string, fields []strin
 }
 
 // searchJQLWithTotal is like searchJQL but also returns the total count from the API response when present (for validation).
-func searchJQLWithTotal(c *gin.Context, baseURL, email, token, jql string, fields []string, maxResults, startAt int, expand string) ([]map[string]interface{}, *int, error) {
+func searchJQLWithTotal(ctx context.Context, baseURL, email, token, jql string, fields []string, maxResults, startAt int, expand string) ([]map[string]interface{}, *int, error) {
 	q := url.Values{}
 	q.Set("jql", jql)
 	q.Set("maxResults", fmt.Sprintf("%d", maxResults))
@@ -143,13 +219,14 @@ func searchJQLWithTotal(c *gin.Context, baseURL, email, token, jql string, field
 	if expand != "" {
 		q.Set("expand", expand)
 	}
-	resp, body, err := jiraAPIReq(c, baseURL, email, token, http.MethodGet, "/rest/api/3/search/jql", q)
+	resp, body, err := jiraAPIReq(ctx, baseURL, email, token, http.MethodGet, "/rest/api/3/search/jql", q)
 	if err != nil {
 		return nil, nil, err
 	}
 	if resp.StatusCode != http.StatusOK {
-		return nil, nil, fmt.Errorf("search: %d %s", resp.StatusCode, string(body))
+		return nil, nil, newUpstreamErrorWithBody(resp.StatusCode, body, "search: %d %s", resp.StatusCode, string(body))
 	}
+	recordJIRAWarnings(ctx, jql, body)
 	var raw map[string]interface{}
 	if err := json.Unmarshal(body, &raw); err != nil {
 		return nil, nil, err
@@ -184,21 +261,24 @@ const vosSearchBackoffSec = 3
 
 // searchJQLWithTotalRateLimited calls searchJQLWithTotal and retries on 429 (rate limit) with backoff.
 // On final failure it returns (nil, nil, err, attempts) so the handler can show JIRA response and retry count.
-func searchJQLWithTotalRateLimited(c *gin.Context, baseURL, email, token, jql string, fields []string, maxResults, startAt int, expand string) ([]map[string]interface{}, *int, error, int) {
+func searchJQLWithTotalRateLimited(ctx context.Context, baseURL, email, token, jql string, fields []string, maxResults, startAt int, expand string) ([]map[string]interface{}, *int, error, int) {
 	var lastErr error
 	attempts := 0
 	for attempt := 0; attempt < vosSearchMaxRetries; attempt++ {
 		attempts = attempt + 1
 		if attempt > 0 {
+			if !requestRetryBudget(ctx).take() {
+				break
+			}
 			backoff := time.Duration(attempt*vosSearchBackoffSec) * time.Second
-			log.Printf("[VOS] 429 rate limited; retrying in %v (attempt %d/%d)", backoff, attempt+1, vosSearchMaxRetries)
+			logf(ctx, "VOS", "429 rate limited; retrying in %v (attempt %d/%d)", backoff, attempt+1, vosSearchMaxRetries)
 			time.Sleep(backoff)
 		}
-		page, total, err := searchJQLWithTotal(c, baseURL, email, token, jql, fields, maxResults, startAt, expand)
+		page, total, err := searchJQLWithTotal(ctx, baseURL, email, token, jql, fields, maxResults, startAt, expand)
 		if err == nil {
 			return page, total, nil, 0
 		}
-		log.Printf("[VOS] JIRA request startAt=%d failed: %v", startAt, err)
+		logf(ctx, "VOS", "JIRA request startAt=%d failed: %v", startAt, err)
 		if !strings.Contains(err.Error(), "429") {
 			return nil, nil, err, attempts
 		}
@@ -209,20 +289,21 @@ func searchJQLWithTotalRateLimited(c *gin.Context, baseURL, email, token, jql st
 
 // searchJIRAPost runs POST /rest/api/3/search/jql with JQL in the body. (POST /rest/api/3/search returns 410 removed.)
 // If you get 400 Invalid request payload, use GET searchJQLWithTotal instead (VOS does).
-func searchJIRAPost(c *gin.Context, baseURL, email, token, jql string, fields []string, maxResults, startAt int) ([]map[string]interface{}, *int, error) {
+func searchJIRAPost(ctx context.Context, baseURL, email, token, jql string, fields []string, maxResults, startAt int) ([]map[string]interface{}, *int, error) {
 	body := map[string]interface{}{
 		"jql":        jql,
 		"maxResults": maxResults,
 		"startAt":    startAt,
 		"fields":     fields,
 	}
-	resp, respBody, err := jiraAPIReqPost(c, baseURL, email, token, "/rest/api/3/search/jql", body)
+	resp, respBody, err := jiraAPIReqPost(ctx, baseURL, email, token, "/rest/api/3/search/jql", body)
 	if err != nil {
 		return nil, nil, err
 	}
 	if resp.StatusCode != http.StatusOK {
-		return nil, nil, fmt.Errorf("search: %d %s", resp.StatusCode, string(respBody))
+		return nil, nil, newUpstreamError(resp.StatusCode, "search: %d %s", resp.StatusCode, string(respBody))
 	}
+	recordJIRAWarnings(ctx, jql, respBody)
 	var raw map[string]interface{}
 	if err := json.Unmarshal(respBody, &raw); err != nil {
 		return nil, nil, err
@@ -253,21 +334,24 @@ func searchJIRAPost(c *gin.Context, baseURL, email, token, jql string, fields []
 }
 
 // searchJIRAPostRateLimited is like searchJIRAPost but retries on 429. Used for VOS so JQL is in body (no URL truncation).
-func searchJIRAPostRateLimited(c *gin.Context, baseURL, email, token, jql string, fields []string, maxResults, startAt int) ([]map[string]interface{}, *int, error, int) {
+func searchJIRAPostRateLimited(ctx context.Context, baseURL, email, token, jql string, fields []string, maxResults, startAt int) ([]map[string]interface{}, *int, error, int) {
 	var lastErr error
 	attempts := 0
 	for attempt := 0; attempt < vosSearchMaxRetries; attempt++ {
 		attempts = attempt + 1
 		if attempt > 0 {
+			if !requestRetryBudget(ctx).take() {
+				break
+			}
 			backoff := time.Duration(attempt*vosSearchBackoffSec) * time.Second
-			log.Printf("[VOS] 429 rate limited; retrying in %v (attempt %d/%d)", backoff, attempt+1, vosSearchMaxRetries)
+			logf(ctx, "VOS", "429 rate limited; retrying in %v (attempt %d/%d)", backoff, attempt+1, vosSearchMaxRetries)
 			time.Sleep(backoff)
 		}
-		page, total, err := searchJIRAPost(c, baseURL, email, token, jql, fields, maxResults, startAt)
+		page, total, err := searchJIRAPost(ctx, baseURL, email, token, jql, fields, maxResults, startAt)
 		if err == nil {
 			return page, total, nil, 0
 		}
-		log.Printf("[VOS] JIRA POST request startAt=%d failed: %v", startAt, err)
+		logf(ctx, "VOS", "JIRA POST request startAt=%d failed: %v", startAt, err)
 		if !strings.Contains(err.Error(), "429") {
 			return nil, nil, err, attempts
 		}
@@ -276,18 +360,53 @@ func searchJIRAPostRateLimited(c *gin.Context, baseURL, email, token, jql string
 	return nil, nil, lastErr, attempts
 }
 
-// getIssue returns a single issue with optional expand (e.g. changelog).
-func getIssue(c *gin.Context, baseURL, email, token, key, expand string) (map[string]interface{}, error) {
+// issueCacheKey is the request value key holding the request's *issueCache.
+const issueCacheKey = "issue_cache"
+
+// issueCache memoizes getIssue within one request, so a child shared by several epics is fetched once.
+// It lives in the request's store, not globally, so every request still sees fresh issues.
+type issueCache struct {
+	mu      sync.Mutex
+	entries map[string]*issueCacheEntry // by key and expand
+}
+
+type issueCacheEntry struct {
+	once  sync.Once
+	issue map[string]interface{}
+	err   error
+}
+
+// getIssue returns a single issue with optional expand (e.g. changelog). Within a request, repeated calls for
+// the same key and expand (including concurrent ones) share one JIRA call and its result or error.
+func getIssue(ctx context.Context, baseURL, email, token, key, expand string) (map[string]interface{}, error) {
+	cache := requestValue(ctx, issueCacheKey, func() *issueCache {
+		return &issueCache{entries: make(map[string]*issueCacheEntry)}
+	})
+	cache.mu.Lock()
+	entry, ok := cache.entries[key+"|"+expand]
+	if !ok {
+		entry = &issueCacheEntry{}
+		cache.entries[key+"|"+expand] = entry
+	}
+	cache.mu.Unlock()
+	entry.once.Do(func() {
+		entry.issue, entry.err = fetchIssue(ctx, baseURL, email, token, key, expand)
+	})
+	return entry.issue, entry.err
+}
+
+// fetchIssue fetches a single issue from JIRA.
+func fetchIssue(ctx context.Context, baseURL, email, token, key, expand string) (map[string]interface{}, error) {
 	q := url.Values{}
 	if expand != "" {
 		q.Set("expand", expand)
 	}
-	resp, body, err := jiraAPIReq(c, baseURL, email, token, http.MethodGet, "/rest/api/3/issue/"+key, q)
+	resp, body, err := jiraAPIReq(ctx, baseURL, email, token, http.MethodGet, "/rest/api/3/issue/"+key, q)
 	if err != nil {
 		return nil, err
 	}
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("issue %s: %d %s", key, resp.StatusCode, string(body))
+		return nil, newUpstreamError(resp.StatusCode, "issue %s: %d %s", key, resp.StatusCode, string(body))
 	}
 	var issue map[string]interface{}
 	if err := json.Unmarshal(body, &issue); err != nil {
@@ -381,21 +500,58 @@ func statusTransitionFromChangelogAny(issue map[string]interface{}, statusNames
 	return time.Time{}, false
 }
 
-// isRogueEpic returns true if the epic name contains "ROG" (Rogue build).
-func isRogueEpic(epic map[string]interface{}) bool {
+// A "vehicle:<label>" label on an epic overrides the summary match, so misclassified epics can be fixed in JIRA.
+// Labels are compared case-insensitively with each program's label (or "other"); the first recognised one wins.
+const (
+	vehicleLabelPrefix = "vehicle:"
+
+	classifiedByLabel   = "label"
+	classifiedBySummary = "summary"
+)
+
+// epicProgram returns the epic's vehicle program (see vehiclePrograms) and whether a label or the summary decided it.
+func epicProgram(epic map[string]interface{}) (program, classifiedBy string) {
+	for _, l := range getLabels(epic) {
+		l = strings.ToLower(strings.TrimSpace(l))
+		if !strings.HasPrefix(l, vehicleLabelPrefix) {
+			continue
+		}
+		label := strings.TrimPrefix(l, vehicleLabelPrefix)
+		if label == strings.ToLower(programOther) {
+			return programOther, classifiedByLabel
+		}
+		for _, p := range vehiclePrograms {
+			if strings.EqualFold(p.Label, label) {
+				return p.Name, classifiedByLabel
+			}
+		}
+	}
 	summary := getFieldString(epic, "fields.summary")
-	return strings.Contains(strings.ToUpper(summary), "ROG")
+	for _, p := range vehiclePrograms {
+		if p.matches(summary) {
+			return p.Name, classifiedBySummary
+		}
+	}
+	return programOther, classifiedBySummary
+}
+
+// isOtherProgram returns true if the epic matched no vehicle program.
+func isOtherProgram(epic map[string]interface{}) bool {
+	program, _ := epicProgram(epic)
+	return program == programOther
 }
 
-// isMachEEpic returns true if the epic is a MachE build (name contains "MCE"), but not D-Max/DMX.
+// isRogueEpic returns true if the epic falls in the Rogue program (by default: labelled vehicle:rogue or name contains "ROG").
+func isRogueEpic(epic map[string]interface{}) bool {
+	program, _ := epicProgram(epic)
+	return program == programRogue
+}
+
+// isMachEEpic returns true if the epic falls in the MachE program (by default: labelled vehicle:mache or name
+// contains "MCE", but not D-Max/DMX).
 func isMachEEpic(epic map[string]interface{}) bool {
-	summary := getFieldString(epic, "fields.summary")
-	upper := strings.ToUpper(summary)
-	// Exclude D-Max / DMAX / DMX so they are not counted as MachE
-	if strings.Contains(upper, "D-MAX") || strings.Contains(upper, "DMAX") || strings.Contains(upper, "DMX-") {
-		return false
-	}
-	return strings.Contains(upper, "MCE")
+	program, _ := epicProgram(epic)
+	return program == programMachE
 }
 
 func getLabels(issue map[string]interface{}) []string {
@@ -431,11 +587,183 @@ func isReleaseToFleet(issue map[string]interface{}) bool {
 		strings.Contains(summary, "released to fleet")
 }
 
+// bucketLocation is the single zone all week/day bucketing happens in. JIRA and BuildKite timestamps carry
+// their own offsets, so bucketing in each timestamp's zone could split one week across two keys. It comes from
+// TZ_LOCATION (an IANA name such as America/Los_Angeles, default UTC) and sets where weeks start on Monday 00:00;
+// handlers report it as meta.bucket_timezone.
+var bucketLocation = time.UTC
+
+func loadBucketLocation() *time.Location {
+	name := envString("TZ_LOCATION", "UTC")
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		log.Printf("[Config] Ignoring TZ_LOCATION=%q: %v; bucketing in UTC", name, err)
+		return time.UTC
+	}
+	return loc
+}
+
+// bucketTime converts t to bucketLocation. Every bucket key (weekKey, monthKey, dayKey, weekdayKey) goes through
+// it, so the same instant lands in the same bucket whatever offset the upstream timestamp carried.
+func bucketTime(t time.Time) time.Time {
+	return t.In(bucketLocation)
+}
+
+// weekKey returns the ISO week (e.g. 2024-W07) of t in bucketLocation.
 func weekKey(t time.Time) string {
-	year, week := t.ISOWeek()
+	year, week := bucketTime(t).ISOWeek()
 	return fmt.Sprintf("%d-W%02d", year, week)
 }
 
+// monthKey returns the calendar month (e.g. 2024-05) of t in bucketLocation.
+func monthKey(t time.Time) string {
+	return bucketTime(t).Format("2006-01")
+}
+
+// weekStart returns the Monday 00:00 (in bucketLocation) that begins an ISO week key like "2024-W18".
+func weekStart(key string) (time.Time, bool) {
+	var year, week int
+	if _, err := fmt.Sscanf(key, "%d-W%d", &year, &week); err != nil || week < 1 || week > 53 {
+		return time.Time{}, false
+	}
+	// Jan 4 is always in ISO week 1; step back to its Monday, then forward whole weeks
+	jan4 := time.Date(year, time.January, 4, 0, 0, 0, 0, bucketLocation)
+	offset := (int(jan4.Weekday()) + 6) % 7 // days since Monday
+	return jan4.AddDate(0, 0, -offset+(week-1)*7), true
+}
+
+// weekRangeLabels returns a human-friendly Monday–Sunday range (e.g. "Apr 29 – May 5") for each week key,
+// so exports and tooltips don't have to turn ISO week numbers back into dates.
+func weekRangeLabels(weeks []string) []string {
+	labels := make([]string, len(weeks))
+	for i, w := range weeks {
+		start, ok := weekStart(w)
+		if !ok {
+			labels[i] = w
+			continue
+		}
+		labels[i] = start.Format("Jan 2") + " – " + start.AddDate(0, 0, 6).Format("Jan 2")
+	}
+	return labels
+}
+
+// ?granularity= picks how week-based KPIs bucket: ISO weeks (default) or calendar months for quarterly reviews.
+// Day buckets are internal (the BuildKite daily series) and not accepted from the query.
+const (
+	granularityWeek  = "week"
+	granularityMonth = "month"
+	granularityDay   = "day"
+)
+
+type bucketGranularity string
+
+// parseGranularity reads ?granularity= (week or month, default week).
+func parseGranularity(c *gin.Context) (bucketGranularity, error) {
+	switch g := strings.ToLower(strings.TrimSpace(c.DefaultQuery("granularity", granularityWeek))); g {
+	case granularityWeek, granularityMonth:
+		return bucketGranularity(g), nil
+	default:
+		return "", fmt.Errorf("invalid granularity %q (want week, month)", g)
+	}
+}
+
+// key returns t's bucket: weekKey, monthKey or dayKey.
+func (g bucketGranularity) key(t time.Time) string {
+	switch g {
+	case granularityMonth:
+		return monthKey(t)
+	case granularityDay:
+		return dayKey(t)
+	}
+	return weekKey(t)
+}
+
+// plural is the response key for the bucket list: "weeks", "months" or "days".
+func (g bucketGranularity) plural() string {
+	return string(g) + "s"
+}
+
+// adjective is the response section name for a granularity: "weekly", "monthly" or "daily".
+func (g bucketGranularity) adjective() string {
+	if g == granularityDay {
+		return "daily"
+	}
+	return string(g) + "ly"
+}
+
+// setBuckets adds the bucket list and its human-readable ranges to resp: weeks/week_ranges or months/month_ranges.
+func (g bucketGranularity) setBuckets(resp gin.H, keys []string) {
+	resp[g.plural()] = keys
+	switch g {
+	case granularityWeek:
+		resp["week_ranges"] = weekRangeLabels(keys)
+	case granularityMonth:
+		resp["month_ranges"] = monthRangeLabels(keys)
+	}
+}
+
+// bucketRange is one per-bucket JIRA query window, [start, end).
+type bucketRange struct {
+	start time.Time
+	end   time.Time
+	key   string
+}
+
+// ranges splits from..now into contiguous buckets in bucketLocation: weeks starting on Monday, months
+// starting on the 1st, or days. The first bucket is the one containing from.
+func (g bucketGranularity) ranges(from, now time.Time) []bucketRange {
+	from = bucketTime(from)
+	start := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, bucketLocation)
+	next := func(t time.Time) time.Time { return t.AddDate(0, 0, 7) }
+	switch g {
+	case granularityMonth:
+		start = time.Date(from.Year(), from.Month(), 1, 0, 0, 0, 0, bucketLocation)
+		next = func(t time.Time) time.Time { return t.AddDate(0, 1, 0) }
+	case granularityDay:
+		next = func(t time.Time) time.Time { return t.AddDate(0, 0, 1) }
+	default:
+		for start.Weekday() != time.Monday {
+			start = start.AddDate(0, 0, -1)
+		}
+	}
+	var out []bucketRange
+	for s := start; s.Before(now); s = next(s) {
+		out = append(out, bucketRange{start: s, end: next(s), key: g.key(s)})
+	}
+	return out
+}
+
+// keys is the contiguous bucket list for from..now (see ranges), for filling gaps in series built from data.
+func (g bucketGranularity) keys(from, now time.Time) []string {
+	ranges := g.ranges(from, now)
+	keys := make([]string, len(ranges))
+	for i, r := range ranges {
+		keys[i] = r.key
+	}
+	return keys
+}
+
+// parseFillGaps reads ?fill_gaps= (default true); false keeps only buckets that had data. The VOS, build-bugs
+// and MTBF series query every bucket in their window, so they are contiguous either way.
+func parseFillGaps(c *gin.Context) bool {
+	v := strings.ToLower(strings.TrimSpace(c.Query("fill_gaps")))
+	return v != "false" && v != "0"
+}
+
+// monthRangeLabels returns e.g. "May 2024" for each month key, the monthly counterpart of weekRangeLabels.
+func monthRangeLabels(months []string) []string {
+	labels := make([]string, len(months))
+	for i, m := range months {
+		t, err := time.ParseInLocation("2006-01", m, bucketLocation)
+		if err != nil {
+			labels[i] = m
+			continue
+		}
+		labels[i] = t.Format("Jan 2006")
+	}
+	return labels
+}
+
 // extractVehicleName returns the vehicle/epic name from summary (e.g. "ROG-131", "MCE-203").
 func extractVehicleName(summary string) string {
 	s := strings.TrimSpace(summary)
@@ -474,6 +802,66 @@ func stripOpenOnly(jql string) string {
 	return strings.TrimSpace(s)
 }
 
+// withOpenOnly restricts jql to unresolved issues, the inverse of stripOpenOnly.
+func withOpenOnly(jql string) string {
+	return "(" + jql + ") AND resolution IS EMPTY"
+}
+
+// epicChildQueries are the ways an epic's children may be linked: parent, parentEpic, or only through the portfolio.
+func epicChildQueries(key string) []string {
+	return []string{
+		"parent = " + key,
+		"parentEpic = " + key,
+		"issue in portfolioChildIssuesOf(" + key + ")",
+	}
+}
+
+// fetchEpicChildren tries each epicChildQueries query in turn until one returns children. It returns the
+// query that matched and the errors from queries that failed.
+func fetchEpicChildren(ctx context.Context, baseURL, email, token, key string) (children []map[string]interface{}, childJQL string, childErrs []string) {
+	for _, jql := range epicChildQueries(key) {
+		found, err := searchJQL(ctx, baseURL, email, token, jql,
+			[]string{"summary", "status", "created", "updated"}, kpiMaxChildren, 0, "")
+		if err != nil {
+			childErrs = append(childErrs, jql+": "+err.Error())
+			continue
+		}
+		if len(found) > 0 {
+			return found, jql, childErrs
+		}
+	}
+	return nil, "", childErrs
+}
+
+// Build-days methods reported per epic row.
+const (
+	buildDaysApprox    = "approximation" // epic created → resolutiondate
+	buildDaysChangelog = "changelog"     // first VBUILD child In Progress → last VBUILD child Done, as kpiDebugEpic
+)
+
+// vbuildBuildSpan is kpiDebugEpic's build span for an epic: first VBUILD child In Progress → last VBUILD child
+// Done, from the children's changelogs (at most kpiMaxChildren children). ok is false when there is no such span.
+func vbuildBuildSpan(ctx context.Context, baseURL, email, token, epicKey string) (start, finish time.Time, ok bool) {
+	children, _, _ := fetchEpicChildren(ctx, baseURL, email, token, epicKey)
+	for _, ch := range children {
+		childKey, _ := ch["key"].(string)
+		if childKey == "" || !isVBUILD(ch) {
+			continue
+		}
+		issue, err := getIssue(ctx, baseURL, email, token, childKey, "changelog")
+		if err != nil {
+			continue
+		}
+		if t, ok := statusTransitionFromChangelogAny(issue, statusesForStage(stageInProgress)); ok && (start.IsZero() || t.Before(start)) {
+			start = t
+		}
+		if t, ok := statusTransitionFromChangelogAny(issue, statusesForStage(stageDone)); ok && t.After(finish) {
+			finish = t
+		}
+	}
+	return start, finish, !start.IsZero() && !finish.IsZero() && finish.After(start)
+}
+
 // kpiDebugEpic processes a single epic (e.g. VBUILD-5762) and returns build time and step-by-step details for validation.
 func kpiDebugEpic(c *gin.Context) {
 	baseURL, email, token, ok := jiraConfig()
@@ -488,34 +876,31 @@ func kpiDebugEpic(c *gin.Context) {
 	}
 
 	// 1. Fetch epic with changelog
-	epic, err := getIssue(c, baseURL, email, token, key, "changelog")
+	epic, err := getIssue(c.Request.Context(), baseURL, email, token, key, "changelog")
 	if err != nil {
-		c.JSON(http.StatusBadGateway, gin.H{"error": "fetch epic: " + err.Error(), "epic_key": key})
+		body := upstreamErrorBody("fetch epic: ", err)
+		body["epic_key"] = key
+		c.JSON(upstreamFailureStatus(err), body)
 		return
 	}
 	summary := getFieldString(epic, "fields.summary")
 	epicCreated, hasEpicCreated := getFieldTime(epic, "fields.created")
-	isRogue := isRogueEpic(epic)
-	isMachE := isMachEEpic(epic)
+	program, _ := epicProgram(epic)
+	isRogue := program == programRogue
+	isMachE := program == programMachE
 
-	// 2. Get children (parent = key or parentEpic = key)
-	childJQL := "parent = " + key
-	children, err := searchJQL(c, baseURL, email, token, childJQL,
-		[]string{"summary", "status", "created", "updated"}, kpiMaxChildren, 0, "")
-	if err != nil {
-		childJQL = "parentEpic = " + key
-		children, err = searchJQL(c, baseURL, email, token, childJQL,
-			[]string{"summary", "status", "created", "updated"}, kpiMaxChildren, 0, "")
-	}
-	if err != nil {
+	// 2. Get children
+	children, childJQL, childErrs := fetchEpicChildren(c.Request.Context(), baseURL, email, token, key)
+	if len(children) == 0 && len(childErrs) == len(epicChildQueries(key)) {
 		c.JSON(http.StatusOK, gin.H{
 			"epic_key":       key,
 			"summary":        summary,
 			"is_rogue":       isRogue,
 			"is_mach_e":      isMachE,
+			"program":        program,
 			"epic_created":   formatTime(epicCreated),
 			"children_count": 0,
-			"error":          "no children: " + err.Error(),
+			"error":          "no children: " + strings.Join(childErrs, "; "),
 			"build_days":     nil,
 			"week":           nil,
 		})
@@ -534,20 +919,20 @@ func kpiDebugEpic(c *gin.Context) {
 			childDetails = append(childDetails, detail)
 			continue
 		}
-		issue, err := getIssue(c, baseURL, email, token, childKey, "changelog")
+		issue, err := getIssue(c.Request.Context(), baseURL, email, token, childKey, "changelog")
 		if err != nil {
 			detail["error"] = err.Error()
 			childDetails = append(childDetails, detail)
 			continue
 		}
 		var firstIP, firstD time.Time
-		if t, ok := statusTransitionFromChangelogAny(issue, []string{"In Progress", "In progress"}); ok {
+		if t, ok := statusTransitionFromChangelogAny(issue, statusesForStage(stageInProgress)); ok {
 			firstIP = t
 			if isVbuild && (firstInProgress.IsZero() || t.Before(firstInProgress)) {
 				firstInProgress = t
 			}
 		}
-		if t, ok := statusTransitionFromChangelogAny(issue, []string{"Done", "Closed", "Complete", "Resolved"}); ok {
+		if t, ok := statusTransitionFromChangelogAny(issue, statusesForStage(stageDone)); ok {
 			firstD = t
 			if isVbuild && t.After(lastDone) {
 				lastDone = t
@@ -555,6 +940,9 @@ func kpiDebugEpic(c *gin.Context) {
 		}
 		detail["first_in_progress"] = formatTime(firstIP)
 		detail["first_done"] = formatTime(firstD)
+		detail["status"] = getFieldString(issue, "fields.status.name")
+		detail["stage"] = normalizeStage(getFieldString(issue, "fields.status.name"))
+		detail["stage_days"] = stageDaysFromChangelog(issue, requestNow(c))
 		childDetails = append(childDetails, detail)
 	}
 
@@ -564,9 +952,9 @@ func kpiDebugEpic(c *gin.Context) {
 		d := lastDone.Sub(firstInProgress).Hours() / 24
 		buildDays = float64(int(d*10)) / 10
 		week = weekKey(lastDone)
-	} else if hasEpicCreated && !isRogue && !isMachE {
+	} else if hasEpicCreated && program == programOther {
 		// All metric: epic created → resolved
-		if epicDone, ok := statusTransitionFromChangelogAny(epic, []string{"Done", "Closed", "Complete", "Resolved"}); ok && epicDone.After(epicCreated) {
+		if epicDone, ok := statusTransitionFromChangelogAny(epic, statusesForStage(stageDone)); ok && epicDone.After(epicCreated) {
 			buildDays = epicDone.Sub(epicCreated).Hours() / 24
 			week = weekKey(epicDone)
 		}
@@ -577,8 +965,14 @@ func kpiDebugEpic(c *gin.Context) {
 		"summary":           summary,
 		"is_rogue":          isRogue,
 		"is_mach_e":         isMachE,
+		"program":           program,
 		"epic_created":      formatTime(epicCreated),
-		"children_count":   len(children),
+		"epic_status":       getFieldString(epic, "fields.status.name"),
+		"epic_stage":        normalizeStage(getFieldString(epic, "fields.status.name")),
+		"epic_stage_days":   stageDaysFromChangelog(epic, requestNow(c)),
+		"children_count":    len(children),
+		"children_query":    childJQL,
+		"children_errors":   childErrs,
 		"children":          childDetails,
 		"first_in_progress": formatTime(firstInProgress),
 		"last_done":         formatTime(lastDone),
@@ -594,761 +988,1880 @@ func formatTime(t time.Time) string {
 	return t.Format("2006-01-02T15:04:05Z07:00")
 }
 
-// kpiTimeInBuild returns time series: by week, average days for Rogue and MachE.
-func kpiTimeInBuild(c *gin.Context) {
-	baseURL, email, token, ok := jiraConfig()
-	if !ok {
-		missing := jiraConfigMissing()
-		c.JSON(http.StatusServiceUnavailable, gin.H{
-			"error":   "JIRA not configured",
-			"missing": missing,
-		})
-		return
-	}
+// completenessSignal is one input to a response's data completeness score: how many items we
+// intended to use (Expected) versus how many we actually got usable data for (Got).
+type completenessSignal struct {
+	Name     string
+	Expected int
+	Got      int
+}
 
-	var epicJQL string
-	var filterID string
-	if customJQL := strings.TrimSpace(c.Query("jql")); customJQL != "" {
-		// Use provided JQL (e.g. project in (10525) AND 'issue' in portfolioChildIssuesOf(VBUILD-8121)); ensure we get epics only
-		filterID = "jql"
-		epicJQL = stripOpenOnly(stripOrderBy(customJQL))
-		epicJQL = "(" + epicJQL + ") AND issuetype = Epic"
-		if !strings.Contains(strings.ToLower(epicJQL), "created") {
-			epicJQL = "(" + epicJQL + ") AND created >= -" + fmt.Sprintf("%dd", kpiCreatedDays)
-		}
-	} else {
-		filterID = c.DefaultQuery("filter_id", kpiFilterIDDefault)
-		jql, err := getFilter(c, baseURL, email, token, filterID)
-		if err != nil {
-			c.JSON(http.StatusBadGateway, gin.H{"error": "failed to get filter: " + err.Error()})
-			return
-		}
-		// Fetch epics from filter (include closed so we get trend over time).
-		// Strip "resolution is empty" so we get both open and closed epics; strip ORDER BY for safe wrapping.
-		epicJQL = stripOpenOnly(stripOrderBy(jql))
-		epicJQL = "(" + epicJQL + ") AND issuetype = Epic"
-		if !strings.Contains(strings.ToLower(epicJQL), "created") {
-			epicJQL = "(" + epicJQL + ") AND created >= -" + fmt.Sprintf("%dd", kpiCreatedDays)
-		}
-		// Optional: include project(s) in addition to filter, e.g. project_keys=VBUILD so VBUILD epics are included
-		if projects := c.Query("project_keys"); projects != "" {
-			var keys []string
-			for _, p := range strings.Split(projects, ",") {
-				p = strings.TrimSpace(strings.ToUpper(p))
-				if p != "" {
-					keys = append(keys, p)
-				}
+// completenessMeta returns a 0–100 score and a per-signal breakdown for meta.
+// The score is the product of each signal's Got/Expected ratio, i.e. the share of intended data
+// that survived every loss (failed queries, skipped records, truncation). Signals with nothing
+// expected are reported but don't affect the score.
+func completenessMeta(signals ...completenessSignal) (float64, gin.H) {
+	score := 1.0
+	breakdown := gin.H{}
+	for _, s := range signals {
+		pct := 100.0
+		if s.Expected > 0 {
+			ratio := float64(s.Got) / float64(s.Expected)
+			if ratio > 1 {
+				ratio = 1
 			}
-			if len(keys) > 0 {
-				extra := "issuetype = Epic AND project in (" + strings.Join(keys, ", ") + ") AND created >= -" + fmt.Sprintf("%dd", kpiCreatedDays)
-				epicJQL = "(" + epicJQL + ") OR (" + extra + ")"
-			}
-		}
-	}
-	// Paginate to fetch all matching epics (so we get closed ones across many weeks)
-	var epics []map[string]interface{}
-	for startAt := 0; ; startAt += kpiMaxEpics {
-		page, err := searchJQL(c, baseURL, email, token, epicJQL,
-			[]string{"summary", "status", "created", "updated", "labels", "resolutiondate"}, kpiMaxEpics, startAt, "")
-		if err != nil {
-			c.JSON(http.StatusBadGateway, gin.H{"error": "epic search: " + err.Error()})
-			return
-		}
-		epics = append(epics, page...)
-		if len(page) < kpiMaxEpics {
-			break
+			score *= ratio
+			pct = ratio * 100
 		}
-		if len(epics) >= 300 {
-			break
+		breakdown[s.Name] = gin.H{
+			"expected": s.Expected,
+			"got":      s.Got,
+			"percent":  math.Round(pct*10) / 10,
 		}
 	}
+	return math.Round(score*1000) / 10, breakdown
+}
 
-	// Optional: include specific epic keys (e.g. VBUILD-4243) so they appear in table/chart even if not in JQL
-	epicKeySet := make(map[string]struct{})
-	for _, ep := range epics {
-		if k, _ := ep["key"].(string); k != "" {
-			epicKeySet[k] = struct{}{}
-		}
+// addCompleteness records the completeness score and breakdown in meta.
+func addCompleteness(meta gin.H, signals ...completenessSignal) {
+	score, breakdown := completenessMeta(signals...)
+	meta["completeness"] = score
+	meta["completeness_breakdown"] = breakdown
+}
+
+// timeInBuildEpicFields are the epic fields the time-in-build endpoints request.
+var timeInBuildEpicFields = []string{"summary", "status", "created", "updated", "labels", "resolutiondate", "resolution"}
+
+// timeInBuildEpicJQL builds the epic JQL from ?jql= or the saved filter (?filter_id=), plus optional ?project_keys=,
+// limited to rng's created window.
+func timeInBuildEpicJQL(c *gin.Context, rng timeInBuildRange, baseURL, email, token string) (epicJQL, filterID string, err error) {
+	baseJQL, filterID, err := timeInBuildBaseJQL(c, baseURL, email, token)
+	if err != nil {
+		return "", filterID, err
 	}
-	for _, raw := range strings.Split(c.Query("include_epic_keys"), ",") {
-		key := strings.TrimSpace(strings.ToUpper(raw))
-		if key == "" {
-			continue
-		}
-		if _, have := epicKeySet[key]; have {
-			continue
-		}
-		issue, err := getIssue(c, baseURL, email, token, key, "")
-		if err != nil {
-			continue
-		}
-		epicKeySet[key] = struct{}{}
-		epics = append(epics, issue)
-	}
-
-	type roguePoint struct {
-		week       string
-		days       float64
-		epicKey    string
-		summary    string
-		startTime  time.Time
-		finishTime time.Time
-	}
-	type machEPoint struct {
-		week       string
-		days       float64
-		epicKey    string
-		summary    string
-		startTime  time.Time
-		finishTime time.Time
-	}
-	type allPoint struct {
-		week       string
-		days       float64
-		epicKey    string
-		summary    string
-		startTime  time.Time
-		finishTime time.Time
-	}
-	var roguePoints []roguePoint
-	var machEPoints []machEPoint
-	var allPoints []allPoint
-
-	// Approximation: use only epic-level data (created → resolutiondate). No child tickets or changelogs — much faster.
-	for _, epic := range epics {
-		key, _ := epic["key"].(string)
-		if key == "" {
-			continue
-		}
-		epicCreated, hasCreated := getFieldTime(epic, "fields.created")
-		epicResolved, hasResolved := getFieldTime(epic, "fields.resolutiondate")
-		if !hasCreated || !hasResolved || !epicResolved.After(epicCreated) {
-			continue
+	// Include closed epics so we get trend over time; restrict to epics only
+	if rng.start.IsZero() {
+		epicJQL = withCreatedWindow(c, "("+baseJQL+") AND issuetype = Epic")
+	} else {
+		epicJQL = "(" + baseJQL + ") AND issuetype = Epic AND " + rng.windowJQL(c)
+	}
+	if filterID == "jql" {
+		return epicJQL, filterID, nil
+	}
+	// Optional: include project(s) in addition to filter, e.g. project_keys=VBUILD so VBUILD epics are included
+	if projects := c.Query("project_keys"); projects != "" {
+		var keys []string
+		for _, p := range strings.Split(projects, ",") {
+			p = strings.TrimSpace(strings.ToUpper(p))
+			if p != "" {
+				keys = append(keys, p)
+			}
 		}
-		days := epicResolved.Sub(epicCreated).Hours() / 24
-		week := weekKey(epicResolved)
-		epicSummary := getFieldString(epic, "fields.summary")
-
-		if isRogueEpic(epic) {
-			roguePoints = append(roguePoints, roguePoint{week, days, key, epicSummary, epicCreated, epicResolved})
-		} else if isMachEEpic(epic) {
-			machEPoints = append(machEPoints, machEPoint{week, days, key, epicSummary, epicCreated, epicResolved})
-		} else {
-			allPoints = append(allPoints, allPoint{week, days, key, epicSummary, epicCreated, epicResolved})
+		if len(keys) > 0 {
+			extra := "issuetype = Epic AND project in (" + strings.Join(keys, ", ") + ") AND " + rng.windowJQL(c)
+			epicJQL = "(" + epicJQL + ") OR (" + extra + ")"
 		}
 	}
+	return epicJQL, filterID, nil
+}
 
-	// Build epic_rows for the table: every finished epic with start/finish/build_days, sorted by finish time
-	type epicRow struct {
-		EpicKey     string  `json:"epic_key"`
-		Summary     string  `json:"summary"`
-		VehicleName string  `json:"vehicle_name"`
-		StartTime   string  `json:"start_time"`
-		FinishTime  string  `json:"finish_time"`
-		BuildDays   float64 `json:"build_days"`
-		Week        string  `json:"week"`
-		Type        string  `json:"type"`
-	}
-	var epicRows []epicRow
-	for _, p := range roguePoints {
-		epicRows = append(epicRows, epicRow{p.epicKey, p.summary, extractVehicleName(p.summary), formatTime(p.startTime), formatTime(p.finishTime), math.Round(p.days*10) / 10, p.week, "Rogue"})
-	}
-	for _, p := range machEPoints {
-		epicRows = append(epicRows, epicRow{p.epicKey, p.summary, extractVehicleName(p.summary), formatTime(p.startTime), formatTime(p.finishTime), math.Round(p.days*10) / 10, p.week, "MachE"})
-	}
-	for _, p := range allPoints {
-		epicRows = append(epicRows, epicRow{p.epicKey, p.summary, extractVehicleName(p.summary), formatTime(p.startTime), formatTime(p.finishTime), math.Round(p.days*10) / 10, p.week, "Other"})
-	}
-	sort.Slice(epicRows, func(i, j int) bool {
-		return epicRows[i].FinishTime < epicRows[j].FinishTime
-	})
+// customJQLMaxLen caps ?jql= on the time-in-build endpoints (JIRA_CUSTOM_JQL_MAX_LEN).
+var customJQLMaxLen = 2000
 
-	// Per-week, per-type vehicle names so labels go next to the right series (Rogue/MachE/Other)
-	weekLabelsRogue := make(map[string][]string)
-	weekLabelsMachE := make(map[string][]string)
-	weekLabelsOther := make(map[string][]string)
-	for _, row := range epicRows {
-		if row.VehicleName == "" {
-			continue
-		}
-		switch row.Type {
-		case "Rogue":
-			weekLabelsRogue[row.Week] = append(weekLabelsRogue[row.Week], row.VehicleName)
-		case "MachE":
-			weekLabelsMachE[row.Week] = append(weekLabelsMachE[row.Week], row.VehicleName)
-		case "Other":
-			weekLabelsOther[row.Week] = append(weekLabelsOther[row.Week], row.VehicleName)
-		}
+// validateCustomJQL checks ?jql= before it is wrapped in parentheses and sent to JIRA: present means not blank,
+// at most customJQLMaxLen, no control characters, and balanced quotes and parentheses, so it can't close the
+// wrapper and change what the epic and created clauses apply to.
+func validateCustomJQL(c *gin.Context) error {
+	raw, ok := c.GetQuery("jql")
+	if !ok {
+		return nil
 	}
-	for _, m := range []map[string][]string{weekLabelsRogue, weekLabelsMachE, weekLabelsOther} {
-		for w := range m {
-			seen := make(map[string]struct{})
-			var list []string
-			for _, v := range m[w] {
-				if _, ok := seen[v]; !ok {
-					seen[v] = struct{}{}
-					list = append(list, v)
-				}
+	jql := strings.TrimSpace(raw)
+	if jql == "" {
+		return errors.New("jql is empty (omit it to use the saved filter)")
+	}
+	if len(jql) > customJQLMaxLen {
+		return fmt.Errorf("jql is %d characters; the limit is %d (JIRA_CUSTOM_JQL_MAX_LEN)", len(jql), customJQLMaxLen)
+	}
+	depth := 0
+	var quote rune
+	escaped := false
+	for _, r := range jql {
+		switch {
+		case unicode.IsControl(r) && r != '\t' && r != '\n' && r != '\r':
+			return fmt.Errorf("jql contains a control character (%U)", r)
+		case escaped:
+			escaped = false
+		case quote != 0 && r == '\\':
+			escaped = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '(':
+			depth++
+		case r == ')':
+			if depth--; depth < 0 {
+				return errors.New("jql has a ')' without a matching '('")
 			}
-			sort.Strings(list)
-			m[w] = list
 		}
 	}
-
-	// Aggregate by week: average days per week
-	rogueByWeek := make(map[string][]float64)
-	for _, p := range roguePoints {
-		rogueByWeek[p.week] = append(rogueByWeek[p.week], p.days)
-	}
-	machEByWeek := make(map[string][]float64)
-	for _, p := range machEPoints {
-		machEByWeek[p.week] = append(machEByWeek[p.week], p.days)
+	if quote != 0 {
+		return errors.New("jql has an unterminated string")
 	}
-	allByWeek := make(map[string][]float64)
-	for _, p := range allPoints {
-		allByWeek[p.week] = append(allByWeek[p.week], p.days)
+	if depth != 0 {
+		return errors.New("jql has an unclosed '('")
 	}
+	return nil
+}
 
-	weeksMap := make(map[string]struct{})
-	for w := range rogueByWeek {
-		weeksMap[w] = struct{}{}
+// writeRejectedJQL answers a JIRA 400 on custom ?jql= with a 400 carrying JIRA's errorMessages, so analysts
+// can fix their query; it reports false (writing nothing) for any other failure.
+func writeRejectedJQL(c *gin.Context, filterID, jql string, err error) bool {
+	var ue *upstreamError
+	if filterID != "jql" || !errors.As(err, &ue) || ue.Status != http.StatusBadRequest {
+		return false
 	}
-	for w := range machEByWeek {
-		weeksMap[w] = struct{}{}
+	c.JSON(http.StatusBadRequest, gin.H{
+		"error":       "JIRA rejected the jql parameter",
+		"jira_errors": jiraErrorMessages(err),
+		"jql_used":    jql,
+	})
+	return true
+}
+
+// timeInBuildBaseJQL returns the user's JQL (?jql= or the saved filter) with open-only clauses and ORDER BY
+// stripped, before the epic restriction. filterID is "jql" for custom JQL.
+func timeInBuildBaseJQL(c *gin.Context, baseURL, email, token string) (baseJQL, filterID string, err error) {
+	if customJQL := strings.TrimSpace(c.Query("jql")); customJQL != "" {
+		// Use provided JQL (e.g. project in (10525) AND 'issue' in portfolioChildIssuesOf(VBUILD-8121))
+		return stripOpenOnly(stripOrderBy(customJQL)), "jql", nil
 	}
-	for w := range allByWeek {
-		weeksMap[w] = struct{}{}
+	filterID = c.DefaultQuery("filter_id", timeInBuildFilter.value)
+	if c.Query("refresh") == "1" || c.Query("refresh") == "true" {
+		forgetFilter(baseURL, filterID)
 	}
-	var weeks []string
-	for w := range weeksMap {
-		weeks = append(weeks, w)
+	jql, err := getFilter(c.Request.Context(), baseURL, email, token, filterID)
+	if err != nil {
+		return "", filterID, err
 	}
-	sort.Strings(weeks)
+	// Strip "resolution is empty" so we get both open and closed epics; strip ORDER BY for safe wrapping.
+	return stripOpenOnly(stripOrderBy(jql)), filterID, nil
+}
 
-	rogueAvg := make([]float64, len(weeks))
-	machEAvg := make([]float64, len(weeks))
-	allAvg := make([]float64, len(weeks))
-	for i, w := range weeks {
-		if vals := rogueByWeek[w]; len(vals) > 0 {
-			var sum float64
-			for _, v := range vals {
-				sum += v
+// withCreatedWindow limits jql to the last kpiCreatedDays unless it already filters on created.
+func withCreatedWindow(c *gin.Context, jql string) string {
+	if strings.Contains(strings.ToLower(jql), "created") {
+		return jql
+	}
+	return "(" + jql + ") AND " + createdWindowJQL(c)
+}
+
+// createdWindowJQL is the created clause for the last kpiCreatedDays. With a ?now= override it uses absolute
+// dates ending at that time, since JQL's relative dates always count from JIRA's real clock. JQL reads those as
+// local times, so they are written in bucketLocation like the bucket keys, not in ?now='s own offset.
+func createdWindowJQL(c *gin.Context) string {
+	now, ok := nowOverride(c.Request.Context())
+	if !ok {
+		return "created >= -" + fmt.Sprintf("%dd", kpiCreatedDays)
+	}
+	now = bucketTime(now)
+	return fmt.Sprintf("created >= '%s' AND created < '%s'",
+		now.AddDate(0, 0, -kpiCreatedDays).Format("2006-01-02 15:04"), now.Format("2006-01-02 15:04"))
+}
+
+const (
+	kpiMaxEpicsDefault = 300  // epics fetched for time-in-build unless ?max_epics= says otherwise
+	kpiMaxEpicsLimit   = 5000 // upper bound for ?max_epics=
+)
+
+// timeInBuildRange is the optional ?start_date=/?end_date= window and ?max_epics= cap for the time-in-build
+// epic search. A zero start means the default last-kpiCreatedDays created window.
+type timeInBuildRange struct {
+	start, end time.Time
+	maxEpics   int
+}
+
+// parseTimeInBuildRange reads ?start_date=, ?end_date= (YYYY-MM-DD or RFC 3339; a date-only end_date covers
+// the whole day) and ?max_epics=. end_date defaults to now; start_date alone is enough, and end_date alone
+// starts kpiCreatedDays before it.
+func parseTimeInBuildRange(c *gin.Context) (timeInBuildRange, error) {
+	var rng timeInBuildRange
+	parse := func(name string, endOfDay bool) (time.Time, error) {
+		raw := strings.TrimSpace(c.Query(name))
+		if raw == "" {
+			return time.Time{}, nil
+		}
+		if t, err := time.Parse("2006-01-02", raw); err == nil {
+			if endOfDay {
+				t = t.Add(24*time.Hour - time.Minute)
 			}
-			rogueAvg[i] = sum / float64(len(vals))
+			return t, nil
 		}
-		if vals := machEByWeek[w]; len(vals) > 0 {
-			var sum float64
-			for _, v := range vals {
-				sum += v
+		if t, err := time.Parse(time.RFC3339, raw); err == nil {
+			return t, nil
+		}
+		return time.Time{}, fmt.Errorf("%s must be an ISO 8601 date (2024-01-31) or RFC 3339 time (2024-01-31T00:00:00Z), got %q", name, raw)
+	}
+	var err error
+	if rng.start, err = parse("start_date", false); err != nil {
+		return rng, err
+	}
+	if rng.end, err = parse("end_date", true); err != nil {
+		return rng, err
+	}
+	if !rng.start.IsZero() || !rng.end.IsZero() {
+		if rng.end.IsZero() {
+			rng.end = requestNow(c)
+		}
+		if rng.start.IsZero() {
+			rng.start = rng.end.AddDate(0, 0, -kpiCreatedDays)
+		}
+		if !rng.end.After(rng.start) {
+			return rng, fmt.Errorf("end_date must be after start_date")
+		}
+	}
+	rng.maxEpics, err = parseMaxEpics(c)
+	return rng, err
+}
+
+// parseMaxEpics reads ?max_epics=, defaulting to kpiMaxEpicsDefault.
+func parseMaxEpics(c *gin.Context) (int, error) {
+	raw := strings.TrimSpace(c.Query("max_epics"))
+	if raw == "" {
+		return kpiMaxEpicsDefault, nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 1 || n > kpiMaxEpicsLimit {
+		return 0, fmt.Errorf("max_epics must be an integer between 1 and %d", kpiMaxEpicsLimit)
+	}
+	return n, nil
+}
+
+// windowJQL is the JQL clause for the range: created on/after start and resolved by end, or the default
+// created window when no dates were given.
+func (r timeInBuildRange) windowJQL(c *gin.Context) string {
+	if r.start.IsZero() {
+		return createdWindowJQL(c)
+	}
+	return fmt.Sprintf("created >= '%s' AND resolutiondate <= '%s'", r.start.Format("2006-01-02 15:04"), r.end.Format("2006-01-02 15:04"))
+}
+
+// epicScopeSample is how many issues the epic scope check fetches per query when JIRA doesn't report a total.
+const epicScopeSample = 100
+
+// epicScopeCheck compares how many issues the base JQL returns with and without the forced
+// "issuetype = Epic" restriction, and which issue types the unrestricted query returns. A filter that
+// targets stories yields results before the restriction and none after, which otherwise looks like a broken KPI.
+func epicScopeCheck(c *gin.Context, baseURL, email, token, baseJQL string) (gin.H, error) {
+	count := func(jql string, fields []string) (int, bool, []map[string]interface{}, error) {
+		page, total, err := searchJQLWithTotal(c.Request.Context(), baseURL, email, token, jql, fields, epicScopeSample, 0, "")
+		if err != nil {
+			return 0, false, nil, err
+		}
+		if total != nil {
+			return *total, false, page, nil
+		}
+		return len(page), len(page) >= epicScopeSample, page, nil
+	}
+	rawJQL := withCreatedWindow(c, baseJQL)
+	epicJQL := withCreatedWindow(c, "("+baseJQL+") AND issuetype = Epic")
+	rawCount, rawCapped, sample, err := count(rawJQL, []string{"issuetype"})
+	if err != nil {
+		return nil, err
+	}
+	epicCount, epicCapped, _, err := count(epicJQL, []string{"key"})
+	if err != nil {
+		return nil, err
+	}
+	issueTypes := make(map[string]int)
+	for _, issue := range sample {
+		issueTypes[getFieldString(issue, "fields.issuetype.name")]++
+	}
+	check := gin.H{
+		"raw_jql":               rawJQL,
+		"epic_jql":              epicJQL,
+		"raw_count":             rawCount,
+		"epic_count":            epicCount,
+		"raw_count_capped":      rawCapped,
+		"epic_count_capped":     epicCapped,
+		"issue_types_sampled":   issueTypes,
+		"epic_scoped":           rawCount > 0 && epicCount == rawCount,
+		"restriction_drops_all": rawCount > 0 && epicCount == 0,
+	}
+	if rawCount > 0 && epicCount == 0 {
+		check["warning"] = fmt.Sprintf("The filter returns %d issue(s) but none are epics; Time in Build only uses epics, so the KPI will be empty. Point the filter at the build epics (e.g. portfolioChildIssuesOf or issuetype = Epic).", rawCount)
+	}
+	return check, nil
+}
+
+// GET /api/kpi/time-in-build/validate?filter_id=|jql= – checks the filter is epic-scoped before trusting the KPI.
+func kpiTimeInBuildValidate(c *gin.Context) {
+	baseURL, email, token, ok := jiraConfig()
+	if !ok {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error":   "JIRA not configured",
+			"missing": jiraConfigMissing(),
+		})
+		return
+	}
+	if err := validateCustomJQL(c); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	baseJQL, filterID, err := timeInBuildBaseJQL(c, baseURL, email, token)
+	if err != nil {
+		c.JSON(upstreamFailureStatus(err), upstreamErrorBody("failed to get filter: ", err))
+		return
+	}
+	check, err := epicScopeCheck(c, baseURL, email, token, baseJQL)
+	if err != nil {
+		if writeRejectedJQL(c, filterID, baseJQL, err) {
+			return
+		}
+		c.JSON(upstreamFailureStatus(err), upstreamErrorBody("epic scope check: ", err))
+		return
+	}
+	check["filter_id"] = filterID
+	check["default_filter"] = timeInBuildFilter.meta()
+	check["base_jql"] = baseJQL
+	addJIRAWarnings(c, check)
+	c.JSON(http.StatusOK, check)
+}
+
+// timeInBuildEpicSet is the epic list the time-in-build endpoints work from.
+type timeInBuildEpicSet struct {
+	Epics    []map[string]interface{}
+	Total    *int // JIRA's reported total from the first page, when the API provides it
+	Searched int  // epics returned by the search, before include_epic_keys
+	CapHit   bool // paging stopped at ?max_epics= with a full last page
+}
+
+// truncated reports whether the search stopped at the epic cap with more epics matching: JIRA's total is
+// above what was fetched, or (without a total) the last page was full.
+func (s timeInBuildEpicSet) truncated() bool {
+	return s.CapHit && (s.Total == nil || *s.Total > s.Searched)
+}
+
+// addTruncationMeta sets meta.truncated, and with it total_available (when JIRA reported one) and a note,
+// so a capped epic list isn't mistaken for the whole filter.
+func (s timeInBuildEpicSet) addTruncationMeta(meta gin.H, maxEpics int) {
+	meta["truncated"] = s.truncated()
+	if !s.truncated() {
+		return
+	}
+	if s.Total != nil {
+		meta["total_available"] = *s.Total
+	}
+	meta["truncated_note"] = fmt.Sprintf("stopped at max_epics=%d; narrow the filter or dates, or raise ?max_epics=, for the full trend", maxEpics)
+}
+
+// fetchTimeInBuildEpics paginates the epic search (requesting fields) up to maxEpics and appends any
+// ?include_epic_keys= epics.
+func fetchTimeInBuildEpics(c *gin.Context, baseURL, email, token, epicJQL string, fields []string, maxEpics int) (timeInBuildEpicSet, error) {
+	var set timeInBuildEpicSet
+	// Paginate to fetch all matching epics (so we get closed ones across many weeks)
+	for startAt := 0; ; startAt += kpiMaxEpics {
+		page, total, err := searchJQLWithTotal(c.Request.Context(), baseURL, email, token, epicJQL, fields, kpiMaxEpics, startAt, "")
+		if err != nil {
+			return set, err
+		}
+		if startAt == 0 {
+			set.Total = total
+		}
+		set.Epics = append(set.Epics, page...)
+		if len(page) < kpiMaxEpics {
+			break
+		}
+		if len(set.Epics) >= maxEpics {
+			set.CapHit = true
+			break
+		}
+	}
+	set.Searched = len(set.Epics)
+	if set.truncated() {
+		total := "unknown"
+		if set.Total != nil {
+			total = strconv.Itoa(*set.Total)
+		}
+		logf(c.Request.Context(), "Time in Build", "Warning: epic search stopped at max_epics=%d with %s matching; results are truncated", maxEpics, total)
+	}
+
+	// Optional: include specific epic keys (e.g. VBUILD-4243) so they appear in table/chart even if not in JQL
+	epicKeySet := make(map[string]struct{})
+	for _, ep := range set.Epics {
+		if k, _ := ep["key"].(string); k != "" {
+			epicKeySet[k] = struct{}{}
+		}
+	}
+	for _, raw := range strings.Split(c.Query("include_epic_keys"), ",") {
+		key := strings.TrimSpace(strings.ToUpper(raw))
+		if key == "" {
+			continue
+		}
+		if _, have := epicKeySet[key]; have {
+			continue
+		}
+		issue, err := getIssue(c.Request.Context(), baseURL, email, token, key, "")
+		if err != nil {
+			continue
+		}
+		epicKeySet[key] = struct{}{}
+		set.Epics = append(set.Epics, issue)
+	}
+	return set, nil
+}
+
+// Reasons an epic produces no build-time data point.
+const (
+	epicSkipOpen               = "open" // not finished yet; expected, not a data problem
+	epicSkipMissingCreated     = "missing_created"
+	epicSkipMissingResolution  = "missing_resolutiondate"
+	epicSkipFinishBeforeStart  = "finish_before_start"
+	epicSkipExcludedResolution = "excluded_resolution"
+)
+
+// excludedResolutions returns resolution names (e.g. "Won't Do", "Duplicate") whose epics are left out of
+// build time, from comma-separated JIRA_EXCLUDED_RESOLUTIONS. Empty by default.
+func excludedResolutions() []string {
+	var out []string
+	for _, r := range strings.Split(os.Getenv("JIRA_EXCLUDED_RESOLUTIONS"), ",") {
+		if r = strings.TrimSpace(r); r != "" {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// buildTimeSkipReason returns why an epic can't produce a build-time point, or "" when it's usable.
+func buildTimeSkipReason(epic map[string]interface{}) string {
+	epicCreated, hasCreated := getFieldTime(epic, "fields.created")
+	epicResolved, hasResolved := getFieldTime(epic, "fields.resolutiondate")
+	done := hasResolved || strings.EqualFold(getFieldString(epic, "fields.status.statusCategory.key"), "done")
+	switch {
+	case !done:
+		return epicSkipOpen
+	case !hasCreated:
+		return epicSkipMissingCreated
+	case !hasResolved:
+		return epicSkipMissingResolution
+	case !epicResolved.After(epicCreated):
+		return epicSkipFinishBeforeStart
+	}
+	resolution := getFieldString(epic, "fields.resolution.name")
+	for _, r := range excludedResolutions() {
+		if strings.EqualFold(resolution, r) {
+			return epicSkipExcludedResolution
+		}
+	}
+	return ""
+}
+
+const (
+	epicRowsLimitDefault = 200
+	epicRowsLimitMax     = 2000
+)
+
+// parseEpicRowsOptions reads ?rows_limit= (default 200, max 2000) and ?rows_sort=finish|build_days|vehicle (default finish).
+func parseEpicRowsOptions(c *gin.Context) (limit int, sortBy string, err error) {
+	limit = epicRowsLimitDefault
+	if raw := strings.TrimSpace(c.Query("rows_limit")); raw != "" {
+		n, convErr := strconv.Atoi(raw)
+		if convErr != nil || n < 1 || n > epicRowsLimitMax {
+			return 0, "", fmt.Errorf("rows_limit must be an integer between 1 and %d", epicRowsLimitMax)
+		}
+		limit = n
+	}
+	sortBy = strings.ToLower(strings.TrimSpace(c.DefaultQuery("rows_sort", "finish")))
+	switch sortBy {
+	case "finish", "build_days", "vehicle":
+	default:
+		return 0, "", fmt.Errorf("rows_sort must be one of finish, build_days, vehicle")
+	}
+	return limit, sortBy, nil
+}
+
+// limitEpicRows keeps the top `limit` rows by sortBy and reports whether any were dropped.
+// finish keeps the most recent finishes (returned oldest first, as the table always showed them);
+// build_days keeps the longest builds (longest first); vehicle sorts by vehicle name, then finish.
+func limitEpicRows[T any](rows []T, sortBy string, limit int, key func(T) (finish string, buildDays float64, vehicle string)) ([]T, bool) {
+	switch sortBy {
+	case "build_days":
+		sort.SliceStable(rows, func(i, j int) bool {
+			_, di, _ := key(rows[i])
+			_, dj, _ := key(rows[j])
+			return di > dj
+		})
+	case "vehicle":
+		sort.SliceStable(rows, func(i, j int) bool {
+			fi, _, vi := key(rows[i])
+			fj, _, vj := key(rows[j])
+			if vi != vj {
+				return vi < vj
+			}
+			return fi < fj
+		})
+	}
+	if len(rows) <= limit {
+		return rows, false
+	}
+	if sortBy == "finish" {
+		return rows[len(rows)-limit:], true
+	}
+	return rows[:limit], true
+}
+
+// timeInBuildPoint is one finished epic's build time, bucketed by when it finished.
+type timeInBuildPoint struct {
+	week       string
+	bucket     string // series bucket: week, or month with ?granularity=month
+	days       float64
+	epicKey    string
+	summary    string
+	startTime  time.Time
+	finishTime time.Time
+	method     string
+	program    string // vehicle program name, programOther when unmatched
+	classified string // classifiedByLabel or classifiedBySummary
+}
+
+// epicBuildTimesResult is what epicBuildTimes found: a point per usable epic, how many epics should have produced
+// one (resolved or in a done status), how many took their days from changelogs, and each epic's group value
+// when grouping is set.
+type epicBuildTimesResult struct {
+	points         []timeInBuildPoint
+	finished       int
+	changelogEpics int
+	groups         map[string]string
+}
+
+// epicBuildTimes turns fetched epics into build-time points. By default a point spans the epic's created →
+// resolutiondate; with accurate, build days come from VBUILD children's changelogs (as /kpi/debug-epic), looked
+// up for every usable epic through the worker pool, and epics without such a span keep the approximation.
+func epicBuildTimes(ctx context.Context, baseURL, email, token string, epics []map[string]interface{}, granularity bucketGranularity, grouping *issueGrouping, accurate bool) epicBuildTimesResult {
+	type buildSpan struct {
+		start, finish time.Time
+		ok            bool
+	}
+	spans := make(map[string]buildSpan)
+	if accurate {
+		var keys []string
+		for _, epic := range epics {
+			if key, _ := epic["key"].(string); key != "" && buildTimeSkipReason(epic) == "" {
+				keys = append(keys, key)
 			}
-			machEAvg[i] = sum / float64(len(vals))
 		}
-		if vals := allByWeek[w]; len(vals) > 0 {
-			var sum float64
-			for _, v := range vals {
-				sum += v
+		results := make([]buildSpan, len(keys))
+		tasks := make([]func() error, len(keys))
+		for i, key := range keys {
+			i, key := i, key
+			tasks[i] = func() error {
+				start, finish, ok := vbuildBuildSpan(ctx, baseURL, email, token, key)
+				results[i] = buildSpan{start, finish, ok}
+				return nil
 			}
-			allAvg[i] = sum / float64(len(vals))
 		}
+		if err := runBounded(ctx, tasks, jiraWeekConcurrency); err != nil {
+			logf(ctx, "TimeInBuild", "Changelog lookups stopped early: %v", err)
+		}
+		for i, key := range keys {
+			spans[key] = results[i]
+		}
+	}
+
+	out := epicBuildTimesResult{groups: make(map[string]string)}
+	for _, epic := range epics {
+		key, _ := epic["key"].(string)
+		if key == "" {
+			continue
+		}
+		reason := buildTimeSkipReason(epic)
+		if reason != epicSkipOpen {
+			out.finished++
+		}
+		if reason != "" {
+			continue
+		}
+		epicCreated, _ := getFieldTime(epic, "fields.created")
+		epicResolved, _ := getFieldTime(epic, "fields.resolutiondate")
+		method := buildDaysApprox
+		if span := spans[key]; span.ok {
+			epicCreated, epicResolved, method = span.start, span.finish, buildDaysChangelog
+			out.changelogEpics++
+		}
+		days := epicResolved.Sub(epicCreated).Hours() / 24
+		program, classifiedBy := epicProgram(epic)
+		if grouping != nil {
+			out.groups[key] = grouping.key(epic)
+		}
+		out.points = append(out.points, timeInBuildPoint{weekKey(epicResolved), granularity.key(epicResolved), days, key,
+			getFieldString(epic, "fields.summary"), epicCreated, epicResolved, method, program, classifiedBy})
+	}
+	return out
+}
+
+// buildTimeSeries averages build days per bucket and program, with the median and p90 alongside the mean since
+// one slow epic can drag a bucket's average. Buckets are those with at least one point; a program without
+// points in a bucket is 0 there.
+func buildTimeSeries(points []timeInBuildPoint, programs []string) (buckets []string, avgs, p50s, p90s map[string][]float64) {
+	byBucket := make(map[string]map[string][]float64, len(programs))
+	for _, name := range programs {
+		byBucket[name] = make(map[string][]float64)
+	}
+	bucketSet := make(map[string]struct{})
+	for _, p := range points {
+		byBucket[p.program][p.bucket] = append(byBucket[p.program][p.bucket], p.days)
+		bucketSet[p.bucket] = struct{}{}
+	}
+	buckets = sortedKeys(bucketSet)
+
+	avgs = make(map[string][]float64, len(programs))
+	p50s = make(map[string][]float64, len(programs))
+	p90s = make(map[string][]float64, len(programs))
+	for _, name := range programs {
+		avg := make([]float64, len(buckets))
+		p50 := make([]float64, len(buckets))
+		p90 := make([]float64, len(buckets))
+		for i, b := range buckets {
+			if vals := byBucket[name][b]; len(vals) > 0 {
+				avg[i] = meanValues(vals)
+				p50[i] = percentileValue(vals, 0.5)
+				p90[i] = percentileValue(vals, 0.9)
+			}
+		}
+		avgs[name], p50s[name], p90s[name] = avg, p50, p90
+	}
+	return buckets, avgs, p50s, p90s
+}
+
+// kpiTimeInBuild returns time series: by week, average days for Rogue and MachE.
+func kpiTimeInBuild(c *gin.Context) {
+	baseURL, email, token, ok := jiraConfig()
+	if !ok {
+		missing := jiraConfigMissing()
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error":   "JIRA not configured",
+			"missing": missing,
+		})
+		return
+	}
+
+	rowsLimit, rowsSort, err := parseEpicRowsOptions(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	grouping, err := parseGroupBy(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	granularity, err := parseGranularity(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	rng, err := parseTimeInBuildRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validateCustomJQL(c); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	epicJQL, filterID, err := timeInBuildEpicJQL(c, rng, baseURL, email, token)
+	if err != nil {
+		c.JSON(upstreamFailureStatus(err), upstreamErrorBody("failed to get filter: ", err))
+		return
+	}
+	epicSet, err := fetchTimeInBuildEpics(c, baseURL, email, token, epicJQL, grouping.searchFields(timeInBuildEpicFields), rng.maxEpics)
+	if err != nil {
+		if writeRejectedJQL(c, filterID, epicJQL, err) {
+			return
+		}
+		c.JSON(upstreamFailureStatus(err), upstreamErrorBody("epic search: ", err))
+		return
+	}
+	epics := epicSet.Epics
+	epicsTotal := epicSet.Total
+	searchedEpics := epicSet.Searched
+
+	programs := vehicleProgramNames()
+	accurate := c.Query("accurate") == "1" || c.Query("accurate") == "true"
+	bt := epicBuildTimes(c.Request.Context(), baseURL, email, token, epics, granularity, grouping, accurate)
+	points, finishedEpics, changelogEpics, epicGroup := bt.points, bt.finished, bt.changelogEpics, bt.groups
+	usableEpics := len(points)
+
+	// Optional: narrow series and rows to one vehicle (a vehicle can span several epics, e.g. a rebuild).
+	// Both sides go through VEHICLE_ALIASES, so an alias matches epics named either way.
+	vehicle := strings.TrimSpace(c.Query("vehicle"))
+	if vehicle != "" {
+		want := canonicalVehicleName(vehicle)
+		filtered := points[:0]
+		for _, p := range points {
+			if canonicalVehicleName(extractVehicleName(p.summary)) == want {
+				filtered = append(filtered, p)
+			}
+		}
+		points = filtered
+	}
+
+	// Build epic_rows for the table: every finished epic with start/finish/build_days, sorted by finish time
+	type epicRow struct {
+		EpicKey     string  `json:"epic_key"`
+		Summary     string  `json:"summary"`
+		VehicleName string  `json:"vehicle_name"`
+		StartTime   string  `json:"start_time"`
+		FinishTime  string  `json:"finish_time"`
+		BuildDays   float64 `json:"build_days"`
+		Week        string  `json:"week"`
+		Type        string  `json:"type"`          // vehicle program
+		Method      string  `json:"method"`        // buildDaysApprox or buildDaysChangelog
+		Classified  string  `json:"classified_by"` // classifiedByLabel or classifiedBySummary
+	}
+	var epicRows []epicRow
+	for _, p := range points {
+		epicRows = append(epicRows, epicRow{p.epicKey, p.summary, extractVehicleName(p.summary), formatTime(p.startTime), formatTime(p.finishTime), math.Round(p.days*10) / 10, p.week, p.program, p.method, p.classified})
+	}
+	sort.Slice(epicRows, func(i, j int) bool {
+		return epicRows[i].FinishTime < epicRows[j].FinishTime
+	})
+
+	// Per-bucket, per-program vehicle names so labels go next to the right series
+	weekLabels := make(map[string]map[string][]string, len(programs))
+	for _, name := range programs {
+		weekLabels[name] = make(map[string][]string)
+	}
+	for _, p := range points {
+		if vehicle := extractVehicleName(p.summary); vehicle != "" {
+			weekLabels[p.program][p.bucket] = append(weekLabels[p.program][p.bucket], vehicle)
+		}
+	}
+	for _, m := range weekLabels {
+		for w := range m {
+			seen := make(map[string]struct{})
+			var list []string
+			for _, v := range m[w] {
+				if _, ok := seen[v]; !ok {
+					seen[v] = struct{}{}
+					list = append(list, v)
+				}
+			}
+			sort.Strings(list)
+			m[w] = list
+		}
+	}
+
+	// Aggregate by bucket: average days per week (or month), per program
+	weeks, avgs, p50s, p90s := buildTimeSeries(points, programs)
+
+	// CSV export: every row (rows_limit is for the on-screen table), finish-time order
+	if wantsCSV(c) {
+		header := []string{"epic_key", "summary", "vehicle_name", "start_time", "finish_time", "build_days", "week", "type"}
+		records := make([][]string, 0, len(epicRows))
+		for _, r := range epicRows {
+			records = append(records, []string{r.EpicKey, r.Summary, r.VehicleName, r.StartTime, r.FinishTime,
+				strconv.FormatFloat(r.BuildDays, 'f', -1, 64), r.Week, r.Type})
+		}
+		writeCSV(c, "time-in-build.csv", header, records)
+		return
+	}
+
+	// Only the table is bounded; series and week labels above use every epic.
+	rowsTotal := len(epicRows)
+	epicRows, rowsTruncated := limitEpicRows(epicRows, rowsSort, rowsLimit, func(r epicRow) (string, float64, string) {
+		return r.FinishTime, r.BuildDays, r.VehicleName
+	})
+
+	epicKeys := make([]string, 0, len(epics))
+	for _, ep := range epics {
+		if k, _ := ep["key"].(string); k != "" {
+			epicKeys = append(epicKeys, k)
+		}
+	}
+	meta := gin.H{
+		"source":         sourceLive,
+		"filter_id":      filterID,
+		"jql_used":       epicJQL,
+		"default_filter": timeInBuildFilter.meta(),
+		"epic_keys":      epicKeys,
+		"epics_seen":     len(epics),
+		"programs":       vehiclePrograms,
+	}
+	programN := make(map[string]int, len(programs))
+	for _, name := range programs {
+		programN[name] = 0
+	}
+	for _, p := range points {
+		programN[p.program]++
+	}
+	meta["program_n"] = programN
+	for name, keys := range legacyProgramKeys {
+		if n, ok := programN[name]; ok {
+			meta[keys.n] = n
+		}
+	}
+	if accurate {
+		meta["build_days_method"] = buildDaysChangelog
+		meta["changelog_epics"] = changelogEpics
+		meta["approximated_epics"] = usableEpics - changelogEpics
+	} else {
+		meta["build_days_method"] = buildDaysApprox
+	}
+	fetchSignal := completenessSignal{Name: "epics_fetched", Expected: searchedEpics, Got: searchedEpics}
+	if epicsTotal != nil {
+		fetchSignal.Expected = *epicsTotal
+	}
+	addCompleteness(meta,
+		fetchSignal,
+		completenessSignal{Name: "finished_epics_usable", Expected: finishedEpics, Got: usableEpics},
+	)
+	// Nothing came back: check whether the forced epic restriction is why
+	if searchedEpics == 0 {
+		if baseJQL, _, err := timeInBuildBaseJQL(c, baseURL, email, token); err == nil {
+			if check, err := epicScopeCheck(c, baseURL, email, token, baseJQL); err == nil {
+				meta["epic_scope"] = check
+			}
+		}
+	}
+	meta["max_epics"] = rng.maxEpics
+	epicSet.addTruncationMeta(meta, rng.maxEpics)
+	if !rng.start.IsZero() {
+		meta["start_date"] = formatTime(rng.start)
+		meta["end_date"] = formatTime(rng.end)
+	}
+	if vehicle != "" {
+		meta["vehicle"] = vehicle
+		meta["vehicle_matched"] = canonicalVehicleName(vehicle)
+		meta["vehicle_epics"] = len(points)
+	}
+	addJIRAWarnings(c, meta)
+	addRetryBudget(c, meta)
+	meta["bucket_timezone"] = bucketLocation.String()
+	meta["granularity"] = granularity
+	// The target applies to every program, so status is on each bucket's average over all of them
+	allDays := make(map[string][]float64, len(weeks))
+	for _, p := range points {
+		allDays[p.bucket] = append(allDays[p.bucket], p.days)
+	}
+	overall := make([]float64, len(weeks))
+	for i, w := range weeks {
+		overall[i] = meanValues(allDays[w])
+	}
+	meta["target_status"] = kpiTargetMeta("time_in_build", overall)
+	resp := gin.H{
+		"series":         avgs,
+		"series_p50":     p50s,
+		"series_p90":     p90s,
+		"week_labels":    weekLabels,
+		"epic_rows":      epicRows,
+		"rows_total":     rowsTotal,
+		"rows_truncated": rowsTruncated,
+		"meta":           meta,
+	}
+	granularity.setBuckets(resp, weeks)
+	// Rogue/MachE/Other also keep their original top-level keys (rogue, week_labels_rogue, ...)
+	for name, keys := range legacyProgramKeys {
+		if avg, ok := avgs[name]; ok {
+			resp[keys.series] = avg
+			resp[keys.series+"_p50"] = p50s[name]
+			resp[keys.series+"_p90"] = p90s[name]
+			resp[keys.labels] = weekLabels[name]
+		}
+	}
+	if grouping != nil {
+		byGroup := make(map[string]groupedSeries, len(programs))
+		for _, name := range programs {
+			byGroup[programSeriesKey(name)] = groupedSeries{}
+		}
+		for _, p := range points {
+			byGroup[programSeriesKey(p.program)].add(epicGroup[p.epicKey], p.bucket, p.days)
+		}
+		groups, groupMeta := grouping.seriesJSON(weeks, meanValues, byGroup)
+		resp["groups"] = groups
+		meta["grouping"] = groupMeta
+	}
+	// Optional: overlay production deploys per bucket (opt-in because it triggers a BuildKite fetch)
+	if c.Query("overlay") == "deploys" {
+		deploys, overlayMeta, err := deployOverlayForWeeks(c, weeks, granularity.key)
+		if err != nil {
+			meta["overlay_error"] = err.Error()
+		} else {
+			resp["deploys"] = deploys
+			meta["overlay"] = overlayMeta
+		}
+	}
+	jsonWithETag(c, resp, "retry_budget", "overlay.cache_age_sec", "overlay.source")
+}
+
+// kpiTimeInBuildDataQuality lists epics from the time-in-build filter that were left out of the calculation
+// (or only landed in Other) and why, with JIRA links so teams can fix their tickets.
+func kpiTimeInBuildDataQuality(c *gin.Context) {
+	baseURL, email, token, ok := jiraConfig()
+	if !ok {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "JIRA not configured", "missing": jiraConfigMissing()})
+		return
+	}
+	rng, err := parseTimeInBuildRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validateCustomJQL(c); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	epicJQL, filterID, err := timeInBuildEpicJQL(c, rng, baseURL, email, token)
+	if err != nil {
+		c.JSON(upstreamFailureStatus(err), upstreamErrorBody("failed to get filter: ", err))
+		return
+	}
+	epicSet, err := fetchTimeInBuildEpics(c, baseURL, email, token, epicJQL, timeInBuildEpicFields, rng.maxEpics)
+	if err != nil {
+		if writeRejectedJQL(c, filterID, epicJQL, err) {
+			return
+		}
+		c.JSON(upstreamFailureStatus(err), upstreamErrorBody("epic search: ", err))
+		return
+	}
+
+	issues := []gin.H{}
+	counts := make(map[string]int)
+	open := 0
+	for _, epic := range epicSet.Epics {
+		key, _ := epic["key"].(string)
+		if key == "" {
+			continue
+		}
+		reason := buildTimeSkipReason(epic)
+		excluded := true
+		switch {
+		case reason == epicSkipOpen:
+			open++
+			continue
+		case reason == "" && isOtherProgram(epic):
+			// Usable, but matched no vehicle program so it's only counted under Other
+			reason = "unclassified"
+			excluded = false
+		case reason == "":
+			continue
+		}
+		counts[reason]++
+		issues = append(issues, gin.H{
+			"epic_key":       key,
+			"summary":        getFieldString(epic, "fields.summary"),
+			"status":         getFieldString(epic, "fields.status.name"),
+			"resolution":     getFieldString(epic, "fields.resolution.name"),
+			"created":        getFieldString(epic, "fields.created"),
+			"resolutiondate": getFieldString(epic, "fields.resolutiondate"),
+			"reason":         reason,
+			"excluded":       excluded,
+			"url":            jiraBrowseURL(key),
+		})
+	}
+	sort.Slice(issues, func(i, j int) bool {
+		if issues[i]["reason"] != issues[j]["reason"] {
+			return issues[i]["reason"].(string) < issues[j]["reason"].(string)
+		}
+		return issues[i]["epic_key"].(string) < issues[j]["epic_key"].(string)
+	})
+
+	meta := gin.H{
+		"source":               sourceLive,
+		"filter_id":            filterID,
+		"jql_used":             epicJQL,
+		"default_filter":       timeInBuildFilter.meta(),
+		"epics_seen":           len(epicSet.Epics),
+		"open_epics":           open,
+		"excluded_resolutions": excludedResolutions(),
+		"note":                 "Open epics are not listed; unclassified epics are included in the Other series.",
+	}
+	addJIRAWarnings(c, meta)
+	addRetryBudget(c, meta)
+	meta["bucket_timezone"] = bucketLocation.String()
+	c.JSON(http.StatusOK, gin.H{
+		"issues":    issues,
+		"by_reason": counts,
+		"meta":      meta,
+	})
+}
+
+// kpiTimeInBuildWIP counts the filter's open epics by status, stage and type (Rogue/MachE/Other): a snapshot of
+// current load to set beside the throughput trend. Unlike the trend it has no created window, so long-running
+// epics still count.
+func kpiTimeInBuildWIP(c *gin.Context) {
+	baseURL, email, token, ok := jiraConfig()
+	if !ok {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "JIRA not configured", "missing": jiraConfigMissing()})
+		return
+	}
+	if c.Query("start_date") != "" || c.Query("end_date") != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "start_date and end_date don't apply to WIP, a snapshot of the filter's open epics"})
+		return
+	}
+	maxEpics, err := parseMaxEpics(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validateCustomJQL(c); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	baseJQL, filterID, err := timeInBuildBaseJQL(c, baseURL, email, token)
+	if err != nil {
+		c.JSON(upstreamFailureStatus(err), upstreamErrorBody("failed to get filter: ", err))
+		return
+	}
+	epicJQL := withOpenOnly("(" + baseJQL + ") AND issuetype = Epic")
+	epicSet, err := fetchTimeInBuildEpics(c, baseURL, email, token, epicJQL, timeInBuildEpicFields, maxEpics)
+	if err != nil {
+		if writeRejectedJQL(c, filterID, epicJQL, err) {
+			return
+		}
+		c.JSON(upstreamFailureStatus(err), upstreamErrorBody("epic search: ", err))
+		return
 	}
 
-	epicKeys := make([]string, 0, len(epics))
-	for _, ep := range epics {
-		if k, _ := ep["key"].(string); k != "" {
-			epicKeys = append(epicKeys, k)
+	byStatus := make(map[string]int)
+	byStage := make(map[string]int)
+	byType := make(map[string]map[string]int)
+	for _, name := range vehicleProgramNames() {
+		byType[name] = make(map[string]int)
+	}
+	total, finished := 0, 0
+	for _, epic := range epicSet.Epics {
+		// Done-category epics without a resolution, or finished ?include_epic_keys= epics, aren't WIP
+		if buildTimeSkipReason(epic) != epicSkipOpen {
+			finished++
+			continue
 		}
+		status := getFieldString(epic, "fields.status.name")
+		if status == "" {
+			status = "(unknown)"
+		}
+		epicType, _ := epicProgram(epic)
+		byStatus[status]++
+		byStage[normalizeStage(status)]++
+		byType[epicType][status]++
+		total++
 	}
+
+	meta := gin.H{
+		"source":            sourceLive,
+		"filter_id":         filterID,
+		"jql_used":          epicJQL,
+		"default_filter":    timeInBuildFilter.meta(),
+		"epics_seen":        len(epicSet.Epics),
+		"finished_excluded": finished,
+		"note":              "Open epics only (resolution empty and status not in the Done category); no created window.",
+	}
+	epicSet.addTruncationMeta(meta, maxEpics)
+	addJIRAWarnings(c, meta)
+	addRetryBudget(c, meta)
 	c.JSON(http.StatusOK, gin.H{
-		"weeks":              weeks,
-		"rogue":              rogueAvg,
-		"machE":              machEAvg,
-		"other":              allAvg,
-		"epic_rows":          epicRows,
-		"week_labels_rogue":  weekLabelsRogue,
-		"week_labels_mach_e": weekLabelsMachE,
-		"week_labels_other":  weekLabelsOther,
-		"meta": gin.H{
-			"filter_id":  filterID,
-			"jql_used":   epicJQL,
-			"epic_keys":  epicKeys,
-			"epics_seen": len(epics),
-			"rogue_n":    len(roguePoints),
-			"machE_n":    len(machEPoints),
-			"other_n":    len(allPoints),
-		},
+		"total":     total,
+		"by_status": byStatus,
+		"by_stage":  byStage,
+		"by_type":   byType,
+		"meta":      meta,
 	})
 }
 
 // JQL for tickets assigned to Vehicle OS engineers during build (VOS integration team). Matches JIRA filter exactly.
-const vosTicketsJQL = `project in (10525) AND 'issue' in portfolioChildIssuesOf(VBUILD-8121) and assignee in membersOf("okta-team-vos_si")`
+const vosTicketsJQL = `project in (10525) AND 'issue' in portfolioChildIssuesOf({portfolio_parent}) and assignee in membersOf("okta-team-vos_si")`
 
 // JQL for KPI #4: Build Issues Caught After Release to Calibration (bugs in VBUILD portfolio)
-const buildBugsJQL = `project in (10525) AND 'issue' in portfolioChildIssuesOf(VBUILD-8121) AND type in ("Bug", "Bug Report")`
-
-// JQL for MTBF (Mean Time Between Failure): Vehicle Stability Issue Reports
-const mtbfJQL = `project = VSTAB AND type = "Vehicle Stability Issue Report" AND component = "On Road Dev"`
-
-const vosTicketsMaxResults = 100  // JIRA caps per-page at 100
-const vosTicketsCreatedDays = 365 // we keep only issues created in last 365 days (~430)
-const vosTicketsPageDelay = 400 * time.Millisecond
-const vosTicketsInRangeCap = 2000  // stop when we have this many in-range issues (safety cap)
-const vosTicketsMaxPages = 25      // max pages to fetch (2500 raw) with date filter in JQL
-
-// kpiVOSTickets returns tickets assigned to Vehicle OS engineers during build: by week, tickets created and tickets resolved.
-// Uses week-by-week queries to avoid JIRA API pagination bugs and improve performance.
-func kpiVOSTickets(c *gin.Context) {
-	baseURL, email, token, ok := jiraConfig()
-	if !ok {
-		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "JIRA not configured", "missing": jiraConfigMissing()})
-		return
-	}
+const buildBugsJQL = `project in (10525) AND 'issue' in portfolioChildIssuesOf({portfolio_parent}) AND type in ("Bug", "Bug Report")`
 
-	baseJQL := vosTicketsJQL
-	log.Printf("[VOS] Base JQL: %s", baseJQL)
-	log.Printf("[VOS] Fetching issues week-by-week for last 2 months")
+// {portfolio_parent} in the VOS and build-bugs JQL is replaced per request with ?portfolio_parent=, else
+// JIRA_PORTFOLIO_PARENT, else defaultPortfolioParent. JQL overrides from env may use the placeholder too.
+const (
+	portfolioParentPlaceholder = "{portfolio_parent}"
+	defaultPortfolioParent     = "VBUILD-8121"
+)
 
-	// Generate week ranges for the last 2 months
-	now := time.Now()
-	twoMonthsAgo := now.AddDate(0, -2, 0)
+// issueKeyPattern is the PROJ-123 shape a portfolio parent must have before it's put into JQL.
+var issueKeyPattern = regexp.MustCompile(`^[A-Z][A-Z0-9_]*-[0-9]+$`)
 
-	// Find the start of the week 2 months ago (Monday)
-	startDate := twoMonthsAgo
-	for startDate.Weekday() != time.Monday {
-		startDate = startDate.AddDate(0, 0, -1)
-	}
+var jiraPortfolioParent = defaultPortfolioParent
 
-	// Collect all week ranges first
-	type weekRange struct {
-		start   time.Time
-		end     time.Time
-		weekKey string
-	}
-	var weekRanges []weekRange
-	for weekStart := startDate; weekStart.Before(now); weekStart = weekStart.AddDate(0, 0, 7) {
-		weekEnd := weekStart.AddDate(0, 0, 7)
-		weekRanges = append(weekRanges, weekRange{
-			start:   weekStart,
-			end:     weekEnd,
-			weekKey: weekKey(weekStart),
-		})
+func loadPortfolioParent() string {
+	v := strings.ToUpper(envString("JIRA_PORTFOLIO_PARENT", defaultPortfolioParent))
+	if !issueKeyPattern.MatchString(v) {
+		log.Printf("[Config] Ignoring invalid JIRA_PORTFOLIO_PARENT=%q (want an issue key like VBUILD-8121); using %s", v, defaultPortfolioParent)
+		return defaultPortfolioParent
 	}
+	return v
+}
 
-	log.Printf("[VOS] Querying %d weeks in parallel...", len(weekRanges))
-
-	// Run queries in parallel using goroutines
-	type result struct {
-		weekKey  string
-		created  int
-		resolved int
-		err      error
+// withPortfolioParent fills the {portfolio_parent} placeholder in jql from ?portfolio_parent= or the configured
+// parent, returning the parent used. A parameter that isn't an issue key, or that the JQL has no placeholder for, is a
+// 400, never interpolated or ignored.
+func withPortfolioParent(c *gin.Context, jql string) (string, string, error) {
+	parent := jiraPortfolioParent
+	if raw := strings.TrimSpace(c.Query("portfolio_parent")); raw != "" {
+		parent = strings.ToUpper(raw)
+		if !issueKeyPattern.MatchString(parent) {
+			return "", "", fmt.Errorf("invalid portfolio_parent %q (want an issue key like VBUILD-8121)", raw)
+		}
+		// A JQL override without the placeholder would silently ignore the parent the caller asked for
+		if !strings.Contains(jql, portfolioParentPlaceholder) {
+			return "", "", fmt.Errorf("portfolio_parent has no effect: this KPI's JQL has no %s placeholder", portfolioParentPlaceholder)
+		}
 	}
+	return strings.ReplaceAll(jql, portfolioParentPlaceholder, parent), parent, nil
+}
 
-	results := make(chan result, len(weekRanges))
-	var wg sync.WaitGroup
-
-	for _, w := range weekRanges {
-		wg.Add(1)
-		go func(week weekRange) {
-			defer wg.Done()
-
-			r := result{weekKey: week.weekKey}
+// JQL for MTBF (Mean Time Between Failure): Vehicle Stability Issue Reports
+const mtbfJQL = `project = VSTAB AND type = "Vehicle Stability Issue Report" AND component = "On Road Dev"`
 
-			// Query for issues created in this week
-			createdJQL := fmt.Sprintf("(%s) AND created >= '%s' AND created < '%s'",
-				baseJQL,
-				week.start.Format("2006-01-02"),
-				week.end.Format("2006-01-02"))
+// jiraQuerySetting is a KPI's JQL or saved filter id, read from env at startup with the constant as default.
+type jiraQuerySetting struct {
+	env        string
+	value      string
+	overridden bool // set from env rather than the built-in default
+}
 
-			createdIssues, err := searchJQL(c, baseURL, email, token, createdJQL, []string{"key"}, 100, 0, "")
-			if err != nil {
-				log.Printf("[VOS] Failed to query created for week %s: %v", week.weekKey, err)
-			} else {
-				r.created = len(createdIssues)
-			}
+func loadJIRAQuerySetting(env, def string) jiraQuerySetting {
+	v := envString(env, def)
+	return jiraQuerySetting{env: env, value: v, overridden: v != def}
+}
 
-			// Query for issues resolved in this week
-			resolvedJQL := fmt.Sprintf("(%s) AND resolutiondate >= '%s' AND resolutiondate < '%s'",
-				baseJQL,
-				week.start.Format("2006-01-02"),
-				week.end.Format("2006-01-02"))
-
-			resolvedIssues, err := searchJQL(c, baseURL, email, token, resolvedJQL, []string{"key"}, 100, 0, "")
-			if err != nil {
-				log.Printf("[VOS] Failed to query resolved for week %s: %v", week.weekKey, err)
-			} else {
-				r.resolved = len(resolvedIssues)
-			}
+// meta reports the setting in effect, so operators can confirm an override took.
+func (s jiraQuerySetting) meta() gin.H {
+	return gin.H{"env": s.env, "value": s.value, "overridden": s.overridden}
+}
 
-			results <- r
-		}(w)
-	}
+// Per-KPI JQL sources; other teams point the dashboard at their JIRA setup through these env vars.
+var (
+	timeInBuildFilter = jiraQuerySetting{env: "JIRA_TIME_IN_BUILD_FILTER_ID", value: kpiFilterIDDefault}
+	vosJQLSetting     = jiraQuerySetting{env: "JIRA_VOS_JQL", value: vosTicketsJQL}
+	buildBugsSetting  = jiraQuerySetting{env: "JIRA_BUILD_BUGS_JQL", value: buildBugsJQL}
+	mtbfJQLSetting    = jiraQuerySetting{env: "JIRA_MTBF_JQL", value: mtbfJQL}
+)
 
-	// Close results channel after all goroutines complete
-	go func() {
-		wg.Wait()
-		close(results)
-	}()
+const vosTicketsMaxResults = 100  // JIRA caps per-page at 100
+const vosTicketsCreatedDays = 365 // we keep only issues created in last 365 days (~430)
+const vosTicketsPageDelay = 400 * time.Millisecond
+const vosTicketsInRangeCap = 2000 // stop when we have this many in-range issues (safety cap)
+const vosTicketsMaxPages = 25     // max pages to fetch (2500 raw) with date filter in JQL
 
-	// Collect results
-	weekCreated := make(map[string]int)
-	weekResolved := make(map[string]int)
-	totalIssuesSeen := 0
+// jiraWeekConcurrency bounds how many weeks' queries the VOS, build-bugs and MTBF KPIs run at once
+// (JIRA_WEEK_CONCURRENCY); firing every week together reliably trips JIRA's rate limiter.
+var jiraWeekConcurrency = 5
 
-	for r := range results {
-		weekCreated[r.weekKey] = r.created
-		weekResolved[r.weekKey] = r.resolved
-		totalIssuesSeen += r.created
-	}
+// Per-week count strategies, reported in meta["count_strategy"].
+const (
+	countStrategyTotal     = "jira_total" // one maxResults=1 request per query; the count is JIRA's total
+	countStrategyPaginated = "paginated"  // every matching issue is paged in (needed for ?group_by=)
+)
 
-	log.Printf("[VOS] Fetched data for %d weeks (total issues seen: %d)", len(weekCreated), totalIssuesSeen)
+// weekCount is one per-week created/resolved query's result.
+type weekCount struct {
+	count     int
+	groups    []string  // group value of each issue, when grouping is set
+	days      []float64 // created→resolved days of each issue having both, when requested
+	truncated bool      // paging stopped at jiraWeekQueryCap
+	fallback  bool      // JIRA returned no total, so the issues were paged in and counted instead
+}
 
-	// Build sorted list of weeks
-	weeksMap := make(map[string]struct{})
-	for w := range weekCreated {
-		weeksMap[w] = struct{}{}
-	}
-	for w := range weekResolved {
-		weeksMap[w] = struct{}{}
+// countWeekJQL counts the issues matching jql. Without a grouping it asks JIRA for a single issue and uses the
+// response's total, so no issue bodies are transferred; when the total is missing, or a grouping or resolutionDays
+// needs each issue's fields, it pages every issue in with searchAllJQLWithRetry and counts those.
+func countWeekJQL(ctx context.Context, baseURL, email, token, jql string, grouping *issueGrouping, resolutionDays bool) (weekCount, error) {
+	var wc weekCount
+	if grouping == nil && !resolutionDays {
+		var total *int
+		err := retryJIRA(ctx, func() error {
+			var err error
+			_, total, err = searchJQLWithTotal(ctx, baseURL, email, token, jql, []string{"key"}, 1, 0, "")
+			return err
+		})
+		if err != nil {
+			return wc, err
+		}
+		if total != nil {
+			wc.count = *total
+			return wc, nil
+		}
+		wc.fallback = true
 	}
-	var weeks []string
-	for w := range weeksMap {
-		weeks = append(weeks, w)
+	fields := []string{"key"}
+	if resolutionDays {
+		fields = append(fields, "created", "resolutiondate")
 	}
-	sort.Strings(weeks)
-
-	// Build counts arrays
-	createdCounts := make([]int, len(weeks))
-	resolvedCounts := make([]int, len(weeks))
-	for i, w := range weeks {
-		createdCounts[i] = weekCreated[w]
-		resolvedCounts[i] = weekResolved[w]
+	issues, truncated, err := searchAllJQLWithRetry(ctx, baseURL, email, token, jql, grouping.searchFields(fields), jiraWeekQueryCap)
+	if err != nil {
+		return wc, err
 	}
-
-	meta := gin.H{
-		"jql_used":    baseJQL,
-		"issues_seen": totalIssuesSeen,
-		"date_filter": "last 2 months (applied in JQL per-week queries)",
-		"note":        fmt.Sprintf("Fetched data using week-by-week queries (much faster than fetching all %d issues)", totalIssuesSeen),
+	wc.count, wc.truncated = len(issues), truncated
+	for _, issue := range issues {
+		if grouping != nil {
+			wc.groups = append(wc.groups, grouping.key(issue))
+		}
+		if resolutionDays {
+			created, okCreated := getFieldTime(issue, "fields.created")
+			resolved, okResolved := getFieldTime(issue, "fields.resolutiondate")
+			if okCreated && okResolved && !resolved.Before(created) {
+				wc.days = append(wc.days, resolved.Sub(created).Hours()/24)
+			}
+		}
 	}
-	c.JSON(http.StatusOK, gin.H{
-		"weeks":    weeks,
-		"created":  createdCounts,
-		"resolved": resolvedCounts,
-		"meta":     meta,
-	})
+	return wc, nil
 }
 
-// kpiBuildBugs returns KPI #4: Build Issues Caught After Release to Calibration.
-// Shows bugs found in VBUILD portfolio, tracked week-by-week.
-func kpiBuildBugs(c *gin.Context) {
-	baseURL, email, token, ok := jiraConfig()
-	if !ok {
-		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "JIRA not configured", "missing": jiraConfigMissing()})
-		return
+// weekCountStrategy is the meta["count_strategy"]: a grouping or per-issue resolution days mean issues are paged in.
+func weekCountStrategy(grouping *issueGrouping, resolutionDays bool) string {
+	if grouping != nil || resolutionDays {
+		return countStrategyPaginated
 	}
+	return countStrategyTotal
+}
 
-	baseJQL := buildBugsJQL
-	log.Printf("[BuildBugs] Base JQL: %s", baseJQL)
-	log.Printf("[BuildBugs] Fetching bugs week-by-week for last 2 months")
+// ?months= sets how far back the VOS, build-bugs and MTBF KPIs look. Defaults come from env and keep the
+// original windows: 2 months for VOS and build bugs, 3 for MTBF.
+const (
+	minKPIWindowMonths = 1
+	maxKPIWindowMonths = 12
+)
 
-	// Generate week ranges for the last 2 months
-	now := time.Now()
-	twoMonthsAgo := now.AddDate(0, -2, 0)
+var (
+	vosWindowMonths       = 2
+	buildBugsWindowMonths = 2
+	mtbfWindowMonths      = 3
+)
 
-	// Find the start of the week 2 months ago (Monday)
-	startDate := twoMonthsAgo
-	for startDate.Weekday() != time.Monday {
-		startDate = startDate.AddDate(0, 0, -1)
+func loadKPIWindowMonths(name string, def int) int {
+	n := envInt(name, def)
+	if n < minKPIWindowMonths || n > maxKPIWindowMonths {
+		log.Printf("[Config] Ignoring %s=%d (want %d-%d); using %d", name, n, minKPIWindowMonths, maxKPIWindowMonths, def)
+		return def
 	}
+	return n
+}
 
-	// Collect all week ranges first
-	type weekRange struct {
-		start   time.Time
-		end     time.Time
-		weekKey string
+// parseWindowMonths reads ?months=, falling back to def.
+func parseWindowMonths(c *gin.Context, def int) (int, error) {
+	raw := strings.TrimSpace(c.Query("months"))
+	if raw == "" {
+		return def, nil
 	}
-	var weekRanges []weekRange
-	for weekStart := startDate; weekStart.Before(now); weekStart = weekStart.AddDate(0, 0, 7) {
-		weekEnd := weekStart.AddDate(0, 0, 7)
-		weekRanges = append(weekRanges, weekRange{
-			start:   weekStart,
-			end:     weekEnd,
-			weekKey: weekKey(weekStart),
-		})
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < minKPIWindowMonths || n > maxKPIWindowMonths {
+		return 0, fmt.Errorf("invalid months %q (want %d-%d)", raw, minKPIWindowMonths, maxKPIWindowMonths)
 	}
+	return n, nil
+}
+
+// weeklyIssueQuery configures issuesByWeek: the base JQL counted per bucket over the last months months, and
+// whether issues resolved in each bucket are counted too.
+type weeklyIssueQuery struct {
+	component     string // log prefix
+	baseJQL       string
+	months        int
+	trackResolved bool
+	granularity   bucketGranularity
+	grouping      *issueGrouping
+	// resolutionDays (with trackResolved) pages in each resolved issue to collect its created→resolved days
+	resolutionDays bool
+}
 
-	log.Printf("[BuildBugs] Querying %d weeks in parallel...", len(weekRanges))
+// weeklyIssueCounts is issuesByWeek's result, aligned to weeks (bucket keys, ascending).
+type weeklyIssueCounts struct {
+	start    time.Time // first bucket's start
+	now      time.Time // window end, in bucketLocation
+	weeks    []string
+	created  []int
+	resolved []int // nil unless trackResolved
 
-	// Run queries in parallel using goroutines
-	type result struct {
-		weekKey  string
-		created  int
-		resolved int
-		err      error
-	}
+	resolutionDays [][]float64 // per bucket, with q.resolutionDays
 
-	results := make(chan result, len(weekRanges))
-	var wg sync.WaitGroup
+	createdByGroup  groupedSeries
+	resolvedByGroup groupedSeries
 
-	for _, w := range weekRanges {
-		wg.Add(1)
-		go func(week weekRange) {
-			defer wg.Done()
+	seen           int // issues created across the window
+	queries        int // created/resolved queries issued
+	failedQueries  int
+	countFallbacks int      // queries whose response had no total, so issues were paged in instead
+	truncatedWeeks []string // weeks where a query hit jiraWeekQueryCap
+}
 
-			r := result{weekKey: week.weekKey}
+// issuesByWeek counts the issues created (and, with trackResolved, resolved) in each bucket of the last q.months
+// months for the VOS, build-bugs and MTBF KPIs, running jiraWeekConcurrency buckets at a time. Each bucket is its
+// own JQL window, avoiding JIRA's pagination bugs; a failed query leaves its bucket at 0 and counts in failedQueries.
+func issuesByWeek(c *gin.Context, baseURL, email, token string, q weeklyIssueQuery) weeklyIssueCounts {
+	ctx := c.Request.Context()
+	logf(ctx, q.component, "Base JQL: %s", q.baseJQL)
+	logf(ctx, q.component, "Fetching issues week-by-week for last %d months", q.months)
 
-			// Query for bugs created in this week
-			createdJQL := fmt.Sprintf("(%s) AND created >= '%s' AND created < '%s'",
-				baseJQL,
-				week.start.Format("2006-01-02"),
-				week.end.Format("2006-01-02"))
+	// Query ranges: weeks starting Monday, or months with ?granularity=month
+	now := requestNow(c).In(bucketLocation)
+	weekRanges := q.granularity.ranges(now.AddDate(0, -q.months, 0), now)
+	logf(ctx, q.component, "Querying %d weeks, %d at a time...", len(weekRanges), jiraWeekConcurrency)
 
-			createdIssues, err := searchJQL(c, baseURL, email, token, createdJQL, []string{"key"}, 100, 0, "")
-			if err != nil {
-				log.Printf("[BuildBugs] Failed to query created for week %s: %v", week.weekKey, err)
-			} else {
-				r.created = len(createdIssues)
+	type result struct {
+		created, resolved weekCount
+		failedQueries     int // created/resolved queries for this week that errored
+	}
+	// Under ?now= the current bucket ends at the pinned time rather than its boundary, so a re-run later doesn't
+	// pick up issues created or resolved since
+	_, pinned := nowOverride(ctx)
+	// countIn counts issues whose field (created or resolutiondate) falls in week
+	countIn := func(r *result, week bucketRange, field string) (weekCount, error) {
+		end := week.end.Format("2006-01-02")
+		if pinned && week.end.After(now) {
+			end = now.Format("2006-01-02 15:04")
+		}
+		jql := fmt.Sprintf("(%s) AND %s >= '%s' AND %s < '%s'",
+			q.baseJQL, field, week.start.Format("2006-01-02"), field, end)
+		wc, err := countWeekJQL(ctx, baseURL, email, token, jql, q.grouping, q.resolutionDays && field == "resolutiondate")
+		if err != nil {
+			logf(ctx, q.component, "Failed to query %s for week %s: %v", field, week.key, err)
+			r.failedQueries++
+		}
+		return wc, err
+	}
+
+	results := make([]result, len(weekRanges))
+	tasks := make([]func() error, len(weekRanges))
+	for i, week := range weekRanges {
+		i, week := i, week
+		tasks[i] = func() error {
+			r := &results[i]
+			var createdErr, resolvedErr error
+			r.created, createdErr = countIn(r, week, "created")
+			if q.trackResolved {
+				r.resolved, resolvedErr = countIn(r, week, "resolutiondate")
 			}
+			if createdErr != nil {
+				return createdErr
+			}
+			return resolvedErr
+		}
+	}
+	if err := runBounded(ctx, tasks, jiraWeekConcurrency); err != nil {
+		logf(ctx, q.component, "Week queries finished with errors: %v", err)
+	}
 
-			// Query for bugs resolved in this week
-			resolvedJQL := fmt.Sprintf("(%s) AND resolutiondate >= '%s' AND resolutiondate < '%s'",
-				baseJQL,
-				week.start.Format("2006-01-02"),
-				week.end.Format("2006-01-02"))
-
-			resolvedIssues, err := searchJQL(c, baseURL, email, token, resolvedJQL, []string{"key"}, 100, 0, "")
-			if err != nil {
-				log.Printf("[BuildBugs] Failed to query resolved for week %s: %v", week.weekKey, err)
-			} else {
-				r.resolved = len(resolvedIssues)
+	counts := weeklyIssueCounts{
+		now:             now,
+		createdByGroup:  groupedSeries{},
+		resolvedByGroup: groupedSeries{},
+		queries:         len(weekRanges),
+	}
+	if len(weekRanges) > 0 {
+		counts.start = weekRanges[0].start
+	}
+	if q.trackResolved {
+		counts.queries *= 2
+		counts.resolved = make([]int, len(weekRanges))
+	}
+	if q.resolutionDays {
+		counts.resolutionDays = make([][]float64, len(weekRanges))
+	}
+	counts.weeks = make([]string, len(weekRanges))
+	counts.created = make([]int, len(weekRanges))
+	for i, r := range results {
+		week := weekRanges[i].key
+		counts.weeks[i] = week
+		counts.created[i] = r.created.count
+		if q.trackResolved {
+			counts.resolved[i] = r.resolved.count
+		}
+		if q.resolutionDays {
+			counts.resolutionDays[i] = r.resolved.days
+		}
+		if r.created.truncated || r.resolved.truncated {
+			counts.truncatedWeeks = append(counts.truncatedWeeks, week)
+		}
+		for _, g := range r.created.groups {
+			counts.createdByGroup.add(g, week, 1)
+		}
+		for _, g := range r.resolved.groups {
+			counts.resolvedByGroup.add(g, week, 1)
+		}
+		for _, wc := range []weekCount{r.created, r.resolved} {
+			if wc.fallback {
+				counts.countFallbacks++
 			}
+		}
+		counts.seen += r.created.count
+		counts.failedQueries += r.failedQueries
+	}
+	logf(ctx, q.component, "Fetched data for %d weeks (total issues seen: %d)", len(counts.weeks), counts.seen)
+	return counts
+}
 
-			results <- r
-		}(w)
+// addQueryMeta reports how the per-week counts were obtained: completeness, count strategy and any capped weeks.
+func (w weeklyIssueCounts) addQueryMeta(meta gin.H, grouping *issueGrouping) {
+	meta["count_strategy"] = weekCountStrategy(grouping, w.resolutionDays != nil)
+	meta["count_fallbacks"] = w.countFallbacks
+	meta["truncated"] = len(w.truncatedWeeks) > 0
+	if len(w.truncatedWeeks) > 0 {
+		meta["truncated_weeks"] = w.truncatedWeeks
+		meta["truncated_note"] = fmt.Sprintf("these weeks matched more than %d issues per query; counts are capped", jiraWeekQueryCap)
 	}
+	meta["week_concurrency"] = jiraWeekConcurrency
+}
 
-	// Close results channel after all goroutines complete
-	go func() {
-		wg.Wait()
-		close(results)
-	}()
+// netFlow is created minus resolved per bucket, and its running sum from 0 at the first bucket.
+func netFlow(created, resolved []int) (net, cumulative []int) {
+	net = make([]int, len(created))
+	cumulative = make([]int, len(created))
+	sum := 0
+	for i := range created {
+		net[i] = created[i] - resolved[i]
+		sum += net[i]
+		cumulative[i] = sum
+	}
+	return net, cumulative
+}
 
-	// Collect results
-	weekCreated := make(map[string]int)
-	weekResolved := make(map[string]int)
-	totalIssuesSeen := 0
+// createdResolvedKPI describes one created/resolved-per-week KPI served by kpiCreatedResolved.
+type createdResolvedKPI struct {
+	component     string // log prefix
+	setting       jiraQuerySetting
+	defaultMonths int                   // window unless ?months= overrides it
+	seenKey       string                // meta key for the number of created issues
+	targetKey     string                // the KPI's entry in kpiTargets
+	note          func(seen int) string // meta.note
+	severity      bool                  // ?severity=true adds a priority-weighted severity_score series
+	splitByType   bool                  // ?split_by_type=true adds by_type series per issue type
+	resolution    bool                  // ?resolution_time=true adds avg/p90 created→resolved days series
+}
 
-	for r := range results {
-		weekCreated[r.weekKey] = r.created
-		weekResolved[r.weekKey] = r.resolved
-		totalIssuesSeen += r.created
+// kpiCreatedResolved serves a created/resolved-per-week KPI (VOS tickets, build bugs) over the last
+// kpi.defaultMonths months (?months= overrides).
+func kpiCreatedResolved(c *gin.Context, kpi createdResolvedKPI) {
+	baseURL, email, token, ok := jiraConfig()
+	if !ok {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "JIRA not configured", "missing": jiraConfigMissing()})
+		return
 	}
-
-	log.Printf("[BuildBugs] Fetched data for %d weeks (total bugs seen: %d)", len(weekCreated), totalIssuesSeen)
-
-	// Build sorted list of weeks
-	weeksMap := make(map[string]struct{})
-	for w := range weekCreated {
-		weeksMap[w] = struct{}{}
+	grouping, err := parseGroupBy(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
-	for w := range weekResolved {
-		weeksMap[w] = struct{}{}
+	// ?split_by_type=true (build bugs: Bug vs Bug Report) is shorthand for ?group_by=type with every type kept
+	splitByType := kpi.splitByType && (c.Query("split_by_type") == "1" || c.Query("split_by_type") == "true")
+	if splitByType {
+		if grouping != nil && grouping.by != groupByType {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "split_by_type can't be combined with group_by=" + grouping.by})
+			return
+		}
+		grouping = &issueGrouping{by: groupByType, field: "issuetype", limit: maxGroupLimit}
 	}
-	var weeks []string
-	for w := range weeksMap {
-		weeks = append(weeks, w)
+	// ?severity=true weights created issues by priority, which needs them grouped by priority
+	severity := kpi.severity && (c.Query("severity") == "1" || c.Query("severity") == "true")
+	if severity {
+		if grouping != nil && grouping.by != groupByPriority {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "severity can't be combined with group_by=" + grouping.by})
+			return
+		}
+		grouping = &issueGrouping{by: groupByPriority, field: "priority", limit: maxGroupLimit}
 	}
-	sort.Strings(weeks)
-
-	// Build counts arrays
-	createdCounts := make([]int, len(weeks))
-	resolvedCounts := make([]int, len(weeks))
-	for i, w := range weeks {
-		createdCounts[i] = weekCreated[w]
-		resolvedCounts[i] = weekResolved[w]
+	resolutionTime := kpi.resolution && (c.Query("resolution_time") == "1" || c.Query("resolution_time") == "true")
+	granularity, err := parseGranularity(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	months, err := parseWindowMonths(c, kpi.defaultMonths)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	baseJQL, portfolioParent, err := withPortfolioParent(c, kpi.setting.value)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
+	counts := issuesByWeek(c, baseURL, email, token, weeklyIssueQuery{
+		component:      kpi.component,
+		baseJQL:        baseJQL,
+		months:         months,
+		trackResolved:  true,
+		granularity:    granularity,
+		grouping:       grouping,
+		resolutionDays: resolutionTime,
+	})
+
 	meta := gin.H{
+		"source":      sourceLive,
 		"jql_used":    baseJQL,
-		"bugs_seen":   totalIssuesSeen,
-		"date_filter": "last 2 months (applied in JQL per-week queries)",
-		"note":        fmt.Sprintf("Fetched bug data using parallel week-by-week queries (%d bugs found)", totalIssuesSeen),
+		kpi.seenKey:   counts.seen,
+		"date_filter": fmt.Sprintf("last %d months (applied in JQL per-week queries)", months),
+		"note":        kpi.note(counts.seen),
+	}
+	meta["window_months"] = months
+	addCompleteness(meta, completenessSignal{Name: "week_queries", Expected: counts.queries, Got: counts.queries - counts.failedQueries})
+	counts.addQueryMeta(meta, grouping)
+	meta["jql_setting"] = kpi.setting.meta()
+	meta["portfolio_parent"] = portfolioParent
+	addJIRAWarnings(c, meta)
+	addRetryBudget(c, meta)
+	meta["bucket_timezone"] = bucketLocation.String()
+	meta["granularity"] = granularity
+	meta["target_status"] = kpiTargetMeta(kpi.targetKey, intsToFloats(counts.created))
+	meta["backlog_note"] = "net = created - resolved per week; backlog_cumulative sums net from 0 at the first week, so it shows the change in backlog over the window, not its true size"
+	net, backlog := netFlow(counts.created, counts.resolved)
+	resp := gin.H{
+		"created":            counts.created,
+		"resolved":           counts.resolved,
+		"net":                net,
+		"backlog_cumulative": backlog,
+		"meta":               meta,
+	}
+	granularity.setBuckets(resp, counts.weeks)
+	if severity {
+		scores, unknown := severityScores(c.Request.Context(), counts.weeks, counts.createdByGroup)
+		resp["severity_score"] = scores
+		meta["severity"] = gin.H{
+			"weights":            priorityWeights,
+			"unknown_weight":     unknownPriorityWeight,
+			"unknown_priorities": unknown,
+			"definition":         "sum of priority weights of the issues created each " + string(granularity),
+		}
 	}
-	c.JSON(http.StatusOK, gin.H{
-		"weeks":    weeks,
-		"created":  createdCounts,
-		"resolved": resolvedCounts,
-		"meta":     meta,
+	if resolutionTime {
+		avg := make([]*float64, len(counts.weeks)) // null for buckets with no resolved issue
+		p90 := make([]*float64, len(counts.weeks))
+		timed, resolved := 0, 0
+		for _, n := range counts.resolved {
+			resolved += n
+		}
+		for i, days := range counts.resolutionDays {
+			if len(days) == 0 {
+				continue
+			}
+			a, p := math.Round(meanValues(days)*10)/10, math.Round(percentileValue(days, 0.9)*10)/10
+			avg[i], p90[i] = &a, &p
+			timed += len(days)
+		}
+		resp["avg_resolution_days"] = avg
+		resp["p90_resolution_days"] = p90
+		meta["resolution_time"] = gin.H{
+			"definition":     "resolutiondate - created in days, for issues resolved in each " + string(granularity) + "; nearest-rank p90",
+			"issues_timed":   timed,
+			"issues_skipped": resolved - timed, // missing created or resolutiondate, or past jiraWeekQueryCap
+		}
+	}
+	if grouping != nil {
+		groups, groupMeta := grouping.seriesJSON(counts.weeks, sumValues, map[string]groupedSeries{
+			"created":  counts.createdByGroup,
+			"resolved": counts.resolvedByGroup,
+		})
+		meta["grouping"] = groupMeta
+		// by_type and by_assignee carry the same series as groups, so those modes send them instead
+		switch {
+		case splitByType:
+			resp["by_type"] = withBuckets(groups, granularity.plural(), counts.weeks)
+		case grouping.by == groupByAssignee:
+			resp["by_assignee"] = withBuckets(groups, granularity.plural(), counts.weeks)
+			meta["assignee_note"] = fmt.Sprintf("only issues matching the base JQL (team members); past group_limit=%d the smallest assignees are summed into %q, unassigned issues are %q", grouping.limit, groupOther, groupNone)
+		default:
+			resp["groups"] = groups
+		}
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// kpiVOSTickets returns tickets assigned to Vehicle OS engineers during build: by week, tickets created and tickets resolved.
+// Uses week-by-week queries to avoid JIRA API pagination bugs and improve performance.
+func kpiVOSTickets(c *gin.Context) {
+	kpiCreatedResolved(c, createdResolvedKPI{
+		component:     "VOS",
+		setting:       vosJQLSetting,
+		defaultMonths: vosWindowMonths,
+		seenKey:       "issues_seen",
+		targetKey:     "vos_tickets",
+		note: func(seen int) string {
+			return fmt.Sprintf("Fetched data using week-by-week queries (much faster than fetching all %d issues)", seen)
+		},
+	})
+}
+
+// kpiBuildBugs returns KPI #4: Build Issues Caught After Release to Calibration.
+// Shows bugs found in VBUILD portfolio, tracked week-by-week.
+func kpiBuildBugs(c *gin.Context) {
+	kpiCreatedResolved(c, createdResolvedKPI{
+		component:     "BuildBugs",
+		setting:       buildBugsSetting,
+		defaultMonths: buildBugsWindowMonths,
+		seenKey:       "bugs_seen",
+		targetKey:     "build_bugs",
+		note: func(seen int) string {
+			return fmt.Sprintf("Fetched bug data using parallel week-by-week queries (%d bugs found)", seen)
+		},
+		severity:    true,
+		splitByType: true,
+		resolution:  true,
 	})
 }
 
 // kpiMTBF returns Mean Time Between Failure metric: vehicle stability issue reports.
-// Tracks failure counts per week for the last 3 months.
-// TODO: Add drive hours denominator once Fleetio/Neuron data source is available.
 func kpiMTBF(c *gin.Context) {
 	baseURL, email, token, ok := jiraConfig()
 	if !ok {
 		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "JIRA not configured", "missing": jiraConfigMissing()})
 		return
 	}
-
-	baseJQL := mtbfJQL
-	log.Printf("[MTBF] Base JQL: %s", baseJQL)
-	log.Printf("[MTBF] Fetching failure reports week-by-week for last 3 months")
-
-	// Generate week ranges for the last 3 months
-	now := time.Now()
-	threeMonthsAgo := now.AddDate(0, -3, 0)
-
-	// Find the start of the week 3 months ago (Monday)
-	startDate := threeMonthsAgo
-	for startDate.Weekday() != time.Monday {
-		startDate = startDate.AddDate(0, 0, -1)
+	grouping, err := parseGroupBy(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
-
-	// Collect all week ranges first
-	type weekRange struct {
-		start   time.Time
-		end     time.Time
-		weekKey string
+	granularity, err := parseGranularity(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
-	var weekRanges []weekRange
-	for weekStart := startDate; weekStart.Before(now); weekStart = weekStart.AddDate(0, 0, 7) {
-		weekEnd := weekStart.AddDate(0, 0, 7)
-		weekRanges = append(weekRanges, weekRange{
-			start:   weekStart,
-			end:     weekEnd,
-			weekKey: weekKey(weekStart),
-		})
+	months, err := parseWindowMonths(c, mtbfWindowMonths)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
-	log.Printf("[MTBF] Querying %d weeks in parallel...", len(weekRanges))
+	baseJQL := mtbfJQLSetting.value
+	counts := issuesByWeek(c, baseURL, email, token, weeklyIssueQuery{
+		component:   "MTBF",
+		baseJQL:     baseJQL,
+		months:      months,
+		granularity: granularity,
+		grouping:    grouping,
+	})
+	weeks, failureCounts := counts.weeks, counts.created
 
-	// Run queries in parallel using goroutines
-	type result struct {
-		weekKey  string
-		failures int
-		err      error
+	meta := gin.H{
+		"source":         sourceLive,
+		"jql_used":       baseJQL,
+		"failures_seen":  counts.seen,
+		"date_filter":    fmt.Sprintf("last %d months (applied in JQL per-week queries)", months),
+		"note":           "Tracking failure counts only; set NEURON_API_TOKEN to add Neuron drive hours and MTBF.",
+		"drive_hours":    gin.H{"source": nil}, // an object either way; source is set once Neuron hours are in
+		"data_available": "failures only",
 	}
-
-	results := make(chan result, len(weekRanges))
-	var wg sync.WaitGroup
-
-	for _, w := range weekRanges {
-		wg.Add(1)
-		go func(week weekRange) {
-			defer wg.Done()
-
-			r := result{weekKey: week.weekKey}
-
-			// Query for failures created in this week
-			createdJQL := fmt.Sprintf("(%s) AND created >= '%s' AND created < '%s'",
-				baseJQL,
-				week.start.Format("2006-01-02"),
-				week.end.Format("2006-01-02"))
-
-			createdIssues, err := searchJQL(c, baseURL, email, token, createdJQL, []string{"key"}, 100, 0, "")
-			if err != nil {
-				log.Printf("[MTBF] Failed to query failures for week %s: %v", week.weekKey, err)
-			} else {
-				r.failures = len(createdIssues)
+	addCompleteness(meta, completenessSignal{Name: "week_queries", Expected: counts.queries, Got: counts.queries - counts.failedQueries})
+	meta["window_months"] = months
+	meta["jql_setting"] = mtbfJQLSetting.meta()
+	addJIRAWarnings(c, meta)
+	addRetryBudget(c, meta)
+	meta["bucket_timezone"] = bucketLocation.String()
+	meta["granularity"] = granularity
+	resp := gin.H{
+		"failures": failureCounts,
+		"meta":     meta,
+	}
+	granularity.setBuckets(resp, weeks)
+	// Neuron drive hours are the MTBF denominator; without them the KPI stays a failure count
+	if neuronURL, neuronToken, ok := neuronConfig(); ok {
+		q := url.Values{}
+		q.Set("project", "Default")
+		q.Set("start_date", counts.start.Format("2006-01-02"))
+		q.Set("end_date", counts.now.Format("2006-01-02"))
+		metrics, _, err := fetchNeuronVehicleMetrics(c.Request.Context(), neuronURL, neuronToken, q)
+		if err != nil {
+			logf(c.Request.Context(), "MTBF", "Neuron drive hours unavailable: %v", err)
+			meta["drive_hours_error"] = err.Error()
+		} else {
+			byWeek, skipped := metrics.driveHoursBy(granularity.key)
+			driveHours := make([]float64, len(weeks))
+			mtbfHours := make([]*float64, len(weeks)) // null for weeks without failures
+			for i, w := range weeks {
+				driveHours[i] = math.Round(byWeek[w]*10) / 10
+				if failureCounts[i] > 0 {
+					v := math.Round(byWeek[w]/float64(failureCounts[i])*10) / 10
+					mtbfHours[i] = &v
+				}
 			}
-
-			results <- r
-		}(w)
+			resp["drive_hours"] = driveHours
+			resp["mtbf_hours"] = mtbfHours
+			meta["drive_hours"] = gin.H{"source": sourceNeuron, "entries_skipped": skipped}
+			meta["data_available"] = "failures and drive hours"
+			meta["note"] = fmt.Sprintf("MTBF = Neuron drive hours / failures per %s; null for %ss with no failures.", granularity, granularity)
+		}
+	}
+	if grouping != nil {
+		groups, groupMeta := grouping.seriesJSON(weeks, sumValues, map[string]groupedSeries{"failures": counts.createdByGroup})
+		resp["groups"] = groups
+		meta["grouping"] = groupMeta
 	}
+	c.JSON(http.StatusOK, resp)
+}
 
-	// Close results channel after all goroutines complete
-	go func() {
-		wg.Wait()
-		close(results)
-	}()
+// weekIssue is one issue in /api/jira/issues-in-week.
+type weekIssue struct {
+	Key            string `json:"key"`
+	Summary        string `json:"summary"`
+	Status         string `json:"status"`
+	Created        string `json:"created"`
+	ResolutionDate string `json:"resolutiondate"`
+	URL            string `json:"url"`
+}
 
-	// Collect results
-	weekFailures := make(map[string]int)
-	totalFailuresSeen := 0
+// jiraQuerySettingsByKey maps ?jql_key= to the base JQL of the VOS, build-bugs and MTBF KPIs.
+func jiraQuerySettingsByKey() map[string]jiraQuerySetting {
+	return map[string]jiraQuerySetting{"vos": vosJQLSetting, "build_bugs": buildBugsSetting, "mtbf": mtbfJQLSetting}
+}
 
-	for r := range results {
-		weekFailures[r.weekKey] = r.failures
-		totalFailuresSeen += r.failures
+// GET /api/jira/issues-in-week?jql_key=vos|build_bugs|mtbf&week=2024-W18[&kind=created|resolved] – the issues behind
+// one point of the VOS, build-bugs or MTBF chart, using the same base JQL and week bounds as the KPI.
+// kind picks the created (default) or resolved series; MTBF only has created.
+func jiraIssuesInWeek(c *gin.Context) {
+	baseURL, email, token, ok := jiraConfig()
+	if !ok {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "JIRA not configured", "missing": jiraConfigMissing()})
+		return
 	}
-
-	log.Printf("[MTBF] Fetched data for %d weeks (total failures: %d)", len(weekFailures), totalFailuresSeen)
-
-	// Build sorted list of weeks
-	weeksMap := make(map[string]struct{})
-	for w := range weekFailures {
-		weeksMap[w] = struct{}{}
+	jqlKey := c.Query("jql_key")
+	setting, ok := jiraQuerySettingsByKey()[jqlKey]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid jql_key %q (want vos, build_bugs, mtbf)", jqlKey)})
+		return
 	}
-	var weeks []string
-	for w := range weeksMap {
-		weeks = append(weeks, w)
+	week := strings.ToUpper(strings.TrimSpace(c.Query("week")))
+	start, ok := weekStart(week)
+	if !ok || weekKey(start) != week {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid week %q (want an ISO week like 2024-W18)", c.Query("week"))})
+		return
+	}
+	kind := c.DefaultQuery("kind", "created")
+	dateField := map[string]string{"created": "created", "resolved": "resolutiondate"}[kind]
+	if dateField == "" || (jqlKey == "mtbf" && kind != "created") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid kind %q for %s", kind, jqlKey)})
+		return
+	}
+	baseJQL, portfolioParent, err := withPortfolioParent(c, setting.value)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
-	sort.Strings(weeks)
 
-	// Build failure counts array
-	failureCounts := make([]int, len(weeks))
-	for i, w := range weeks {
-		failureCounts[i] = weekFailures[w]
+	end := start.AddDate(0, 0, 7)
+	jql := fmt.Sprintf("(%s) AND %s >= '%s' AND %s < '%s' ORDER BY %s ASC",
+		baseJQL, dateField, start.Format("2006-01-02"), dateField, end.Format("2006-01-02"), dateField)
+	fields := []string{"key", "summary", "status", "created", "resolutiondate"}
+	raw, truncated, err := searchAllJQLWithRetry(c.Request.Context(), baseURL, email, token, jql, fields, jiraWeekQueryCap)
+	if err != nil {
+		c.JSON(upstreamFailureStatus(err), upstreamErrorBody("issue search: ", err))
+		return
+	}
+	issues := make([]weekIssue, 0, len(raw))
+	for _, issue := range raw {
+		key, _ := issue["key"].(string)
+		issues = append(issues, weekIssue{
+			Key:            key,
+			Summary:        getFieldString(issue, "fields.summary"),
+			Status:         getFieldString(issue, "fields.status.name"),
+			Created:        getFieldString(issue, "fields.created"),
+			ResolutionDate: getFieldString(issue, "fields.resolutiondate"),
+			URL:            jiraBrowseURL(key),
+		})
 	}
 
 	meta := gin.H{
-		"jql_used":       baseJQL,
-		"failures_seen":  totalFailuresSeen,
-		"date_filter":    "last 3 months (applied in JQL per-week queries)",
-		"note":           "Tracking failure counts. Drive hours data source pending (Fleetio or Neuron).",
-		"drive_hours":    "TODO: Add drive hours denominator",
-		"data_available": "failures only",
-	}
+		"source":           sourceLive,
+		"jql_used":         jql,
+		"portfolio_parent": portfolioParent,
+		"week_range":       weekRangeLabels([]string{week})[0],
+		"truncated":        truncated,
+		"bucket_timezone":  bucketLocation.String(),
+	}
+	addJIRAWarnings(c, meta)
+	addRetryBudget(c, meta)
 	c.JSON(http.StatusOK, gin.H{
-		"weeks":    weeks,
-		"failures": failureCounts,
-		"meta":     meta,
+		"jql_key": jqlKey,
+		"week":    week,
+		"kind":    kind,
+		"count":   len(issues),
+		"issues":  issues,
+		"meta":    meta,
 	})
 }
 
-// kpiDataCollectionEfficiency returns placeholder data for Data Collection Efficiency KPI.
-// TODO: Integrate with lakehouse via KunaalC's query service for real data.
+// kpiDataCollectionEfficiency returns the Data Collection Efficiency KPI from the lakehouse query service.
 // Formula: (hours of valid/usable data) / (total driving hours) * 100
 // Target: >95%
 func kpiDataCollectionEfficiency(c *gin.Context) {
-	log.Println("[DataCollectionEfficiency] Returning placeholder data - TODO: integrate with lakehouse")
-
-	// Generate placeholder data for the last 10 weeks
-	now := time.Now()
-	tenWeeksAgo := now.AddDate(0, 0, -70) // ~10 weeks
+	baseURL, token, ok := lakehouseConfig()
+	if !ok {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Lakehouse not configured", "missing": lakehouseConfigMissing()})
+		return
+	}
 
-	// Find the start of the week 10 weeks ago (Monday)
-	startDate := tenWeeksAgo
+	// Last 10 weeks
+	now := requestNow(c).In(bucketLocation) // week ranges start on Monday in the bucketing zone
+	startDate := now.AddDate(0, 0, -70)
 	for startDate.Weekday() != time.Monday {
 		startDate = startDate.AddDate(0, 0, -1)
 	}
 
-	var weeks []string
-	var efficiencyPercentages []float64
+	rows, err := fetchLakehouseWeeklyHours(c.Request.Context(), baseURL, token, startDate, now)
+	if err != nil {
+		c.JSON(upstreamFailureStatus(err), upstreamErrorBody("Lakehouse weekly hours: ", err))
+		return
+	}
+	validByWeek := make(map[string]float64)
+	totalByWeek := make(map[string]float64)
+	skipped := 0
+	for _, r := range rows {
+		t, err := time.ParseInLocation("2006-01-02", r.WeekStart, bucketLocation)
+		if err != nil {
+			skipped++
+			continue
+		}
+		validByWeek[weekKey(t)] += r.ValidHours
+		totalByWeek[weekKey(t)] += r.TotalHours
+	}
 
-	// Generate mock data with some variation around 75%
+	var weeks []string
 	for weekStart := startDate; weekStart.Before(now); weekStart = weekStart.AddDate(0, 0, 7) {
 		weeks = append(weeks, weekKey(weekStart))
-		// Mock data: efficiency between 72% and 78%
-		// Add some realistic variation
-		baseEfficiency := 75.0
-		variation := float64((len(weeks) % 5) - 2) // -2 to +2
-		efficiency := baseEfficiency + variation
-		efficiencyPercentages = append(efficiencyPercentages, efficiency)
+	}
+	efficiencyPercentages := make([]*float64, len(weeks)) // null for weeks with no driving hours
+	var measured []float64
+	for i, w := range weeks {
+		if totalByWeek[w] <= 0 {
+			continue
+		}
+		pct := math.Round(validByWeek[w]/totalByWeek[w]*1000) / 10
+		efficiencyPercentages[i] = &pct
+		measured = append(measured, pct)
 	}
 
 	meta := gin.H{
-		"data_source": "PLACEHOLDER - awaiting lakehouse integration",
-		"formula":     "(valid data hours) / (total driving hours) * 100",
-		"target":      ">95%",
-		"status":      "TODO: Integrate with KunaalC's query service for neuron/frontier/mosaic clusters",
-		"note":        "Currently returning mock data. Real implementation requires ADP auth and lakehouse query API.",
+		"source":        sourceLakehouse,
+		"formula":       "(valid data hours) / (total driving hours) * 100",
+		"target":        ">95%",
+		"rows_seen":     len(rows),
+		"rows_skipped":  skipped,
+		"weeks_no_data": len(weeks) - len(measured),
 	}
+	meta["target_status"] = kpiTargetMeta("data_collection_efficiency", measured)
+	meta["bucket_timezone"] = bucketLocation.String()
 
 	c.JSON(http.StatusOK, gin.H{
 		"weeks":                 weeks,
+		"week_ranges":           weekRangeLabels(weeks),
 		"efficiency_percentage": efficiencyPercentages,
+		"valid_hours":           hoursSeries(weeks, validByWeek),
+		"total_hours":           hoursSeries(weeks, totalByWeek),
 		"meta":                  meta,
 	})
 }
+
+// hoursSeries aligns per-week hours to weeks, rounded to 0.1h.
+func hoursSeries(weeks []string, byWeek map[string]float64) []float64 {
+	out := make([]float64, len(weeks))
+	for i, w := range weeks {
+		out[i] = math.Round(byWeek[w]*10) / 10
+	}
+	return out
+}
@@ -0,0 +1,471 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestGetIssueFetchesEachKeyOncePerRequest(t *testing.T) {
+	calls := stubUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"key": %q, "fields": {"summary": "s"}}`, r.URL.Path[len("/rest/api/3/issue/"):])
+	})
+	ctx := withRequestValues(context.Background())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			issue, err := getIssue(ctx, "https://example.atlassian.net", "e", "t", "VBUILD-1", "changelog")
+			if err != nil || issue["key"] != "VBUILD-1" {
+				t.Errorf("getIssue = %v, %v", issue, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if got := calls.Load(); got != 1 {
+		t.Fatalf("calls for a repeated key = %d, want 1", got)
+	}
+
+	if _, err := getIssue(ctx, "https://example.atlassian.net", "e", "t", "VBUILD-1", ""); err != nil {
+		t.Fatal(err)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("calls after a different expand = %d, want 2", got)
+	}
+
+	// The memo is per request: a new request fetches again
+	if _, err := getIssue(withRequestValues(context.Background()), "https://example.atlassian.net", "e", "t", "VBUILD-1", "changelog"); err != nil {
+		t.Fatal(err)
+	}
+	if got := calls.Load(); got != 3 {
+		t.Errorf("calls from a second request = %d, want 3", got)
+	}
+}
+
+// The fetch helpers take a context.Context, not *gin.Context, so they work outside a request: no request
+// value store, no ?now= override, nothing from gin.
+func TestUpstreamHelpersWithPlainContext(t *testing.T) {
+	stubUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/rest/api/3/filter/"):
+			fmt.Fprint(w, `{"jql": "project = VBUILD"}`)
+		case strings.HasPrefix(r.URL.Path, "/rest/api/3/issue/"):
+			fmt.Fprint(w, `{"key": "VBUILD-9"}`)
+		case strings.HasPrefix(r.URL.Path, "/rest/api/3/search/jql"):
+			fmt.Fprint(w, `{"issues": [{"key": "VBUILD-9"}], "total": 1, "warningMessages": ["w"]}`)
+		case strings.HasPrefix(r.URL.Path, "/v2/organizations/"):
+			fmt.Fprint(w, `[{"number": 1}]`)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	ctx := context.Background()
+	const site = "https://plain.atlassian.net"
+
+	if jql, err := getFilter(ctx, site, "e", "t", "123"); err != nil || jql != "project = VBUILD" {
+		t.Errorf("getFilter = %q, %v", jql, err)
+	}
+	if issue, err := getIssue(ctx, site, "e", "t", "VBUILD-9", ""); err != nil || issue["key"] != "VBUILD-9" {
+		t.Errorf("getIssue = %v, %v", issue, err)
+	}
+	if issues, err := searchJQL(ctx, site, "e", "t", "project = VBUILD", []string{"key"}, 10, 0, ""); err != nil || len(issues) != 1 {
+		t.Errorf("searchJQL = %v, %v", issues, err)
+	}
+	if issues, total, err := searchJQLWithTotal(ctx, site, "e", "t", "project = VBUILD", nil, 10, 0, ""); err != nil || len(issues) != 1 || total == nil || *total != 1 {
+		t.Errorf("searchJQLWithTotal = %v, %v, %v", issues, total, err)
+	}
+	if issues, _, err := searchJIRAPost(ctx, site, "e", "t", "project = VBUILD", []string{"key"}, 10, 0); err != nil || len(issues) != 1 {
+		t.Errorf("searchJIRAPost = %v, %v", issues, err)
+	}
+	if issues, truncated, err := searchAllJQLWithRetry(ctx, site, "e", "t", "project = VBUILD", nil, 10); err != nil || len(issues) != 1 || truncated {
+		t.Errorf("searchAllJQLWithRetry = %v, %v, %v", issues, truncated, err)
+	}
+	if page, err := fetchBuildkitePage(ctx, "token", buildkiteBuildsPageURL("org", "pipe", time.Now(), 1)); err != nil || len(page.Builds) != 1 {
+		t.Errorf("fetchBuildkitePage = %+v, %v", page, err)
+	}
+}
+
+func TestUpstreamHelpersStopOnCanceledContext(t *testing.T) {
+	stubUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"issues": [{"key": "VBUILD-9"}], "total": 1}`)
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, _, err := searchJQLWithTotal(ctx, "https://plain.atlassian.net", "e", "t", "project = VBUILD", nil, 10, 0, ""); !errors.Is(err, context.Canceled) {
+		t.Errorf("searchJQLWithTotal err = %v, want context.Canceled", err)
+	}
+}
+
+// weekOf150 is a JIRA search over a week with 150 matching issues: it honors startAt and maxResults, and
+// reports total unless omitTotal.
+func weekOf150(omitTotal bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		startAt, _ := strconv.Atoi(r.URL.Query().Get("startAt"))
+		maxResults, _ := strconv.Atoi(r.URL.Query().Get("maxResults"))
+		var issues []string
+		for i := startAt; i < min(startAt+maxResults, 150); i++ {
+			issues = append(issues, fmt.Sprintf(`{"key": "VOS-%d", "fields": {"issuetype": {"name": "Bug"}}}`, i+1))
+		}
+		total := `, "total": 150`
+		if omitTotal {
+			total = ""
+		}
+		fmt.Fprintf(w, `{"issues": [%s]%s}`, strings.Join(issues, ","), total)
+	}
+}
+
+func TestCountWeekJQLCountsPast100(t *testing.T) {
+	typeGrouping := &issueGrouping{by: groupByType, field: "issuetype", limit: maxGroupLimit}
+	tests := []struct {
+		name      string
+		omitTotal bool
+		grouping  *issueGrouping
+		calls     int32
+		fallback  bool
+	}{
+		{"total", false, nil, 1, false},
+		{"paged when total is missing", true, nil, 3, true}, // the total probe, then pages of 100 and 50
+		{"paged for a grouping", false, typeGrouping, 2, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := stubUpstream(t, weekOf150(tt.omitTotal))
+			wc, err := countWeekJQL(context.Background(), "https://example.atlassian.net", "e", "t", "project = VOS", tt.grouping, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if wc.count != 150 || wc.truncated || wc.fallback != tt.fallback {
+				t.Errorf("count = %d, truncated = %v, fallback = %v; want 150, false, %v", wc.count, wc.truncated, wc.fallback, tt.fallback)
+			}
+			if tt.grouping != nil && len(wc.groups) != 150 {
+				t.Errorf("groups = %d, want one per issue", len(wc.groups))
+			}
+			if got := calls.Load(); got != tt.calls {
+				t.Errorf("calls = %d, want %d", got, tt.calls)
+			}
+		})
+	}
+}
+
+func TestWeekCountStrategy(t *testing.T) {
+	meta := gin.H{}
+	weeklyIssueCounts{resolutionDays: [][]float64{{2.5}}}.addQueryMeta(meta, nil)
+	if meta["count_strategy"] != countStrategyPaginated {
+		t.Errorf("with resolution days: count_strategy = %v, want %s", meta["count_strategy"], countStrategyPaginated)
+	}
+	if got := weekCountStrategy(&issueGrouping{by: groupByType, field: "issuetype"}, false); got != countStrategyPaginated {
+		t.Errorf("with a grouping: %s, want %s", got, countStrategyPaginated)
+	}
+	if got := weekCountStrategy(nil, false); got != countStrategyTotal {
+		t.Errorf("plain counts: %s, want %s", got, countStrategyTotal)
+	}
+}
+
+func TestSearchAllJQLWithRetryReportsTruncation(t *testing.T) {
+	stubUpstream(t, weekOf150(false))
+	issues, truncated, err := searchAllJQLWithRetry(context.Background(), "https://example.atlassian.net", "e", "t", "project = VOS", nil, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(issues) != 100 || !truncated {
+		t.Errorf("got %d issues, truncated %v; want 100 and truncated at the limit", len(issues), truncated)
+	}
+}
+
+func TestValidateCustomJQL(t *testing.T) {
+	setForTest(t, &customJQLMaxLen, 40)
+	tests := []struct {
+		query   string
+		wantErr string
+	}{
+		{"", ""}, // no ?jql=: the saved filter is used
+		{"?jql=project+%3D+VBUILD", ""},
+		{"?jql=summary+~+%22a+(b%22", ""}, // parentheses inside a string don't count
+		{"?jql=", "jql is empty"},
+		{"?jql=+++", "jql is empty"},
+		{"?jql=" + strings.Repeat("x", 41), "limit is 40"},
+		{"?jql=project+%3D+X)+OR+(1%3D1", "without a matching '('"},
+		{"?jql=(project+%3D+X", "unclosed '('"},
+		{"?jql=summary+~+%22open", "unterminated string"},
+		{"?jql=project+%3D+X%00", "control character"},
+	}
+	for _, tt := range tests {
+		c, _ := testContext("/api/kpi/time-in-build" + tt.query)
+		err := validateCustomJQL(c)
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("%s: unexpected error %v", tt.query, err)
+		case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+			t.Errorf("%s: error %v, want %q", tt.query, err, tt.wantErr)
+		}
+	}
+}
+
+func TestTimeInBuildCustomJQLErrors(t *testing.T) {
+	setJIRAEnv(t)
+	setForTest(t, &customJQLMaxLen, 40)
+	calls := stubUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"errorMessages": ["Field 'projekt' does not exist or you do not have permission to view it."], "errors": {}}`)
+	})
+
+	tests := []struct {
+		name, query string
+		wantError   string
+		wantCalls   int32
+	}{
+		{"empty", "jql=", "jql is empty", 0},
+		{"over-long", "jql=" + strings.Repeat("x", 41), "limit is 40", 0},
+		{"rejected by JIRA", "jql=projekt+%3D+VBUILD", "JIRA rejected the jql parameter", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls.Store(0)
+			c, rec := testContext("/api/kpi/time-in-build?" + tt.query)
+			c.Request = c.Request.WithContext(withRequestValues(c.Request.Context()))
+			kpiTimeInBuild(c)
+
+			var body struct {
+				Error      string   `json:"error"`
+				JIRAErrors []string `json:"jira_errors"`
+				JQLUsed    string   `json:"jql_used"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if rec.Code != http.StatusBadRequest || !strings.Contains(body.Error, tt.wantError) {
+				t.Errorf("status %d, error %q; want 400 %q", rec.Code, body.Error, tt.wantError)
+			}
+			if got := calls.Load(); got != tt.wantCalls {
+				t.Errorf("JIRA calls = %d, want %d", got, tt.wantCalls)
+			}
+			if tt.wantCalls > 0 && (len(body.JIRAErrors) != 1 || !strings.Contains(strings.ToLower(body.JQLUsed), "projekt = vbuild")) {
+				t.Errorf("jira_errors = %v, jql_used = %q", body.JIRAErrors, body.JQLUsed)
+			}
+		})
+	}
+}
+
+// WIP is a snapshot of open epics, so a date range would be silently dropped; it's rejected instead.
+func TestTimeInBuildWIPRejectsDateRange(t *testing.T) {
+	setJIRAEnv(t)
+	calls := stubUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"issues": [{"key": "VBUILD-1", "fields": {"summary": "ROG-01 build", "status": {"name": "In Progress", "statusCategory": {"key": "indeterminate"}}}}], "total": 1}`)
+	})
+
+	for query, wantCode := range map[string]int{
+		"start_date=2024-05-01": http.StatusBadRequest,
+		"end_date=2024-05-31":   http.StatusBadRequest,
+		"max_epics=0":           http.StatusBadRequest,
+		"max_epics=10":          http.StatusOK,
+	} {
+		calls.Store(0)
+		c, rec := testContext("/api/kpi/time-in-build/wip?jql=project+%3D+VBUILD&" + query)
+		c.Request = c.Request.WithContext(withRequestValues(c.Request.Context()))
+		kpiTimeInBuildWIP(c)
+
+		if rec.Code != wantCode {
+			t.Errorf("%s: status %d, want %d: %s", query, rec.Code, wantCode, rec.Body.String())
+		}
+		if wantCode == http.StatusBadRequest && calls.Load() != 0 {
+			t.Errorf("%s: %d JIRA calls, want none before rejecting", query, calls.Load())
+		}
+	}
+}
+
+// ?vehicle= on time-in-build compares names after VEHICLE_ALIASES, so an alias and the extracted name match
+// each other whichever one the epic summary or the query uses.
+func TestVehicleAliases(t *testing.T) {
+	aliases, err := parseVehicleAliases(`{"Rogue 131": "ROG-131", " rog-131b ": "ROG-131"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setForTest(t, &vehicleAliases, aliases)
+	for _, name := range []string{"ROG-131", "rog-131", "Rogue 131", "ROG-131B"} {
+		if got := canonicalVehicleName(name); got != "rog-131" {
+			t.Errorf("canonicalVehicleName(%q) = %q, want rog-131", name, got)
+		}
+	}
+	if got := canonicalVehicleName(extractVehicleName("ROG-131B - rebuild")); got != "rog-131" {
+		t.Errorf("aliased epic name = %q, want rog-131", got)
+	}
+	if got := canonicalVehicleName("ROG-132"); got != "rog-132" {
+		t.Errorf("unaliased name = %q, want rog-132", got)
+	}
+	for _, raw := range []string{`["ROG-131"]`, `{"": "ROG-131"}`, `{"Rogue 131": " "}`} {
+		if _, err := parseVehicleAliases(raw); err == nil {
+			t.Errorf("parseVehicleAliases(%s) = nil error", raw)
+		}
+	}
+}
+
+func TestWithPortfolioParent(t *testing.T) {
+	c, _ := testContext("/api/kpi/vos-tickets?portfolio_parent=vbuild-9000")
+	jql, parent, err := withPortfolioParent(c, vosTicketsJQL)
+	if err != nil || parent != "VBUILD-9000" || !strings.Contains(jql, "portfolioChildIssuesOf(VBUILD-9000)") {
+		t.Errorf("jql = %q, parent = %q, err = %v", jql, parent, err)
+	}
+	if _, _, err := withPortfolioParent(c, "project = VBUILD"); err == nil || !strings.Contains(err.Error(), "no effect") {
+		t.Errorf("override without the placeholder: err = %v, want the parameter rejected", err)
+	}
+
+	c, _ = testContext("/api/kpi/vos-tickets")
+	if jql, _, err := withPortfolioParent(c, "project = VBUILD"); err != nil || jql != "project = VBUILD" {
+		t.Errorf("without ?portfolio_parent=: jql = %q, err = %v", jql, err)
+	}
+}
+
+func TestGroupedSeriesSentOnce(t *testing.T) {
+	setJIRAEnv(t)
+	stubUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"issues": [{"key": "VBUILD-1", "fields": {"issuetype": {"name": "Bug Report"}}}], "total": 1}`)
+	})
+	get := func(path string, handler gin.HandlerFunc) map[string]json.RawMessage {
+		c, rec := testContext(path)
+		c.Request = c.Request.WithContext(withRequestValues(c.Request.Context()))
+		handler(c)
+		var body map[string]json.RawMessage
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || rec.Code != http.StatusOK {
+			t.Fatalf("%s: status %d: %s", path, rec.Code, rec.Body.String())
+		}
+		return body
+	}
+
+	bugs := get("/api/kpi/build-bugs?months=1&split_by_type=true", kpiBuildBugs)
+	if _, ok := bugs["groups"]; ok || !strings.Contains(string(bugs["by_type"]), `"Bug Report"`) {
+		t.Errorf("build bugs: groups = %s, by_type = %s; want by_type only", bugs["groups"], bugs["by_type"])
+	}
+	vos := get("/api/kpi/vos-tickets?months=1&split_by_type=true", kpiVOSTickets)
+	if _, ok := vos["by_type"]; ok {
+		t.Errorf("VOS tickets: by_type = %s, want split_by_type ignored", vos["by_type"])
+	}
+	// by_assignee likewise replaces groups rather than repeating it
+	byAssignee := get("/api/kpi/vos-tickets?months=1&group_by=assignee", kpiVOSTickets)
+	if _, ok := byAssignee["groups"]; ok || byAssignee["by_assignee"] == nil {
+		t.Errorf("VOS by assignee: groups = %s, by_assignee = %s; want by_assignee only", byAssignee["groups"], byAssignee["by_assignee"])
+	}
+}
+
+// Without Neuron, MTBF is a failure count; meta.drive_hours keeps the object shape it has when Neuron is set.
+func TestMTBFDriveHoursWithoutNeuron(t *testing.T) {
+	setJIRAEnv(t)
+	t.Setenv("NEURON_API_TOKEN", "")
+	stubUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"issues": [], "total": 0}`)
+	})
+	c, rec := testContext("/api/kpi/mtbf?months=1")
+	c.Request = c.Request.WithContext(withRequestValues(c.Request.Context()))
+	kpiMTBF(c)
+	var body struct {
+		Meta struct {
+			DriveHours    map[string]any `json:"drive_hours"`
+			DataAvailable string         `json:"data_available"`
+		} `json:"meta"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body.String())
+	}
+	if source, ok := body.Meta.DriveHours["source"]; !ok || source != nil {
+		t.Errorf("meta.drive_hours = %v, want {\"source\": null}", body.Meta.DriveHours)
+	}
+	if body.Meta.DataAvailable != "failures only" {
+		t.Errorf("meta.data_available = %q, want \"failures only\"", body.Meta.DataAvailable)
+	}
+}
+
+// With ?now= pinned mid-week, the last week's queries end at the pinned time, so a later re-run can't count
+// issues created or resolved after it.
+func TestIssuesByWeekEndsAtPinnedNow(t *testing.T) {
+	la, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Skip(err)
+	}
+	withBucketLocation(t, la)
+	var mu sync.Mutex
+	var jqls []string
+	stubUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		jqls = append(jqls, r.URL.Query().Get("jql"))
+		mu.Unlock()
+		fmt.Fprint(w, `{"issues": [], "total": 0}`)
+	})
+	c, _ := testContext("/api/kpi/vos-tickets")
+	now := time.Date(2024, 5, 15, 3, 0, 0, 0, time.UTC) // Tuesday 2024-05-14 20:00 in Los Angeles
+	c.Request = c.Request.WithContext(context.WithValue(withRequestValues(c.Request.Context()), nowOverrideCtxKey{}, now))
+
+	counts := issuesByWeek(c, "https://example.atlassian.net", "e", "t", weeklyIssueQuery{
+		component: "VOS", baseJQL: "project = VOS", months: 1, trackResolved: true, granularity: granularityWeek,
+	})
+	if last := counts.weeks[len(counts.weeks)-1]; last != "2024-W20" {
+		t.Fatalf("last week = %s, want 2024-W20", last)
+	}
+	for _, field := range []string{"created", "resolutiondate"} {
+		lastWeek := fmt.Sprintf("%s >= '2024-05-13' AND %s < '2024-05-14 20:00'", field, field)
+		earlierWeek := fmt.Sprintf("%s >= '2024-05-06' AND %s < '2024-05-13'", field, field)
+		var sawLast, sawEarlier bool
+		for _, jql := range jqls {
+			sawLast = sawLast || strings.Contains(jql, lastWeek)
+			sawEarlier = sawEarlier || strings.Contains(jql, earlierWeek)
+		}
+		if !sawLast || !sawEarlier {
+			t.Errorf("%s: no query with %q and %q in %q", field, lastWeek, earlierWeek, jqls)
+		}
+	}
+}
+
+// An epic resolved Monday 02:00 UTC may come back as Sunday evening in one system and Monday morning in another;
+// every bucket key must put both in the same week, day and month.
+func TestBucketKeysUseOneZone(t *testing.T) {
+	instant := time.Date(2024, 7, 1, 2, 0, 0, 0, time.UTC) // Monday; still June 30 in Los Angeles
+	offsets := []*time.Location{time.FixedZone("PDT", -7*3600), time.FixedZone("JST", 9*3600)}
+	for _, loc := range []string{"UTC", "America/Los_Angeles"} {
+		zone, err := time.LoadLocation(loc)
+		if err != nil {
+			t.Skip(err)
+		}
+		withBucketLocation(t, zone)
+		for _, g := range []bucketGranularity{granularityWeek, granularityMonth, granularityDay} {
+			want := g.key(instant)
+			for _, off := range offsets {
+				if got := g.key(instant.In(off)); got != want {
+					t.Errorf("%s %s key in %s = %s, want %s", loc, g, off, got, want)
+				}
+			}
+		}
+		for _, off := range offsets {
+			if got, want := weekdayKey(instant.In(off)), weekdayKey(instant); got != want {
+				t.Errorf("%s weekday in %s = %s, want %s", loc, off, got, want)
+			}
+		}
+	}
+}
+
+// The query windows and the keys data is bucketed into must agree, or a week's count lands in its neighbour.
+func TestBucketRangesMatchKeys(t *testing.T) {
+	zone, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Skip(err)
+	}
+	withBucketLocation(t, zone)
+	now := time.Date(2024, 11, 15, 12, 0, 0, 0, time.UTC) // the window spans the November DST change
+	for _, g := range []bucketGranularity{granularityWeek, granularityMonth, granularityDay} {
+		for _, r := range g.ranges(now.AddDate(0, -3, 0), now) {
+			first, last := r.start.UTC(), r.end.Add(-time.Nanosecond).UTC()
+			if g.key(first) != r.key || g.key(last) != r.key || g.key(r.end) == r.key {
+				t.Errorf("%s range %s: [%s, %s) keys as %s..%s", g, r.key, r.start, r.end, g.key(first), g.key(last))
+			}
+		}
+	}
+}
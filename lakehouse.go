@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// lakehouseWeeklyHoursPath is the query-service path returning weekly valid/total collection hours.
+// PLACEHOLDER - replace with the actual path once the lakehouse query service exposes it
+const lakehouseWeeklyHoursPath = "/api/v1/data-collection/weekly-hours"
+
+func lakehouseConfig() (baseURL, token string, ok bool) {
+	baseURL = strings.TrimRight(strings.TrimSpace(os.Getenv("LAKEHOUSE_API_URL")), "/")
+	token = strings.TrimSpace(os.Getenv("LAKEHOUSE_API_TOKEN"))
+	if baseURL == "" || token == "" {
+		return "", "", false
+	}
+	return baseURL, token, true
+}
+
+func lakehouseConfigMissing() []string {
+	var missing []string
+	if strings.TrimSpace(os.Getenv("LAKEHOUSE_API_URL")) == "" {
+		missing = append(missing, "LAKEHOUSE_API_URL")
+	}
+	if strings.TrimSpace(os.Getenv("LAKEHOUSE_API_TOKEN")) == "" {
+		missing = append(missing, "LAKEHOUSE_API_TOKEN")
+	}
+	return missing
+}
+
+// lakehouseWeeklyHours is one week of collection hours (TODO: confirm field names against the actual API)
+type lakehouseWeeklyHours struct {
+	WeekStart  string  `json:"week_start"` // YYYY-MM-DD (Monday)
+	ValidHours float64 `json:"valid_hours"`
+	TotalHours float64 `json:"total_hours"`
+}
+
+// fetchLakehouseWeeklyHours returns valid and total collection hours per week between start and end.
+func fetchLakehouseWeeklyHours(ctx context.Context, baseURL, token string, start, end time.Time) ([]lakehouseWeeklyHours, error) {
+	q := url.Values{}
+	q.Set("start_date", start.Format("2006-01-02"))
+	q.Set("end_date", end.Format("2006-01-02"))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+lakehouseWeeklyHoursPath+"?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return nil, newUpstreamError(resp.StatusCode, "weekly hours: %d %s", resp.StatusCode, string(body))
+	}
+	var out struct {
+		Weeks []lakehouseWeeklyHours `json:"weeks"`
+	}
+	if err := json.Unmarshal(body, &out); err != nil {
+		return nil, err
+	}
+	return out.Weeks, nil
+}
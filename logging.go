@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// logger writes JSON lines to stderr; request-scoped lines carry the request ID and route (see logf).
+var logger = slog.New(slog.NewJSONHandler(os.Stderr, nil))
+
+// requestIDHeader is read from incoming requests (so a proxy's ID carries through) and echoed on responses.
+const requestIDHeader = "X-Request-ID"
+
+// requestIDPattern bounds what an incoming X-Request-ID may contain; anything else gets a generated ID.
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// requestLogCtxKey is the request context key holding the request's requestLogInfo.
+type requestLogCtxKey struct{}
+
+type requestLogInfo struct {
+	id    string
+	route string
+}
+
+// newRequestID returns a random UUID (version 4).
+func newRequestID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// requestLogMiddleware assigns the request ID (X-Request-ID if valid, otherwise a new UUID), makes it available
+// to logf through the request context and as c.GetString("request_id"), echoes it in the response header, and
+// writes one access line per request with route, status and latency.
+func requestLogMiddleware(c *gin.Context) {
+	start := time.Now()
+	id := c.GetHeader(requestIDHeader)
+	if !requestIDPattern.MatchString(id) {
+		id = newRequestID()
+	}
+	route := c.FullPath()
+	c.Set("request_id", id)
+	c.Header(requestIDHeader, id)
+	c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), requestLogCtxKey{}, requestLogInfo{id: id, route: route}))
+
+	c.Next()
+
+	logger.Info("request",
+		"request_id", id,
+		"method", c.Request.Method,
+		"route", route,
+		"path", c.Request.URL.Path,
+		"status", c.Writer.Status(),
+		"latency_ms", time.Since(start).Milliseconds(),
+	)
+}
+
+// logf writes a JSON log line for component (the old "[VOS]"-style prefix), tagged with the request ID and
+// route when ctx belongs to a request.
+func logf(ctx context.Context, component, format string, args ...interface{}) {
+	attrs := []interface{}{"component", component}
+	if info, ok := ctx.Value(requestLogCtxKey{}).(requestLogInfo); ok {
+		attrs = append(attrs, "request_id", info.id, "route", info.route)
+	}
+	logger.Info(fmt.Sprintf(format, args...), attrs...)
+}
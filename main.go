@@ -1,11 +1,18 @@
 package main
 
 import (
+	"context"
 	"embed"
+	"errors"
 	"io/fs"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"path"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
@@ -21,56 +28,145 @@ type Response struct {
 
 func main() {
 	// Load .env from project root (no-op if file missing; env vars already set take precedence)
-	_ = godotenv.Load()
+	dotenvLoaded = godotenv.Load() == nil
+	loadConfig()
+	validateConfig()
 
-	r := gin.Default()
+	r := newRouter()
+	port := listenPort()
+
+	srv := &http.Server{Addr: ":" + port, Handler: r}
+	go func() {
+		log.Printf("Server starting on port %s\n", port)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
+
+	// On SIGINT/SIGTERM stop accepting connections and let in-flight KPI requests finish (SHUTDOWN_GRACE_SEC)
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	sig := <-stop
+	grace := envSeconds("SHUTDOWN_GRACE_SEC", 15*time.Second)
+	log.Printf("Received %v; shutting down (grace period %v)", sig, grace)
+	ctx, cancel := context.WithTimeout(context.Background(), grace)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Printf("Shutdown did not complete cleanly: %v", err)
+		return
+	}
+	log.Println("Shutdown complete")
+}
+
+// newRouter builds the API routes, middleware and the frontend fallback.
+func newRouter() *gin.Engine {
+	r := gin.New()
+	// JSON access log with request IDs (replaces gin's text logger), Prometheus request metrics, then panic recovery
+	r.Use(requestLogMiddleware, metricsMiddleware, gin.Recovery(), corsMiddleware)
+	r.GET("/metrics", metricsHandler)
 
 	// API routes
 	api := r.Group("/api")
+	api.Use(gzipMiddleware, requestValuesMiddleware, nowOverrideMiddleware)
 	{
 		api.GET("/hello", func(c *gin.Context) {
 			c.JSON(http.StatusOK, Response{
 				Message: "Hello from Go backend!",
 			})
 		})
+		api.GET("/health", health)
+		api.GET("/health/deep", healthDeep)
+		api.GET("/config", configDump)
 		api.GET("/jira/search", jiraSearch)
+		api.GET("/jira/filters", jiraFilters)
+		api.GET("/jira/issues-in-week", jiraIssuesInWeek)
+		api.GET("/jira/export", jiraExport)
+		api.GET("/kpi/catalog", kpiCatalog)
 		api.GET("/kpi/time-in-build", kpiTimeInBuild)
+		api.GET("/kpi/time-in-build/data-quality", kpiTimeInBuildDataQuality)
+		api.GET("/kpi/time-in-build/validate", kpiTimeInBuildValidate)
+		api.GET("/kpi/time-in-build/wip", kpiTimeInBuildWIP)
 		api.GET("/kpi/debug-epic", kpiDebugEpic)
-		api.GET("/kpi/vos-tickets", kpiVOSTickets)
-		api.GET("/kpi/build-bugs", kpiBuildBugs)
-		api.GET("/kpi/mtbf", kpiMTBF)
+		api.GET("/kpi/epic-timeline/:key", kpiEpicTimeline)
+		api.GET("/kpi/vos-tickets", responseCacheMiddleware(vosResponseCache), kpiVOSTickets)
+		api.GET("/kpi/build-bugs", responseCacheMiddleware(buildBugsResponseCache), kpiBuildBugs)
+		api.GET("/kpi/mtbf", responseCacheMiddleware(mtbfResponseCache), kpiMTBF)
+		api.GET("/kpi/summary", kpiSummary)
 		api.GET("/fleetio/me", fleetioMe)
 		api.GET("/fleetio/vehicles", fleetioVehicles)
+		api.GET("/fleetio/vehicles/all", fleetioVehiclesAll)
+		api.GET("/fleetio/vehicles/:id/meters", fleetioVehicleMeters)
+		api.GET("/neuron/vehicle-faults", neuronVehicleFaults)
+		api.GET("/neuron/drive-hours-weekly", neuronDriveHoursWeekly)
 		api.GET("/kpi/buildkite-deployment-time", kpiBuildkiteDeploymentTime)
 		api.GET("/kpi/buildkite-deployment-failure-rate", kpiBuildkiteDeploymentFailureRate)
 		api.GET("/kpi/buildkite-combined", kpiBuildkiteCombined)                 // Optimized: both metrics in one call (weekly, 3 months) - DEPRECATED
 		api.GET("/kpi/buildkite-combined-daily", kpiBuildkiteCombinedDaily)      // Daily metrics (last 30 days) - DEPRECATED
 		api.GET("/kpi/buildkite-combined-all", kpiBuildkiteCombinedAll)          // Optimized: weekly + daily in one call with caching
-		api.GET("/kpi/data-collection-efficiency", kpiDataCollectionEfficiency)  // TODO: Integrate with lakehouse via KunaalC's query service
+		api.GET("/kpi/deploy-health", kpiDeployHealth)                          // Weekly 0-100 composite of failure rate, frequency, duration
+		api.GET("/buildkite/adhoc", buildkiteAdhoc)                              // Ad-hoc metrics for ?pipelines=a,b (bypasses configured pipelines)
+		api.POST("/buildkite/cache/refresh", buildkiteCacheRefresh)              // Clear the BuildKite build cache and refetch the 3-month window
+		api.GET("/buildkite/builds", buildkiteBuildsDebug)                       // Parsed builds with computed duration, for debugging a metric
+		api.GET("/buildkite/builds/:number/jobs", buildkiteBuildJobs)            // Per-job timing for one build (top-N by duration + others)
+		api.GET("/buildkite/builds/:number/annotations", buildkiteBuildAnnotations) // Annotations (context, style, html) for one build, e.g. failure reasons
+		api.GET("/kpi/data-collection-efficiency", kpiDataCollectionEfficiency)  // Valid/total collection hours from the lakehouse query service
 	}
 
 	// Serve embedded frontend in production, or proxy to Vite in dev
 	if os.Getenv("ENV") == "dev" {
 		// In dev mode, frontend runs separately on Vite
 		log.Println("Running in dev mode - frontend should be served by Vite on :3000")
+		r.NoRoute(func(c *gin.Context) {
+			if isAPIPath(c.Request.URL.Path) {
+				apiNotFound(c)
+				return
+			}
+			c.String(http.StatusNotFound, "404 page not found")
+		})
 	} else {
 		// Serve embedded frontend
 		distFS, err := fs.Sub(frontendFS, "frontend/dist")
 		if err != nil {
 			log.Fatal(err)
 		}
-		r.NoRoute(func(c *gin.Context) {
-			c.FileFromFS(c.Request.URL.Path, http.FS(distFS))
+		r.NoRoute(gzipMiddleware, func(c *gin.Context) {
+			// Unknown API calls get a JSON 404, not the SPA's index.html
+			if isAPIPath(c.Request.URL.Path) {
+				apiNotFound(c)
+				return
+			}
+			c.FileFromFS(spaPath(distFS, c.Request.URL.Path), http.FS(distFS))
 		})
 	}
+	return r
+}
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8082"
-	}
+func isAPIPath(path string) bool {
+	return path == "/api" || strings.HasPrefix(path, "/api/")
+}
 
-	log.Printf("Server starting on port %s\n", port)
-	if err := r.Run(":" + port); err != nil {
-		log.Fatal(err)
+// spaPath maps a request path to the file to serve from the frontend build: the path itself when that file
+// exists, otherwise "/" (index.html) so client-side routes like /kpi/mtbf survive a refresh or bookmark.
+// Missing files under /assets/ still 404, so broken asset references stay visible.
+func spaPath(distFS fs.FS, urlPath string) string {
+	if strings.HasPrefix(urlPath, "/assets/") {
+		return urlPath
 	}
+	name := strings.TrimPrefix(path.Clean(urlPath), "/")
+	if name == "" {
+		return "/"
+	}
+	if info, err := fs.Stat(distFS, name); err == nil && !info.IsDir() {
+		return urlPath
+	}
+	return "/"
+}
+
+// apiNotFound is the JSON 404 for unmatched /api/* routes (e.g. a typo like /api/kip/mtbf).
+func apiNotFound(c *gin.Context) {
+	c.JSON(http.StatusNotFound, gin.H{
+		"error":  "unknown API route",
+		"method": c.Request.Method,
+		"path":   c.Request.URL.Path,
+	})
 }
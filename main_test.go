@@ -0,0 +1,43 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestUnknownAPIRouteIsJSON404(t *testing.T) {
+	for _, env := range []string{"", "dev"} {
+		t.Run("ENV="+env, func(t *testing.T) {
+			t.Setenv("ENV", env)
+			r := newRouter()
+			for _, path := range []string{"/api/kip/mtbf", "/api", "/api/kpi/vos-tickets/extra"} {
+				rec := httptest.NewRecorder()
+				r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+
+				if rec.Code != http.StatusNotFound {
+					t.Errorf("%s: status %d, want 404", path, rec.Code)
+				}
+				if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+					t.Errorf("%s: Content-Type %q, want JSON", path, ct)
+				}
+				var body struct{ Error, Path string }
+				if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body.Error != "unknown API route" || body.Path != path {
+					t.Errorf("%s: body %s (%v)", path, rec.Body.String(), err)
+				}
+			}
+		})
+	}
+}
+
+func TestClientRouteServesSPA(t *testing.T) {
+	t.Setenv("ENV", "")
+	rec := httptest.NewRecorder()
+	newRouter().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/kpi/mtbf", nil))
+
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/html") {
+		t.Fatalf("status %d, Content-Type %q; want the SPA's index.html", rec.Code, rec.Header().Get("Content-Type"))
+	}
+}
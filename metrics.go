@@ -0,0 +1,100 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Prometheus metrics, registered on the default registry and served at GET /metrics.
+var (
+	httpRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "Requests served, by route and status.",
+	}, []string{"method", "route", "status"})
+	httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Request latency by route.",
+		Buckets: []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"method", "route"})
+
+	upstreamRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "upstream_requests_total",
+		Help: "Outbound calls by integration and HTTP status (\"error\" when no response came back).",
+	}, []string{"service", "status"})
+	upstreamRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "upstream_request_duration_seconds",
+		Help:    "Outbound call latency by integration.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"service"})
+	upstreamErrorsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "upstream_errors_total",
+		Help: "Outbound calls that failed: no response, or a 4xx/5xx status.",
+	}, []string{"service"})
+
+	buildkiteCacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "buildkite_cache_lookups_total",
+		Help: "BuildKite build cache lookups: hit (fresh), miss (fetched), stale (served after a failed refresh).",
+	}, []string{"result"})
+)
+
+// metricsMiddleware counts requests and their latency per route (the gin route pattern, so /:key doesn't explode cardinality).
+func metricsMiddleware(c *gin.Context) {
+	start := time.Now()
+	c.Next()
+	route := c.FullPath()
+	if route == "" {
+		route = "unmatched"
+	}
+	httpRequestsTotal.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).Inc()
+	httpRequestDuration.WithLabelValues(c.Request.Method, route).Observe(time.Since(start).Seconds())
+}
+
+// metricsHandler serves the default Prometheus registry.
+var metricsHandler = gin.WrapH(promhttp.Handler())
+
+// metricsTransport records upstream_* metrics for every request httpClient sends.
+type metricsTransport struct {
+	base http.RoundTripper
+}
+
+func (t metricsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	service := upstreamService(req.URL)
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	upstreamRequestDuration.WithLabelValues(service).Observe(time.Since(start).Seconds())
+	status := "error"
+	if err == nil {
+		status = strconv.Itoa(resp.StatusCode)
+	}
+	upstreamRequestsTotal.WithLabelValues(service, status).Inc()
+	if err != nil || resp.StatusCode >= 400 {
+		upstreamErrorsTotal.WithLabelValues(service).Inc()
+	}
+	return resp, err
+}
+
+// upstreamService names the integration a request goes to by matching its host against each integration's base URL.
+func upstreamService(u *url.URL) string {
+	jiraURL, _, _, _ := jiraConfig()
+	neuronURL, _, _ := neuronConfig()
+	lakehouseURL, _, _ := lakehouseConfig()
+	for service, base := range map[string]string{
+		"jira":      jiraURL,
+		"buildkite": buildkiteBaseURL,
+		"fleetio":   fleetioBaseURL,
+		"neuron":    neuronURL,
+		"lakehouse": lakehouseURL,
+	} {
+		if b, err := url.Parse(base); err == nil && b.Host != "" && b.Host == u.Host {
+			return service
+		}
+	}
+	return "other"
+}
@@ -1,12 +1,18 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
+	"net/url"
 	"os"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -34,8 +40,10 @@ func neuronConfigMissing() []string {
 	return missing
 }
 
-// GET /api/neuron/vehicle-hours - get vehicle operation hours
-// TODO: Update path, query params, and response parsing once API is discovered
+// GET /api/neuron/vehicle-hours - vehicle operation hours, normalized to {vehicles, total_drive_hours}
+// Query: project (default "Default"), workspace, start_date, end_date (ISO 8601)
+// If the response doesn't decode into NeuronVehicleMetrics it is returned under "raw" with a warning.
+// TODO: Update path and query params once API is discovered
 func neuronVehicleHours(c *gin.Context) {
 	baseURL, token, ok := neuronConfig()
 	if !ok {
@@ -48,93 +56,369 @@ func neuronVehicleHours(c *gin.Context) {
 		return
 	}
 
-	// TODO: Replace with actual API path once discovered (see docs/neuron-api-discovery.md)
-	// Example paths to try:
-	// - /api/v1/metrics/vehicle-hours
-	// - /api/vehicles/operation-hours
-	// - /validation_toolset/api/metrics
-	path := "/api/v1/metrics/vehicle-hours" // PLACEHOLDER - replace with actual path
-
-	// TODO: Add query params based on dashboard URL pattern
-	project := c.DefaultQuery("project", "Default")
-	workspace := c.DefaultQuery("workspace", "")
-	startDate := c.Query("start_date") // ISO 8601 format
-	endDate := c.Query("end_date")
-
-	// Build request URL
-	reqURL := baseURL + path
-	if project != "" || workspace != "" || startDate != "" || endDate != "" {
-		reqURL += "?"
-		params := []string{}
-		if project != "" {
-			params = append(params, "project="+project)
+	q := url.Values{}
+	q.Set("project", c.DefaultQuery("project", "Default"))
+	for _, name := range []string{"workspace", "start_date", "end_date"} {
+		if v := c.Query(name); v != "" {
+			q.Set(name, v)
 		}
-		if workspace != "" {
-			params = append(params, "workspace="+workspace)
+	}
+
+	metrics, body, err := fetchNeuronVehicleMetrics(c.Request.Context(), baseURL, token, q)
+	var ue *upstreamError
+	if errors.As(err, &ue) || (err != nil && body == nil) {
+		writeNeuronRequestError(c, err, body)
+		return
+	}
+	if err != nil {
+		// Shape differs from NeuronVehicleMetrics: pass the body through so API discovery can continue
+		var raw interface{}
+		if json.Unmarshal(body, &raw) != nil {
+			raw = string(body)
 		}
-		if startDate != "" {
-			params = append(params, "start_date="+startDate)
+		c.JSON(http.StatusOK, gin.H{
+			"raw":     raw,
+			"warning": "Neuron response didn't match the expected vehicle-hours shape: " + err.Error(),
+			"hint":    "Update NeuronVehicleMetrics in neuron.go to match the response.",
+			"source":  sourceNeuron,
+		})
+		return
+	}
+
+	var total float64
+	for _, v := range metrics.Vehicles {
+		total += v.DriveHours
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"vehicles":          metrics.Vehicles,
+		"total_drive_hours": math.Round(total*10) / 10,
+		"source":            sourceNeuron,
+	})
+}
+
+// GET /api/neuron/drive-hours-weekly - fleet drive hours bucketed by ISO week, shaped like the JIRA KPIs
+// ({weeks, week_ranges, drive_hours, meta}) so the frontend can chart it alongside them.
+// Query: start_date, end_date (YYYY-MM-DD, default last 3 months), project
+func neuronDriveHoursWeekly(c *gin.Context) {
+	baseURL, token, ok := neuronConfig()
+	if !ok {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error":   "Neuron not configured",
+			"missing": neuronConfigMissing(),
+			"hint":    "Set NEURON_API_TOKEN in .env or environment. Use docs/neuron-api-discovery.md to find API details.",
+		})
+		return
+	}
+
+	endDate := requestNow(c).In(bucketLocation)
+	startDate := endDate.AddDate(0, -3, 0)
+	for name, dst := range map[string]*time.Time{"start_date": &startDate, "end_date": &endDate} {
+		if raw := c.Query(name); raw != "" {
+			t, err := time.ParseInLocation("2006-01-02", raw, bucketLocation)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": name + " must be YYYY-MM-DD"})
+				return
+			}
+			*dst = t
 		}
-		if endDate != "" {
-			params = append(params, "end_date="+endDate)
+	}
+	if !endDate.After(startDate) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "end_date must be after start_date"})
+		return
+	}
+
+	q := url.Values{}
+	q.Set("project", c.DefaultQuery("project", "Default"))
+	q.Set("start_date", startDate.Format("2006-01-02"))
+	q.Set("end_date", endDate.Format("2006-01-02"))
+	metrics, _, err := fetchNeuronVehicleMetrics(c.Request.Context(), baseURL, token, q)
+	if err != nil {
+		c.JSON(upstreamFailureStatus(err), upstreamErrorBody("Neuron vehicle hours: ", err))
+		return
+	}
+	byWeek, skipped := metrics.weeklyDriveHours()
+
+	// every week in the range, so weeks without data chart as 0 rather than disappearing
+	weekStartDate := startDate
+	for weekStartDate.Weekday() != time.Monday {
+		weekStartDate = weekStartDate.AddDate(0, 0, -1)
+	}
+	var weeks []string
+	for ws := weekStartDate; !ws.After(endDate); ws = ws.AddDate(0, 0, 7) {
+		weeks = append(weeks, weekKey(ws))
+	}
+	driveHours := make([]float64, len(weeks))
+	var total float64
+	for i, w := range weeks {
+		driveHours[i] = math.Round(byWeek[w]*10) / 10
+		total += byWeek[w]
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"weeks":       weeks,
+		"week_ranges": weekRangeLabels(weeks),
+		"drive_hours": driveHours,
+		"meta": gin.H{
+			"source":            sourceNeuron,
+			"vehicle_days":      len(metrics.Vehicles),
+			"entries_skipped":   skipped,
+			"total_drive_hours": math.Round(total*10) / 10,
+			"date_range":        fmt.Sprintf("%s to %s", startDate.Format("2006-01-02"), endDate.Format("2006-01-02")),
+			"bucket_timezone":   bucketLocation.String(),
+			"note":              "PLACEHOLDER endpoint path and fields; see docs/neuron-api-discovery.md",
+		},
+	})
+}
+
+// neuronFaultEvent is one fault/DTC event (TODO: update field names once the API is discovered)
+type neuronFaultEvent struct {
+	Vehicle   string `json:"vehicle"`
+	Code      string `json:"code"` // DTC, e.g. P0A80
+	Severity  string `json:"severity"`
+	Timestamp string `json:"timestamp"`
+}
+
+// GET /api/neuron/vehicle-faults - fault/DTC events bucketed by week and vehicle (finer-grained failure signal for MTBF)
+// Query: start_date, end_date (YYYY-MM-DD, default last 12 weeks), vehicle (optional), project
+// TODO: Update path, query params, and response parsing once API is discovered
+func neuronVehicleFaults(c *gin.Context) {
+	baseURL, token, ok := neuronConfig()
+	if !ok {
+		missing := neuronConfigMissing()
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error":   "Neuron not configured",
+			"missing": missing,
+			"hint":    "Set NEURON_API_TOKEN in .env or environment. Use docs/neuron-api-discovery.md to find API details.",
+		})
+		return
+	}
+
+	path := "/api/v1/vehicles/faults" // PLACEHOLDER - replace with actual path
+
+	endDate := requestNow(c).In(bucketLocation)
+	startDate := endDate.AddDate(0, 0, -84)
+	for name, dst := range map[string]*time.Time{"start_date": &startDate, "end_date": &endDate} {
+		if raw := c.Query(name); raw != "" {
+			t, err := time.ParseInLocation("2006-01-02", raw, bucketLocation)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": name + " must be YYYY-MM-DD"})
+				return
+			}
+			*dst = t
 		}
-		reqURL += strings.Join(params, "&")
+	}
+	if !endDate.After(startDate) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "end_date must be after start_date"})
+		return
+	}
+
+	q := url.Values{}
+	q.Set("start_date", startDate.Format("2006-01-02"))
+	q.Set("end_date", endDate.Format("2006-01-02"))
+	q.Set("project", c.DefaultQuery("project", "Default"))
+	if vehicle := c.Query("vehicle"); vehicle != "" {
+		q.Set("vehicle", vehicle)
 	}
 
-	req, err := http.NewRequestWithContext(c.Request.Context(), http.MethodGet, reqURL, nil)
+	body, err := neuronGet(c.Request.Context(), baseURL, token, path, q)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		writeNeuronRequestError(c, err, body)
 		return
 	}
 
-	// TODO: Update auth header based on what you find in DevTools
-	// Common patterns:
-	// - Authorization: Bearer <token>
-	// - X-API-Key: <token>
-	// - Cookie: session=<token>
+	// Accept a bare array or an object wrapping it in "events" or "faults"
+	var events []neuronFaultEvent
+	if err := json.Unmarshal(body, &events); err != nil {
+		var wrapped struct {
+			Events []neuronFaultEvent `json:"events"`
+			Faults []neuronFaultEvent `json:"faults"`
+		}
+		if err := json.Unmarshal(body, &wrapped); err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{
+				"error": "invalid Neuron response: " + err.Error(),
+				"hint":  "Response format may differ from expected. Check raw response in DevTools.",
+			})
+			return
+		}
+		// Any other JSON object decodes cleanly into an empty struct; that's a shape mismatch, not a fault-free fleet
+		if wrapped.Events == nil && wrapped.Faults == nil {
+			c.JSON(http.StatusBadGateway, gin.H{
+				"error": `invalid Neuron response: no "events" or "faults" field`,
+				"hint":  "Response format may differ from expected. Check raw response in DevTools.",
+			})
+			return
+		}
+		events = append(wrapped.Events, wrapped.Faults...)
+	}
+
+	// Bucket by week and vehicle
+	byVehicleWeek := make(map[string]map[string]int)
+	weekTotals := make(map[string]int)
+	codes := make(map[string]int)
+	skipped := 0
+	for _, ev := range events {
+		t, ok := parseTime(ev.Timestamp)
+		if !ok || ev.Vehicle == "" {
+			skipped++
+			continue
+		}
+		w := weekKey(t)
+		if byVehicleWeek[ev.Vehicle] == nil {
+			byVehicleWeek[ev.Vehicle] = make(map[string]int)
+		}
+		byVehicleWeek[ev.Vehicle][w]++
+		weekTotals[w]++
+		if ev.Code != "" {
+			codes[ev.Code]++
+		}
+	}
+	weeks := sortedKeys(weekTotals)
+	totals := make([]int, len(weeks))
+	for i, w := range weeks {
+		totals[i] = weekTotals[w]
+	}
+	byVehicle := make(map[string][]int, len(byVehicleWeek))
+	for vehicle, perWeek := range byVehicleWeek {
+		counts := make([]int, len(weeks))
+		for i, w := range weeks {
+			counts[i] = perWeek[w]
+		}
+		byVehicle[vehicle] = counts
+	}
+	type codeCount struct {
+		Code  string `json:"code"`
+		Count int    `json:"count"`
+	}
+	var topCodes []codeCount
+	for code, n := range codes {
+		topCodes = append(topCodes, codeCount{code, n})
+	}
+	sort.Slice(topCodes, func(i, j int) bool {
+		if topCodes[i].Count != topCodes[j].Count {
+			return topCodes[i].Count > topCodes[j].Count
+		}
+		return topCodes[i].Code < topCodes[j].Code
+	})
+	if len(topCodes) > 10 {
+		topCodes = topCodes[:10]
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"weeks":       weeks,
+		"week_ranges": weekRangeLabels(weeks),
+		"faults":      totals,
+		"by_vehicle":  byVehicle,
+		"top_codes":   topCodes,
+		"meta": gin.H{
+			"source":          sourceNeuron,
+			"events_seen":     len(events),
+			"events_skipped":  skipped,
+			"vehicles":        len(byVehicle),
+			"date_range":      fmt.Sprintf("%s to %s", startDate.Format("2006-01-02"), endDate.Format("2006-01-02")),
+			"bucket_timezone": bucketLocation.String(),
+			"note":            "PLACEHOLDER endpoint path and event fields; see docs/neuron-api-discovery.md",
+		},
+	})
+}
+
+// neuronGet GETs path with query q from the Neuron API. A non-200 response is an upstreamError, returned with
+// Neuron's body so callers can show it.
+func neuronGet(ctx context.Context, baseURL, token, path string, q url.Values) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+path+"?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
 	req.Header.Set("Authorization", "Bearer "+token)
 	req.Header.Set("Accept", "application/json")
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := httpClient.Do(req)
 	if err != nil {
-		c.JSON(http.StatusBadGateway, gin.H{"error": "Neuron request failed: " + err.Error()})
-		return
+		return nil, err
 	}
 	defer resp.Body.Close()
 	body, _ := io.ReadAll(resp.Body)
-
 	if resp.StatusCode != http.StatusOK {
-		c.JSON(resp.StatusCode, gin.H{
-			"error":  fmt.Sprintf("Neuron API returned %d", resp.StatusCode),
-			"detail": string(body),
-			"hint":   "API endpoint may be incorrect. Check docs/neuron-api-discovery.md to find correct endpoint.",
-		})
-		return
+		return body, newUpstreamError(resp.StatusCode, "%s: %d %s", path, resp.StatusCode, string(body))
 	}
+	return body, nil
+}
 
-	// TODO: Update response parsing based on actual API response format
-	var data interface{}
-	if err := json.Unmarshal(body, &data); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "invalid Neuron response: " + err.Error(),
-			"hint":  "Response format may differ from expected. Check raw response in DevTools.",
-		})
+// writeNeuronRequestError answers a failed neuronGet: Neuron's own status and body for an HTTP error, otherwise
+// the usual upstream failure.
+func writeNeuronRequestError(c *gin.Context, err error, body []byte) {
+	var ue *upstreamError
+	if !errors.As(err, &ue) {
+		c.JSON(upstreamFailureStatus(err), upstreamErrorBody("Neuron request failed: ", err))
 		return
 	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"data":   data,
-		"source": "neuron",
+	c.JSON(ue.Status, gin.H{
+		"error":           fmt.Sprintf("Neuron API returned %d", ue.Status),
+		"detail":          string(body),
+		"upstream_status": ue.Status,
+		"hint":            "API endpoint may be incorrect. Check docs/neuron-api-discovery.md to find correct endpoint.",
 	})
 }
 
-// Example response structure (TODO: update based on actual API)
-// type NeuronVehicleMetrics struct {
-// 	Vehicles []struct {
-// 		ID              string  `json:"id"`
-// 		Name            string  `json:"name"`
-// 		OperationHours  float64 `json:"operation_hours"`
-// 		DriveHours      float64 `json:"drive_hours"`
-// 		Date            string  `json:"date"`
-// 	} `json:"vehicles"`
-// }
+// neuronVehicleHoursPath is the vehicle-hours metrics path.
+// PLACEHOLDER - replace with actual path once discovered (see docs/neuron-api-discovery.md)
+const neuronVehicleHoursPath = "/api/v1/metrics/vehicle-hours"
+
+// NeuronVehicleMetrics is the vehicle-hours response (TODO: confirm field names against the actual API)
+type NeuronVehicleMetrics struct {
+	Vehicles []NeuronVehicleHours `json:"vehicles"`
+}
+
+// NeuronVehicleHours is one vehicle's hours for one day.
+type NeuronVehicleHours struct {
+	ID             string  `json:"id"`
+	Name           string  `json:"name"`
+	OperationHours float64 `json:"operation_hours"`
+	DriveHours     float64 `json:"drive_hours"`
+	Date           string  `json:"date"`
+}
+
+// fetchNeuronVehicleMetrics calls the vehicle-hours endpoint with q and decodes the response. The raw body is
+// returned alongside (also when decoding fails or the response has no "vehicles" field) so callers can show it
+// while the API is being discovered.
+func fetchNeuronVehicleMetrics(ctx context.Context, baseURL, token string, q url.Values) (*NeuronVehicleMetrics, []byte, error) {
+	body, err := neuronGet(ctx, baseURL, token, neuronVehicleHoursPath, q)
+	if err != nil {
+		return nil, body, err
+	}
+	var metrics NeuronVehicleMetrics
+	if err := json.Unmarshal(body, &metrics); err != nil {
+		return nil, body, err
+	}
+	if metrics.Vehicles == nil {
+		// Any other JSON object decodes cleanly into an empty struct; treat it as a shape mismatch, not zero hours
+		return nil, body, errors.New(`no "vehicles" field`)
+	}
+	return &metrics, body, nil
+}
+
+// parseNeuronDate accepts a bare date (read in bucketLocation, so it lands in the right week) or an RFC 3339 time.
+func parseNeuronDate(s string) (time.Time, bool) {
+	if t, err := time.ParseInLocation("2006-01-02", s, bucketLocation); err == nil {
+		return t, true
+	}
+	return parseTime(s)
+}
+
+// weeklyDriveHours sums drive hours per week key; skipped counts entries whose date couldn't be parsed.
+func (m *NeuronVehicleMetrics) weeklyDriveHours() (byWeek map[string]float64, skipped int) {
+	return m.driveHoursBy(weekKey)
+}
+
+// driveHoursBy sums drive hours per bucket (weekKey, monthKey, ...); skipped counts entries whose date couldn't be parsed.
+func (m *NeuronVehicleMetrics) driveHoursBy(bucket func(time.Time) string) (byBucket map[string]float64, skipped int) {
+	byBucket = make(map[string]float64)
+	for _, v := range m.Vehicles {
+		t, ok := parseNeuronDate(v.Date)
+		if !ok {
+			skipped++
+			continue
+		}
+		byBucket[bucket(t)] += v.DriveHours
+	}
+	return byBucket, skipped
+}
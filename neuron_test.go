@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+const sampleNeuronVehicleHours = `{
+	"vehicles": [
+		{"id": "v1", "name": "ROG-01", "operation_hours": 8.5, "drive_hours": 6.25, "date": "2024-05-13"},
+		{"id": "v2", "name": "MCE-07", "operation_hours": 4, "drive_hours": 3.5, "date": "2024-05-14"},
+		{"id": "v1", "name": "ROG-01", "operation_hours": 2, "drive_hours": 1.3, "date": "2024-05-20"}
+	],
+	"next_cursor": null
+}`
+
+func TestNeuronVehicleMetricsDecodesSamplePayload(t *testing.T) {
+	var m NeuronVehicleMetrics
+	if err := json.Unmarshal([]byte(sampleNeuronVehicleHours), &m); err != nil {
+		t.Fatal(err)
+	}
+	want := NeuronVehicleHours{ID: "v2", Name: "MCE-07", OperationHours: 4, DriveHours: 3.5, Date: "2024-05-14"}
+	if len(m.Vehicles) != 3 || m.Vehicles[1] != want {
+		t.Fatalf("vehicles = %+v", m.Vehicles)
+	}
+
+	byWeek, skipped := m.weeklyDriveHours()
+	if skipped != 0 || byWeek["2024-W20"] != 9.75 || byWeek["2024-W21"] != 1.3 {
+		t.Errorf("weekly drive hours = %v (skipped %d)", byWeek, skipped)
+	}
+}
+
+func TestNeuronVehicleHoursNormalizesResponse(t *testing.T) {
+	t.Setenv("NEURON_API_TOKEN", "token")
+	stubUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(sampleNeuronVehicleHours))
+	})
+	c, rec := testContext("/api/neuron/vehicle-hours")
+	neuronVehicleHours(c)
+
+	var body struct {
+		Vehicles        []NeuronVehicleHours `json:"vehicles"`
+		TotalDriveHours float64              `json:"total_drive_hours"`
+		Raw             interface{}          `json:"raw"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusOK || len(body.Vehicles) != 3 || body.Raw != nil {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body.String())
+	}
+	if body.TotalDriveHours != 11.1 {
+		t.Errorf("total_drive_hours = %v, want 11.1", body.TotalDriveHours)
+	}
+}
+
+func TestNeuronVehicleHoursFallsBackToRaw(t *testing.T) {
+	t.Setenv("NEURON_API_TOKEN", "token")
+	for name, payload := range map[string]string{
+		"other shape":     `{"data": [{"vehicle_id": "v1", "hours": 3}]}`,
+		"wrong types":     `{"vehicles": [{"id": 1, "drive_hours": "3"}]}`,
+		"not json object": `[1, 2, 3]`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			stubUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(payload))
+			})
+			c, rec := testContext("/api/neuron/vehicle-hours")
+			neuronVehicleHours(c)
+
+			var body map[string]interface{}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if rec.Code != http.StatusOK || body["raw"] == nil || body["warning"] == nil || body["vehicles"] != nil {
+				t.Errorf("status %d: %s; want raw with a warning", rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+// Without a "vehicles" field there are no drive hours to report; charting zeros would look like an idle fleet.
+func TestNeuronDriveHoursWeeklyRejectsOtherShape(t *testing.T) {
+	t.Setenv("NEURON_API_TOKEN", "token")
+	stubUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data": [{"vehicle_id": "v1", "hours": 3}]}`))
+	})
+	c, rec := testContext("/api/neuron/drive-hours-weekly")
+	neuronDriveHoursWeekly(c)
+
+	if rec.Code != http.StatusBadGateway || !strings.Contains(rec.Body.String(), `no \"vehicles\" field`) {
+		t.Errorf("status %d: %s; want 502 for a response without vehicles", rec.Code, rec.Body.String())
+	}
+}
+
+func TestNeuronVehicleFaults(t *testing.T) {
+	t.Setenv("NEURON_API_TOKEN", "token")
+	la, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Skip(err)
+	}
+	withBucketLocation(t, la)
+	pinNow(t, time.Date(2024, 5, 15, 3, 0, 0, 0, time.UTC)) // 2024-05-14 20:00 in Los Angeles
+
+	var query url.Values
+	payload, status := "", http.StatusOK
+	stubUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		w.WriteHeader(status)
+		w.Write([]byte(payload))
+	})
+	faults := func(target string) (int, map[string]interface{}) {
+		c, rec := testContext(target)
+		neuronVehicleFaults(c)
+		var body map[string]interface{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		return rec.Code, body
+	}
+
+	payload = `{"events": [{"vehicle": "ROG-01", "code": "P0A80", "timestamp": "2024-05-13T02:00:00Z"}]}`
+	code, body := faults("/api/neuron/vehicle-faults")
+	if code != http.StatusOK || query.Get("end_date") != "2024-05-14" {
+		t.Fatalf("status %d, end_date %q: %v; want 200 and today in the bucket zone", code, query.Get("end_date"), body)
+	}
+	// Monday 02:00 UTC is still Sunday in Los Angeles
+	if weeks := fmt.Sprint(body["weeks"]); weeks != "[2024-W19]" {
+		t.Errorf("weeks = %s, want [2024-W19]", weeks)
+	}
+
+	_, body = faults("/api/neuron/vehicle-faults?start_date=2024-05-01&end_date=2024-05-10")
+	if got := body["meta"].(map[string]interface{})["date_range"]; got != "2024-05-01 to 2024-05-10" || query.Get("start_date") != "2024-05-01" {
+		t.Errorf("date_range = %v, start_date sent %q", got, query.Get("start_date"))
+	}
+
+	// An object without events or faults isn't a fault-free fleet
+	payload = `{"data": [{"vehicle": "ROG-01"}]}`
+	if code, body := faults("/api/neuron/vehicle-faults"); code != http.StatusBadGateway || !strings.Contains(fmt.Sprint(body["error"]), `no "events" or "faults"`) {
+		t.Errorf("status %d: %v; want 502 for a response without events", code, body)
+	}
+
+	payload, status = `not found`, http.StatusNotFound
+	if code, body := faults("/api/neuron/vehicle-faults"); code != http.StatusNotFound || body["detail"] != "not found" {
+		t.Errorf("status %d: %v; want Neuron's 404 passed through", code, body)
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"sync"
+)
+
+// runBounded runs tasks with at most maxConcurrency in flight and waits for every task it started. A failing
+// task doesn't stop the others (the KPI handlers answer with partial data); the first error is returned. Once
+// ctx is done no further tasks start, and ctx's error is returned unless a task failed first.
+func runBounded(ctx context.Context, tasks []func() error, maxConcurrency int) error {
+	if maxConcurrency < 1 {
+		maxConcurrency = 1
+	}
+	sem := make(chan struct{}, maxConcurrency)
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+	record := func(err error) { once.Do(func() { firstErr = err }) }
+
+	for _, task := range tasks {
+		if ctx.Err() != nil {
+			break
+		}
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func(task func() error) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if err := task(); err != nil {
+				record(err)
+			}
+		}(task)
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		record(err)
+	}
+	return firstErr
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Vehicle programs split time-in-build (and the WIP/data-quality views) into series. Epics are matched against
+// the programs in order; the first match wins and anything unmatched falls into programOther. The list comes
+// from VEHICLE_PROGRAMS (inline JSON) or VEHICLE_PROGRAMS_FILE (path to JSON), e.g.
+//
+//	[{"name":"Rogue","match":"substring","pattern":"ROG"},
+//	 {"name":"D-Max","match":"regex","pattern":"(?i)D-?MAX|DMX-"}]
+//
+// and defaults to Rogue and MachE, so the response keeps its rogue/machE/other series.
+const (
+	programRogue = "Rogue"
+	programMachE = "MachE"
+	programOther = "Other" // implicit catch-all; not configurable
+
+	programMatchSubstring = "substring" // case-insensitive substring of the summary
+	programMatchRegex     = "regex"     // Go regexp against the summary; add (?i) for case-insensitive
+)
+
+type vehicleProgram struct {
+	Name    string   `json:"name"`
+	Match   string   `json:"match"`
+	Pattern string   `json:"pattern"`
+	Exclude []string `json:"exclude,omitempty"` // case-insensitive summary substrings that veto a match
+	Label   string   `json:"label"`             // vehicle:<label> epic label that forces this program
+
+	re *regexp.Regexp
+}
+
+var defaultVehiclePrograms = []vehicleProgram{
+	{Name: programRogue, Match: programMatchSubstring, Pattern: "ROG", Label: "rogue"},
+	// D-Max / DMAX / DMX are excluded so they are not counted as MachE
+	{Name: programMachE, Match: programMatchSubstring, Pattern: "MCE", Exclude: []string{"D-MAX", "DMAX", "DMX-"}, Label: "mache"},
+}
+
+var vehiclePrograms = compileVehiclePrograms(defaultVehiclePrograms)
+
+// legacyProgramKeys are the response keys the Rogue/MachE/Other series had before programs were configurable;
+// they are still emitted for those program names so existing charts keep working.
+var legacyProgramKeys = map[string]struct{ series, labels, n string }{
+	programRogue: {"rogue", "week_labels_rogue", "rogue_n"},
+	programMachE: {"machE", "week_labels_mach_e", "machE_n"},
+	programOther: {"other", "week_labels_other", "other_n"},
+}
+
+// loadVehiclePrograms reads the program list from env, falling back to defaultVehiclePrograms when unset or invalid.
+func loadVehiclePrograms() []vehicleProgram {
+	raw, source := strings.TrimSpace(os.Getenv("VEHICLE_PROGRAMS")), "VEHICLE_PROGRAMS"
+	if raw == "" {
+		path := strings.TrimSpace(os.Getenv("VEHICLE_PROGRAMS_FILE"))
+		if path == "" {
+			return compileVehiclePrograms(defaultVehiclePrograms)
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			log.Printf("[Config] Ignoring VEHICLE_PROGRAMS_FILE: %v; using the default programs", err)
+			return compileVehiclePrograms(defaultVehiclePrograms)
+		}
+		raw, source = string(b), "VEHICLE_PROGRAMS_FILE"
+	}
+	programs, err := parseVehiclePrograms(raw)
+	if err != nil {
+		log.Printf("[Config] Ignoring %s: %v; using the default programs", source, err)
+		return compileVehiclePrograms(defaultVehiclePrograms)
+	}
+	return programs
+}
+
+// parseVehiclePrograms decodes and validates a JSON program list.
+func parseVehiclePrograms(raw string) ([]vehicleProgram, error) {
+	var programs []vehicleProgram
+	if err := json.Unmarshal([]byte(raw), &programs); err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	for i := range programs {
+		p := &programs[i]
+		p.Name = strings.TrimSpace(p.Name)
+		switch {
+		case p.Name == "":
+			return nil, fmt.Errorf("program %d has no name", i)
+		case strings.EqualFold(p.Name, programOther):
+			return nil, fmt.Errorf("program %q is reserved for unmatched epics", p.Name)
+		case seen[strings.ToLower(p.Name)]:
+			return nil, fmt.Errorf("duplicate program %q", p.Name)
+		case p.Pattern == "":
+			return nil, fmt.Errorf("program %q has no pattern", p.Name)
+		}
+		seen[strings.ToLower(p.Name)] = true
+		if p.Match == "" {
+			p.Match = programMatchSubstring
+		}
+		switch p.Match {
+		case programMatchSubstring:
+		case programMatchRegex:
+			re, err := regexp.Compile(p.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("program %q: %v", p.Name, err)
+			}
+			p.re = re
+		default:
+			return nil, fmt.Errorf("program %q: invalid match %q (want substring, regex)", p.Name, p.Match)
+		}
+		if p.Label == "" {
+			p.Label = strings.ToLower(p.Name)
+		}
+	}
+	return programs, nil
+}
+
+// compileVehiclePrograms validates a built-in list; it panics on error since defaults are fixed at compile time.
+func compileVehiclePrograms(programs []vehicleProgram) []vehicleProgram {
+	b, _ := json.Marshal(programs)
+	out, err := parseVehiclePrograms(string(b))
+	if err != nil {
+		panic(err)
+	}
+	return out
+}
+
+// vehicleProgramNames lists the configured programs in match order, followed by programOther.
+func vehicleProgramNames() []string {
+	names := make([]string, 0, len(vehiclePrograms)+1)
+	for _, p := range vehiclePrograms {
+		names = append(names, p.Name)
+	}
+	return append(names, programOther)
+}
+
+// programSeriesKey is the response key for a program's series: the legacy key for Rogue/MachE/Other, else its name.
+func programSeriesKey(name string) string {
+	if k, ok := legacyProgramKeys[name]; ok {
+		return k.series
+	}
+	return name
+}
+
+// matches reports whether summary belongs to the program by its pattern and exclusions.
+func (p vehicleProgram) matches(summary string) bool {
+	upper := strings.ToUpper(summary)
+	for _, ex := range p.Exclude {
+		if strings.Contains(upper, strings.ToUpper(ex)) {
+			return false
+		}
+	}
+	if p.re != nil {
+		return p.re.MatchString(summary)
+	}
+	return strings.Contains(upper, strings.ToUpper(p.Pattern))
+}
+
+// vehicleAliases maps alternate vehicle names to the name extractVehicleName gives the epic, both lowercased,
+// so ?vehicle= on time-in-build can use either. Set from VEHICLE_ALIASES, a JSON object such as
+// {"Rogue 131": "ROG-131", "ROG-131B": "ROG-131"}; empty by default.
+var vehicleAliases = map[string]string{}
+
+// loadVehicleAliases reads VEHICLE_ALIASES, falling back to no aliases when unset or invalid.
+func loadVehicleAliases() map[string]string {
+	raw := strings.TrimSpace(os.Getenv("VEHICLE_ALIASES"))
+	if raw == "" {
+		return map[string]string{}
+	}
+	aliases, err := parseVehicleAliases(raw)
+	if err != nil {
+		log.Printf("[Config] Ignoring VEHICLE_ALIASES=%q: %v", raw, err)
+		return map[string]string{}
+	}
+	return aliases
+}
+
+// parseVehicleAliases decodes a JSON alias -> vehicle name object, keyed and valued in lowercase.
+func parseVehicleAliases(raw string) (map[string]string, error) {
+	var m map[string]string
+	if err := json.Unmarshal([]byte(raw), &m); err != nil {
+		return nil, err
+	}
+	aliases := make(map[string]string, len(m))
+	for alias, name := range m {
+		alias, name = strings.ToLower(strings.TrimSpace(alias)), strings.ToLower(strings.TrimSpace(name))
+		if alias == "" || name == "" {
+			return nil, fmt.Errorf("alias %q -> %q: both sides must be non-empty", alias, name)
+		}
+		aliases[alias] = name
+	}
+	return aliases, nil
+}
+
+// canonicalVehicleName lowercases name and resolves it through vehicleAliases.
+func canonicalVehicleName(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if canonical, ok := vehicleAliases[name]; ok {
+		return canonical
+	}
+	return name
+}
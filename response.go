@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// upstreamError is a non-2xx response from JIRA, BuildKite, or Fleetio. It keeps the status the
+// service actually returned so handlers can report it even when they answer the client with 502/503.
+type upstreamError struct {
+	Status int
+	msg    string
+	body   []byte // raw response body, when the caller kept it (see jiraErrorMessages)
+}
+
+func (e *upstreamError) Error() string { return e.msg }
+
+// newUpstreamError formats an error message like fmt.Errorf and records the upstream HTTP status.
+func newUpstreamError(status int, format string, args ...interface{}) error {
+	return &upstreamError{Status: status, msg: fmt.Sprintf(format, args...)}
+}
+
+// newUpstreamErrorWithBody is newUpstreamError that also keeps the response body for later inspection.
+func newUpstreamErrorWithBody(status int, body []byte, format string, args ...interface{}) error {
+	return &upstreamError{Status: status, msg: fmt.Sprintf(format, args...), body: body}
+}
+
+// jiraErrorMessages extracts JIRA's errorMessages (and field errors) from an upstream error's kept body.
+func jiraErrorMessages(err error) []string {
+	var ue *upstreamError
+	if !errors.As(err, &ue) || len(ue.body) == 0 {
+		return nil
+	}
+	var parsed struct {
+		ErrorMessages []string          `json:"errorMessages"`
+		Errors        map[string]string `json:"errors"`
+	}
+	if json.Unmarshal(ue.body, &parsed) != nil {
+		return nil
+	}
+	msgs := parsed.ErrorMessages
+	for field, msg := range parsed.Errors {
+		msgs = append(msgs, field+": "+msg)
+	}
+	return msgs
+}
+
+// upstreamStatus returns the upstream HTTP status behind err, or nil when the request never got a
+// response (network error, timeout, bad JSON) so the field serializes as null.
+func upstreamStatus(err error) interface{} {
+	var ue *upstreamError
+	if errors.As(err, &ue) {
+		return ue.Status
+	}
+	return nil
+}
+
+// upstreamErrorBody is the JSON body for a failed upstream call: prefix + err, plus upstream_status.
+func upstreamErrorBody(prefix string, err error) gin.H {
+	body := gin.H{
+		"error":           prefix + err.Error(),
+		"upstream_status": upstreamStatus(err),
+	}
+	if isUpstreamTimeout(err) {
+		body["timeout"] = true
+		body["hint"] = fmt.Sprintf("upstream did not respond within %v (HTTP_CLIENT_TIMEOUT_SEC)", httpClient.Timeout)
+	}
+	return body
+}
+
+// isUpstreamTimeout reports whether err is an outbound call running out of time (client timeout or deadline).
+func isUpstreamTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// upstreamFailureStatus is the status to answer a failed upstream call with: 504 on timeout, otherwise 502.
+func upstreamFailureStatus(err error) int {
+	if isUpstreamTimeout(err) {
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusBadGateway
+}
+
+// Data provenance reported as meta.source, so consumers can tell real numbers from stand-ins.
+const (
+	sourceLive        = "live"        // fetched from JIRA/BuildKite for this request
+	sourceCache       = "cache"       // served from the in-memory cache
+	sourceStaleCache  = "stale_cache" // served from the cache past its TTL because a refresh failed
+	sourceNeuron      = "neuron"      // fetched from Neuron
+	sourceLakehouse   = "lakehouse"   // fetched from the lakehouse query service
+	sourcePlaceholder = "placeholder" // fixed values standing in for a missing integration
+	sourceMock        = "mock"        // generated sample data
+)
+
+// cacheSource is meta.source for data that may have come from a cache: sourceStaleCache when it was served
+// because a refresh failed, sourceCache for a cache hit, otherwise sourceLive.
+func cacheSource(fromCache, stale bool) string {
+	switch {
+	case stale:
+		return sourceStaleCache
+	case fromCache:
+		return sourceCache
+	}
+	return sourceLive
+}
+
+// wantsCSV reports whether the client asked for CSV, via ?format=csv or an Accept: text/csv header.
+func wantsCSV(c *gin.Context) bool {
+	if format := c.Query("format"); format != "" {
+		return strings.EqualFold(format, "csv")
+	}
+	return strings.Contains(strings.ToLower(c.GetHeader("Accept")), "text/csv")
+}
+
+// writeCSV streams header and records as a CSV attachment. The header row is written even with no records.
+func writeCSV(c *gin.Context, filename string, header []string, records [][]string) {
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", "attachment; filename="+filename)
+	c.Status(http.StatusOK)
+	w := csv.NewWriter(c.Writer)
+	_ = w.Write(header)
+	if err := w.WriteAll(records); err != nil { // WriteAll flushes; write errors are sticky, so this covers the header too
+		logf(c.Request.Context(), "CSV", "Failed writing %s: %v", filename, err)
+	}
+}
+
+// jsonWithETag writes a 200 JSON response with a weak ETag and answers 304 when If-None-Match already has it,
+// so polling dashboards skip identical payloads. The tag hashes the body minus the named meta keys, which
+// change on every request (cache age, fetch timing) without the data changing; a dotted key such as
+// "overlay.cache_age_sec" names a key in a nested meta object. A 304 leaves the client's copy of those stale.
+func jsonWithETag(c *gin.Context, body gin.H, volatileMeta ...string) {
+	hashed := body
+	if meta, ok := body["meta"].(gin.H); ok && len(volatileMeta) > 0 {
+		for _, key := range volatileMeta {
+			meta = withoutKey(meta, strings.Split(key, "."))
+		}
+		hashed = make(gin.H, len(body))
+		for k, v := range body {
+			hashed[k] = v
+		}
+		hashed["meta"] = meta
+	}
+	raw, err := json.Marshal(hashed)
+	if err != nil {
+		c.JSON(http.StatusOK, body)
+		return
+	}
+	sum := sha256.Sum256(raw)
+	etag := `W/"` + hex.EncodeToString(sum[:16]) + `"`
+	c.Header("ETag", etag)
+	if etagMatches(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.JSON(http.StatusOK, body)
+}
+
+// withoutKey returns m without the key at path, copying each map it changes so the response body is untouched.
+func withoutKey(m gin.H, path []string) gin.H {
+	v, ok := m[path[0]]
+	if !ok {
+		return m
+	}
+	nested, isMap := v.(gin.H)
+	if len(path) > 1 && !isMap {
+		return m
+	}
+	out := make(gin.H, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	if len(path) == 1 {
+		delete(out, path[0])
+	} else {
+		out[path[0]] = withoutKey(nested, path[1:])
+	}
+	return out
+}
+
+// etagMatches reports whether an If-None-Match header lists etag (weak comparison) or is "*".
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
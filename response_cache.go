@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Response caches for the per-week JIRA KPIs, which re-run dozens of JIRA queries per load while the data
+// changes slowly. Each route has its own TTL (default 5 minutes; 0 disables) and is keyed by route plus query
+// string, so ?portfolio_parent=, ?group_by=, ?granularity= and ?now= get separate entries. Each holds at most
+// responseCacheMaxEntries, since any query string makes a new key.
+var (
+	vosResponseCache       = newBoundedTTLCache[string, cachedResponse](5*time.Minute, 0, responseCacheMaxEntries)
+	buildBugsResponseCache = newBoundedTTLCache[string, cachedResponse](5*time.Minute, 0, responseCacheMaxEntries)
+	mtbfResponseCache      = newBoundedTTLCache[string, cachedResponse](5*time.Minute, 0, responseCacheMaxEntries)
+)
+
+const responseCacheMaxEntries = 100
+
+// cachedResponse is a serialized 200 JSON body with meta.cached_at already set.
+type cachedResponse struct {
+	body       []byte
+	cachedAt   time.Time
+	incomplete string // why the body must not be cached (see incompleteReason); empty when it may be
+}
+
+// responseCacheKey is the route plus its query string without ?refresh= (url.Values.Encode sorts the keys).
+func responseCacheKey(c *gin.Context) string {
+	q := c.Request.URL.Query()
+	q.Del("refresh")
+	return c.FullPath() + "?" + q.Encode()
+}
+
+// responseCacheMiddleware serves a cached body (X-Cache: HIT) while it is fresh, otherwise runs the handler
+// (X-Cache: MISS) and caches its 200 response unless it is incomplete (see incompleteReason): a transient JIRA
+// or Neuron failure shouldn't be served for the whole TTL. ?refresh=1 skips the lookup but still stores the
+// fresh result.
+func responseCacheMiddleware(cache *ttlCache[string, cachedResponse]) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if cache.ttl == 0 {
+			c.Next()
+			return
+		}
+		key := responseCacheKey(c)
+		refresh := c.Query("refresh") == "1" || c.Query("refresh") == "true"
+		if hit, ok := cache.Get(key); ok && !refresh {
+			c.Header("X-Cache", "HIT")
+			c.Header("Age", fmt.Sprintf("%d", int(time.Since(hit.cachedAt).Seconds())))
+			c.Data(http.StatusOK, "application/json; charset=utf-8", hit.body)
+			c.Abort()
+			return
+		}
+		c.Header("X-Cache", "MISS")
+		w := &bodyRecorder{ResponseWriter: c.Writer}
+		c.Writer = w
+		c.Next()
+		if w.Status() != http.StatusOK {
+			return
+		}
+		if entry, err := newCachedResponse(w.body.Bytes()); err != nil {
+			logf(c.Request.Context(), "ResponseCache", "Not caching %s: %v", key, err)
+		} else if entry.incomplete != "" {
+			logf(c.Request.Context(), "ResponseCache", "Not caching incomplete %s: %s", key, entry.incomplete)
+		} else {
+			cache.Set(key, entry)
+		}
+	}
+}
+
+// newCachedResponse stamps meta.cached_at into a JSON body and reports a live meta.source as the cache.
+func newCachedResponse(raw []byte) (cachedResponse, error) {
+	var body map[string]interface{}
+	if err := json.Unmarshal(raw, &body); err != nil {
+		return cachedResponse{}, err
+	}
+	now := time.Now()
+	meta, _ := body["meta"].(map[string]interface{})
+	incomplete := incompleteReason(meta)
+	if meta != nil {
+		meta["cached_at"] = now.UTC().Format(time.RFC3339)
+		if src, _ := meta["source"].(string); src == sourceLive {
+			meta["source"] = cacheSource(true, false) // what a hit serves
+		}
+	}
+	stamped, err := json.Marshal(body)
+	if err != nil {
+		return cachedResponse{}, err
+	}
+	return cachedResponse{body: stamped, cachedAt: now, incomplete: incomplete}, nil
+}
+
+// incompleteReason reports what in meta marks a response as missing data that a retry could fetch: failed
+// queries in the completeness breakdown, partial data, an errors list or any *_error (e.g. drive_hours_error).
+// Data that is incomplete for good, such as truncated weeks, doesn't count.
+func incompleteReason(meta map[string]interface{}) string {
+	if partial, _ := meta["partial"].(bool); partial {
+		return "meta.partial"
+	}
+	if errs, ok := meta["errors"]; ok && errs != nil {
+		if list, isList := errs.([]interface{}); !isList || len(list) > 0 {
+			return "meta.errors"
+		}
+	}
+	for _, k := range sortedKeys(meta) {
+		if strings.HasSuffix(k, "_error") {
+			return "meta." + k
+		}
+	}
+	breakdown, _ := meta["completeness_breakdown"].(map[string]interface{})
+	for _, k := range sortedKeys(breakdown) {
+		s, _ := breakdown[k].(map[string]interface{})
+		got, _ := s["got"].(float64)
+		expected, _ := s["expected"].(float64)
+		if strings.HasSuffix(k, "_queries") && got < expected {
+			return fmt.Sprintf("meta.completeness_breakdown.%s: %v of %v", k, got, expected)
+		}
+	}
+	return ""
+}
+
+// bodyRecorder keeps a copy of everything the handler writes.
+type bodyRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *bodyRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *bodyRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
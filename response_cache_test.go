@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestResponseCacheSkipsIncompleteResponses(t *testing.T) {
+	tests := []struct {
+		name  string
+		meta  gin.H
+		cache bool
+	}{
+		{"complete", gin.H{"completeness": 100, "completeness_breakdown": gin.H{"week_queries": gin.H{"expected": 20, "got": 20}}}, true},
+		{"truncated weeks only", gin.H{"truncated": true, "truncated_weeks": []string{"2024-W20"}}, true},
+		{"failed week queries", gin.H{"completeness": 95, "completeness_breakdown": gin.H{"week_queries": gin.H{"expected": 20, "got": 19}}}, false},
+		{"drive hours error", gin.H{"drive_hours_error": "Neuron returned 503"}, false},
+		{"partial", gin.H{"partial": true}, false},
+		{"errors", gin.H{"errors": []string{"week 2024-W20: timeout"}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handlerCalls := 0
+			r := gin.New()
+			r.GET("/kpi", responseCacheMiddleware(newTTLCache[string, cachedResponse](time.Minute, 0)), func(c *gin.Context) {
+				handlerCalls++
+				tt.meta["source"] = sourceLive
+				c.JSON(http.StatusOK, gin.H{"weeks": []string{"2024-W20"}, "meta": tt.meta})
+			})
+			var xCache, body string
+			for i := 0; i < 2; i++ {
+				rec := httptest.NewRecorder()
+				r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/kpi", nil))
+				xCache, body = rec.Header().Get("X-Cache"), rec.Body.String()
+			}
+			if cached := xCache == "HIT"; cached && !strings.Contains(body, `"source":"cache"`) {
+				t.Errorf("cache hit body %s, want meta.source cache", body)
+			}
+			if cached := xCache == "HIT"; cached != tt.cache || handlerCalls != map[bool]int{true: 1, false: 2}[tt.cache] {
+				t.Errorf("second request X-Cache %s after %d handler calls, want cached = %v", xCache, handlerCalls, tt.cache)
+			}
+		})
+	}
+}
+
+func TestBoundedTTLCacheEvictsOldest(t *testing.T) {
+	cache := newBoundedTTLCache[string, int](time.Minute, 0, 3)
+	for i := 0; i < 10; i++ {
+		cache.Set(fmt.Sprintf("?portfolio_parent=P-%d", i), i)
+		time.Sleep(time.Millisecond) // distinct storedAt, so "oldest" is well defined
+	}
+	if n := len(cache.entries); n != 3 {
+		t.Fatalf("entries = %d, want the limit of 3", n)
+	}
+	for i := 7; i < 10; i++ {
+		if v, ok := cache.Get(fmt.Sprintf("?portfolio_parent=P-%d", i)); !ok || v != i {
+			t.Errorf("newest entry %d = %v, %v; want kept", i, v, ok)
+		}
+	}
+	// Re-setting an existing key replaces it rather than evicting another
+	cache.Set("?portfolio_parent=P-9", 90)
+	if _, ok := cache.Get("?portfolio_parent=P-7"); !ok || len(cache.entries) != 3 {
+		t.Errorf("updating a key evicted another entry")
+	}
+}
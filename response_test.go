@@ -0,0 +1,34 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestJSONWithETagIgnoresNestedVolatileMeta(t *testing.T) {
+	respond := func(cacheAge int, deploys int, ifNoneMatch string) (*http.Response, gin.H) {
+		body := gin.H{
+			"deploys": []int{deploys},
+			"meta":    gin.H{"retry_budget": cacheAge, "overlay": gin.H{"cache_age_sec": cacheAge, "org": "org"}},
+		}
+		c, rec := testContext("/api/kpi/time-in-build?overlay=deploys")
+		c.Request.Header.Set("If-None-Match", ifNoneMatch)
+		jsonWithETag(c, body, "retry_budget", "overlay.cache_age_sec")
+		c.Writer.WriteHeaderNow() // gin does this after the handler chain
+		return rec.Result(), body
+	}
+
+	first, body := respond(5, 1, "")
+	if overlay := body["meta"].(gin.H)["overlay"].(gin.H); overlay["cache_age_sec"] != 5 {
+		t.Errorf("overlay = %v, want the response body left intact", overlay)
+	}
+	etag := first.Header.Get("ETag")
+	if again, _ := respond(65, 1, etag); again.StatusCode != http.StatusNotModified {
+		t.Errorf("only the overlay's cache age changed: status %d, want 304", again.StatusCode)
+	}
+	if changed, _ := respond(65, 2, etag); changed.StatusCode != http.StatusOK {
+		t.Errorf("the data changed: status %d, want 200", changed.StatusCode)
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// requestValues is the per-request store for values a handler's goroutines share (JIRA warnings, the
+// retry budget). It rides on the request's context.Context so upstream helpers don't need *gin.Context.
+type requestValues struct {
+	mu sync.Mutex
+	m  map[string]interface{}
+}
+
+type requestValuesCtxKey struct{}
+
+// withRequestValues returns ctx carrying an empty request-scoped store.
+func withRequestValues(ctx context.Context) context.Context {
+	return context.WithValue(ctx, requestValuesCtxKey{}, &requestValues{m: make(map[string]interface{})})
+}
+
+// requestValuesMiddleware gives every API request its own store.
+func requestValuesMiddleware(c *gin.Context) {
+	c.Request = c.Request.WithContext(withRequestValues(c.Request.Context()))
+	c.Next()
+}
+
+// requestValue returns the *T stored under key on ctx's request, creating it with init on first use.
+// Without a store (e.g. a plain context.Background()) it returns a fresh, unshared value.
+func requestValue[T any](ctx context.Context, key string, init func() *T) *T {
+	store, ok := ctx.Value(requestValuesCtxKey{}).(*requestValues)
+	if !ok {
+		return init()
+	}
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if v, ok := store.m[key]; ok {
+		return v.(*T)
+	}
+	v := init()
+	store.m[key] = v
+	return v
+}
+
+// lookupRequestValue returns the *T stored under key on ctx's request, if any call created it.
+func lookupRequestValue[T any](ctx context.Context, key string) (*T, bool) {
+	store, ok := ctx.Value(requestValuesCtxKey{}).(*requestValues)
+	if !ok {
+		return nil, false
+	}
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	v, ok := store.m[key].(*T)
+	return v, ok
+}
+
+// retryBudgetKey is the request value key holding the request's *retryBudget.
+const retryBudgetKey = "retry_budget"
+
+// jiraRetryBudget is the total number of JIRA retries one dashboard request may spend across all its
+// sub-calls (JIRA_RETRY_BUDGET). Without it, per-week fan-out × per-call retries compounds during an incident.
+var jiraRetryBudget = 6
+
+// retryBudget counts retries taken by one request; goroutines share it.
+type retryBudget struct {
+	limit     int
+	used      atomic.Int32
+	exhausted atomic.Bool
+}
+
+func requestRetryBudget(ctx context.Context) *retryBudget {
+	return requestValue(ctx, retryBudgetKey, func() *retryBudget { return &retryBudget{limit: jiraRetryBudget} })
+}
+
+// take reserves one retry, or marks the budget exhausted and returns false.
+func (b *retryBudget) take() bool {
+	if int(b.used.Add(1)) > b.limit {
+		b.used.Add(-1)
+		b.exhausted.Store(true)
+		return false
+	}
+	return true
+}
+
+// retryableJIRAError reports whether err is worth retrying: rate limited (429) or a JIRA 5xx.
+func retryableJIRAError(err error) bool {
+	var ue *upstreamError
+	if !errors.As(err, &ue) {
+		return false
+	}
+	return ue.Status == http.StatusTooManyRequests || ue.Status >= 500
+}
+
+// retryJIRA runs call, retrying with backoff on 429/5xx while the request's retry budget lasts. Once the
+// budget is spent it returns the last error immediately so the handler can answer with partial data.
+func retryJIRA(ctx context.Context, call func() error) error {
+	budget := requestRetryBudget(ctx)
+	for attempt := 0; ; attempt++ {
+		err := call()
+		if err == nil || !retryableJIRAError(err) || attempt+1 >= vosSearchMaxRetries {
+			return err
+		}
+		if !budget.take() {
+			logf(ctx, "JIRA", "Retry budget exhausted (%d); not retrying: %v", budget.limit, err)
+			return err
+		}
+		backoff := time.Duration((attempt+1)*vosSearchBackoffSec) * time.Second
+		logf(ctx, "JIRA", "%v; retrying in %v (attempt %d/%d)", err, backoff, attempt+2, vosSearchMaxRetries)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// jiraWeekQueryCap bounds how many issues one per-week query pages through; past it the week is reported truncated.
+const jiraWeekQueryCap = 1000
+
+// searchAllJQLWithRetry pages through jql by startAt until JIRA's total is reached (or a short page), up to
+// limit issues, retrying each page with retryJIRA's backoff. truncated means more issues matched than were fetched.
+func searchAllJQLWithRetry(ctx context.Context, baseURL, email, token, jql string, fields []string, limit int) (issues []map[string]interface{}, truncated bool, err error) {
+	for startAt := 0; ; {
+		var page []map[string]interface{}
+		var total *int
+		err := retryJIRA(ctx, func() error {
+			var err error
+			page, total, err = searchJQLWithTotal(ctx, baseURL, email, token, jql, fields, vosTicketsMaxResults, startAt, "")
+			return err
+		})
+		if err != nil {
+			return nil, false, err
+		}
+		issues = append(issues, page...)
+		startAt += len(page)
+		if len(page) < vosTicketsMaxResults || (total != nil && startAt >= *total) {
+			return issues, false, nil
+		}
+		if len(issues) >= limit {
+			return issues, true, nil
+		}
+	}
+}
+
+// BuildKite requests are retried on 429/5xx up to buildkiteMaxAttempts times in total (BUILDKITE_MAX_ATTEMPTS),
+// backing off exponentially from buildkiteBackoffBase with jitter, or for as long as Retry-After asks.
+var buildkiteMaxAttempts = 4
+
+const (
+	buildkiteBackoffBase = time.Second
+	buildkiteBackoffMax  = 30 * time.Second
+	// buildkiteRetryAfterMax caps a server-requested Retry-After so one request can't stall a dashboard load.
+	buildkiteRetryAfterMax = time.Minute
+)
+
+// backoffWithJitter is base·2^attempt capped at limit, randomized to between half and all of that so
+// concurrent page fetches that failed together don't retry in lockstep.
+func backoffWithJitter(attempt int, base, limit time.Duration) time.Duration {
+	d := limit
+	if attempt < 30 && base<<attempt < limit {
+		d = base << attempt
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// retryAfterDelay parses a Retry-After header (delay in seconds or an HTTP date).
+func retryAfterDelay(header string) (time.Duration, bool) {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(header); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(header); err == nil {
+		return max(time.Until(t), 0), true
+	}
+	return 0, false
+}
+
+// addRetryBudget records retry budget usage in meta when any JIRA call in this request retried.
+func addRetryBudget(c *gin.Context, meta gin.H) {
+	b, ok := lookupRequestValue[retryBudget](c.Request.Context(), retryBudgetKey)
+	if !ok {
+		return
+	}
+	meta["retry_budget"] = gin.H{
+		"limit":     b.limit,
+		"used":      int(b.used.Load()),
+		"exhausted": b.exhausted.Load(),
+	}
+	if b.exhausted.Load() {
+		meta["retry_warning"] = "retry budget exhausted; some weeks may be missing (see completeness)"
+	}
+}
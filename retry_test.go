@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestFetchBuildkitePageRetriesAfter429(t *testing.T) {
+	var n atomic.Int32
+	calls := stubUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		if n.Add(1) == 1 {
+			w.Header().Set("Retry-After", "2") // longer than any first backoff, so honoring it is measurable
+			http.Error(w, "rate limited", http.StatusTooManyRequests)
+			return
+		}
+		fmt.Fprint(w, `[{"number": 7, "state": "passed"}]`)
+	})
+
+	start := time.Now()
+	page, err := fetchBuildkitePage(context.Background(), "token", buildkiteBuildsPageURL("org", "pipe", time.Now(), 1))
+	if err != nil {
+		t.Fatalf("fetchBuildkitePage: %v", err)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("calls = %d, want 2 (429 then 200)", got)
+	}
+	if len(page.Builds) != 1 || page.Builds[0].Number != 7 {
+		t.Errorf("builds = %+v, want build 7", page.Builds)
+	}
+	if elapsed := time.Since(start); elapsed < 2*time.Second {
+		t.Errorf("retried after %v, want at least the 2s Retry-After", elapsed)
+	}
+}
+
+func TestFetchBuildkitePageGivesUp(t *testing.T) {
+	setForTest(t, &buildkiteMaxAttempts, 2)
+	calls := stubUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	})
+
+	_, err := fetchBuildkitePage(context.Background(), "token", buildkiteBuildsPageURL("org", "pipe", time.Now(), 1))
+	var ue *upstreamError
+	if !errors.As(err, &ue) || ue.Status != http.StatusServiceUnavailable {
+		t.Fatalf("err = %v, want the 503 upstreamError", err)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("calls = %d, want buildkiteMaxAttempts (2)", got)
+	}
+}
+
+func TestFetchBuildkitePageDoesNotRetry4xx(t *testing.T) {
+	calls := stubUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "not found", http.StatusNotFound)
+	})
+
+	if _, err := fetchBuildkitePage(context.Background(), "token", buildkiteBuildsPageURL("org", "pipe", time.Now(), 1)); err == nil {
+		t.Fatal("want an error for 404")
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("calls = %d, want 1", got)
+	}
+}
+
+func TestFetchBuildsFromPipelineMarksPartialWhenRetriesRunOut(t *testing.T) {
+	setForTest(t, &buildkiteMaxAttempts, 1)
+	stubUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("page") {
+		case "1":
+			w.Header().Set("Link", `<https://api.buildkite.com/v2/x?page=2>; rel="next", <https://api.buildkite.com/v2/x?page=3>; rel="last"`)
+			fmt.Fprint(w, `[{"number": 3}]`)
+		case "2":
+			http.Error(w, "rate limited", http.StatusTooManyRequests)
+		default:
+			fmt.Fprint(w, `[{"number": 1}]`)
+		}
+	})
+
+	ctx := withRequestValues(context.Background())
+	builds, truncated, err := fetchBuildsFromPipeline(ctx, "token", "org", "pipe", time.Now().AddDate(0, -1, 0))
+	if err != nil {
+		t.Fatalf("fetchBuildsFromPipeline: %v", err)
+	}
+	if len(builds) != 2 || truncated {
+		t.Errorf("got %d builds (truncated %v), want pages 1 and 3", len(builds), truncated)
+	}
+
+	c, _ := testContext("/api/kpi/buildkite-combined-all")
+	c.Request = c.Request.WithContext(ctx)
+	meta := gin.H{}
+	addBuildkitePartial(c, meta)
+	if meta["partial"] != true {
+		t.Fatalf("meta = %v, want partial", meta)
+	}
+	if errs, _ := meta["partial_errors"].([]string); len(errs) != 1 || !strings.Contains(errs[0], "org/pipe page 2") {
+		t.Errorf("partial_errors = %v, want page 2", meta["partial_errors"])
+	}
+}
+
+func TestRetryAfterDelay(t *testing.T) {
+	if d, ok := retryAfterDelay("3"); !ok || d != 3*time.Second {
+		t.Errorf("retryAfterDelay(3) = %v, %v", d, ok)
+	}
+	if _, ok := retryAfterDelay(""); ok {
+		t.Error("empty Retry-After parsed")
+	}
+}
+
+func TestBuildkiteJobsAndAnnotationsRetry5xx(t *testing.T) {
+	setForTest(t, &buildkiteJobCache, newBoundedTTLCache[string, []BuildkiteJob](time.Hour, 0, buildkiteJobCacheMaxEntries))
+	var n atomic.Int32
+	calls := stubUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		if n.Add(1)%2 == 1 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		if strings.HasSuffix(r.URL.Path, "/annotations") {
+			fmt.Fprint(w, `[{"context": "lint", "style": "error"}]`)
+			return
+		}
+		fmt.Fprint(w, `{"finished_at": "2024-05-01T00:00:00Z", "jobs": [{"id": "j1"}]}`)
+	})
+
+	ctx := context.Background()
+	jobs, _, err := fetchBuildJobs(ctx, "token", "org", "pipe", 1)
+	if err != nil || len(jobs) != 1 {
+		t.Fatalf("fetchBuildJobs = %+v, %v; want the job after a retry", jobs, err)
+	}
+	annotations, err := fetchBuildAnnotations(ctx, "token", "org", "pipe", 1)
+	if err != nil || len(annotations) != 1 {
+		t.Fatalf("fetchBuildAnnotations = %+v, %v; want the annotation after a retry", annotations, err)
+	}
+	if got := calls.Load(); got != 4 {
+		t.Errorf("calls = %d, want 4 (a 503 then a 200 for each)", got)
+	}
+}
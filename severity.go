@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Build-bugs severity score: with ?severity=true each week's created bugs are weighted by JIRA priority and
+// summed, so five blockers outweigh five trivial bugs. Weights come from BUILD_BUGS_PRIORITY_WEIGHTS
+// ("Highest=5,High=4,...", case-insensitive names); priorities not listed, and bugs without one, weigh
+// unknownPriorityWeight.
+const unknownPriorityWeight = 1.0
+
+var defaultPriorityWeights = map[string]float64{"highest": 5, "high": 4, "medium": 3, "low": 2, "lowest": 1}
+
+var priorityWeights = defaultPriorityWeights
+
+// parsePriorityWeights parses "Highest=5,High=4"; names are lowercased.
+func parsePriorityWeights(raw string) (map[string]float64, error) {
+	weights := make(map[string]float64)
+	for _, pair := range strings.Split(raw, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		name, val, found := strings.Cut(pair, "=")
+		name = strings.ToLower(strings.TrimSpace(name))
+		f, err := strconv.ParseFloat(strings.TrimSpace(val), 64)
+		if !found || name == "" || err != nil || f < 0 || math.IsInf(f, 0) || math.IsNaN(f) {
+			return nil, fmt.Errorf("invalid weight %q (want priority=non-negative number)", pair)
+		}
+		weights[name] = f
+	}
+	if len(weights) == 0 {
+		return nil, fmt.Errorf("no weights")
+	}
+	return weights, nil
+}
+
+// loadPriorityWeights reads BUILD_BUGS_PRIORITY_WEIGHTS, falling back to the defaults when unset or invalid.
+func loadPriorityWeights() map[string]float64 {
+	raw := strings.TrimSpace(os.Getenv("BUILD_BUGS_PRIORITY_WEIGHTS"))
+	if raw == "" {
+		return defaultPriorityWeights
+	}
+	w, err := parsePriorityWeights(raw)
+	if err != nil {
+		log.Printf("[Config] Ignoring BUILD_BUGS_PRIORITY_WEIGHTS=%q: %v", raw, err)
+		return defaultPriorityWeights
+	}
+	return w
+}
+
+// severityScores sums priority weights per week from issues grouped by priority name (groupByPriority).
+// Unknown priorities weigh unknownPriorityWeight and are logged and returned.
+func severityScores(ctx context.Context, weeks []string, byPriority groupedSeries) (scores []float64, unknown []string) {
+	scores, unknown = make([]float64, len(weeks)), []string{}
+	for priority, byWeek := range byPriority {
+		weight, ok := priorityWeights[strings.ToLower(priority)]
+		if !ok {
+			weight = unknownPriorityWeight
+			unknown = append(unknown, priority)
+			logf(ctx, "BuildBugs", "Priority %q has no severity weight; counting it as %v", priority, unknownPriorityWeight)
+		}
+		for i, w := range weeks {
+			scores[i] += weight * float64(len(byWeek[w]))
+		}
+	}
+	sort.Strings(unknown)
+	return scores, unknown
+}
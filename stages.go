@@ -0,0 +1,151 @@
+package main
+
+import (
+	"log"
+	"math"
+	"os"
+	"strings"
+	"time"
+)
+
+// Normalized lifecycle stages. Projects name their statuses differently ("In Development", "Building",
+// "In Progress"); mapping them to these stages makes time-in-stage comparable across programs.
+const (
+	stageBacklog    = "Backlog"
+	stageInProgress = "InProgress"
+	stageReview     = "Review"
+	stageDone       = "Done"
+	stageUnknown    = "Unknown"
+)
+
+var lifecycleStages = []string{stageBacklog, stageInProgress, stageReview, stageDone}
+
+// defaultStatusStages maps lowercase JIRA status names to stages. The InProgress and Done entries match the
+// status names the time-in-build calculations always used, so defaults don't change existing numbers.
+var defaultStatusStages = map[string]string{
+	"backlog":                  stageBacklog,
+	"to do":                    stageBacklog,
+	"open":                     stageBacklog,
+	"new":                      stageBacklog,
+	"selected for development": stageBacklog,
+	"in progress":              stageInProgress,
+	"in review":                stageReview,
+	"code review":              stageReview,
+	"review":                   stageReview,
+	"done":                     stageDone,
+	"closed":                   stageDone,
+	"complete":                 stageDone,
+	"resolved":                 stageDone,
+}
+
+// statusStages is the effective mapping: defaults plus JIRA_STATUS_STAGES overrides, read once at startup.
+var statusStages = defaultStatusStages
+
+// loadStatusStages applies JIRA_STATUS_STAGES on top of the defaults.
+// Format: semicolon-separated Status=Stage pairs, e.g. "In Development=InProgress;QA=Review;Released=Done".
+func loadStatusStages() map[string]string {
+	m := make(map[string]string, len(defaultStatusStages))
+	for k, v := range defaultStatusStages {
+		m[k] = v
+	}
+	raw := strings.TrimSpace(os.Getenv("JIRA_STATUS_STAGES"))
+	if raw == "" {
+		return m
+	}
+	for _, pair := range strings.Split(raw, ";") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		status, stage, found := strings.Cut(pair, "=")
+		status = strings.ToLower(strings.TrimSpace(status))
+		stage = canonicalStage(stage)
+		if !found || status == "" || stage == "" {
+			log.Printf("[Config] Ignoring invalid JIRA_STATUS_STAGES entry %q (want Status=Backlog|InProgress|Review|Done)", pair)
+			continue
+		}
+		m[status] = stage
+	}
+	return m
+}
+
+// canonicalStage returns the stage constant matching s case-insensitively, or "" if s isn't a stage.
+func canonicalStage(s string) string {
+	s = strings.TrimSpace(s)
+	for _, stage := range lifecycleStages {
+		if strings.EqualFold(s, stage) {
+			return stage
+		}
+	}
+	return ""
+}
+
+// normalizeStage maps a raw JIRA status name to its lifecycle stage (Unknown when unmapped).
+func normalizeStage(status string) string {
+	if stage, ok := statusStages[strings.ToLower(strings.TrimSpace(status))]; ok {
+		return stage
+	}
+	return stageUnknown
+}
+
+// statusesForStage returns every status name mapped to stage, for changelog lookups.
+func statusesForStage(stage string) []string {
+	var names []string
+	for status, s := range statusStages {
+		if s == stage {
+			names = append(names, status)
+		}
+	}
+	return names
+}
+
+// stageDaysFromChangelog returns days spent in each stage, walking status changes oldest-first from the
+// issue's creation. The current stage accrues until now, except Done, which has no meaningful end.
+func stageDaysFromChangelog(issue map[string]interface{}, now time.Time) map[string]float64 {
+	days := make(map[string]float64)
+	created, ok := getFieldTime(issue, "fields.created")
+	if !ok {
+		return days
+	}
+	changelog, _ := issue["changelog"].(map[string]interface{})
+	histories, _ := changelog["histories"].([]interface{})
+
+	since := created
+	current := ""
+	for _, raw := range histories {
+		h, _ := raw.(map[string]interface{})
+		if h == nil {
+			continue
+		}
+		createdStr, _ := h["created"].(string)
+		t, ok := parseTime(createdStr)
+		if !ok {
+			continue
+		}
+		items, _ := h["items"].([]interface{})
+		for _, it := range items {
+			item, _ := it.(map[string]interface{})
+			if item == nil || item["field"] != "status" {
+				continue
+			}
+			from, _ := item["fromString"].(string)
+			to, _ := item["toString"].(string)
+			if current == "" {
+				current = from
+			}
+			if t.After(since) {
+				days[normalizeStage(current)] += t.Sub(since).Hours() / 24
+			}
+			current, since = to, t
+		}
+	}
+	if current == "" {
+		current = getFieldString(issue, "fields.status.name")
+	}
+	if stage := normalizeStage(current); stage != stageDone && now.After(since) {
+		days[stage] += now.Sub(since).Hours() / 24
+	}
+	for stage, d := range days {
+		days[stage] = math.Round(d*10) / 10
+	}
+	return days
+}
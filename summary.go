@@ -0,0 +1,231 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// summaryJIRAMonths is the window the summary's JIRA counts query: enough for the latest week and a trend
+// against the one before, without the full chart's two or three months of per-week queries.
+const summaryJIRAMonths = 1
+
+// kpiSummarySection computes one KPI's latest value; an error becomes that section's "error".
+type kpiSummarySection struct {
+	name    string
+	compute func(c *gin.Context) (gin.H, error)
+}
+
+// GET /api/kpi/summary – the latest single value of each KPI in one call, for the landing page. Sections run
+// concurrently, each with its own retry budget and warnings; a failing or unconfigured integration only sets
+// that section's "error". Each section reports its meta.source-style provenance as "source". Always 200.
+func kpiSummary(c *gin.Context) {
+	sections := []kpiSummarySection{
+		{"time_in_build", summaryTimeInBuild},
+		{"vos_tickets", func(sc *gin.Context) (gin.H, error) {
+			return summaryCreatedResolved(sc, "VOS Summary", vosJQLSetting, "vos_tickets")
+		}},
+		{"build_bugs", func(sc *gin.Context) (gin.H, error) {
+			return summaryCreatedResolved(sc, "BuildBugs Summary", buildBugsSetting, "build_bugs")
+		}},
+		{"mtbf", summaryMTBF},
+		{"buildkite", summaryBuildkite},
+	}
+
+	start := time.Now()
+	results := make([]gin.H, len(sections))
+	tasks := make([]func() error, len(sections))
+	for i, s := range sections {
+		i, s := i, s
+		sc := c.Copy()
+		sc.Request = c.Request.WithContext(withRequestValues(c.Request.Context()))
+		tasks[i] = func() error {
+			sectionStart := time.Now()
+			out, err := s.compute(sc)
+			if err != nil {
+				logf(c.Request.Context(), "Summary", "%s failed: %v", s.name, err)
+				out = gin.H{"error": err.Error()}
+			}
+			out["duration_ms"] = time.Since(sectionStart).Milliseconds()
+			results[i] = out
+			return nil
+		}
+	}
+	_ = runBounded(c.Request.Context(), tasks, len(tasks))
+
+	kpis := gin.H{}
+	var failed []string
+	for i, s := range sections {
+		if results[i] == nil { // not started: the request was canceled
+			results[i] = gin.H{"error": "not computed: request canceled"}
+		}
+		if _, ok := results[i]["error"]; ok {
+			failed = append(failed, s.name)
+		}
+		kpis[s.name] = results[i]
+	}
+	if failed == nil {
+		failed = []string{}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"kpis": kpis,
+		"meta": gin.H{
+			"failed":          failed,
+			"duration_ms":     time.Since(start).Milliseconds(),
+			"bucket_timezone": bucketLocation.String(),
+			"jira_window":     fmt.Sprintf("last %d month (latest week and the one before)", summaryJIRAMonths),
+			"note":            "Latest bucket of each KPI; the current week is still in progress. Each section's error is independent.",
+		},
+	})
+}
+
+// summaryCreatedResolved is the latest week of a created/resolved KPI (VOS tickets, build bugs).
+func summaryCreatedResolved(c *gin.Context, component string, setting jiraQuerySetting, targetKey string) (gin.H, error) {
+	baseURL, email, token, ok := jiraConfig()
+	if !ok {
+		return nil, errors.New("JIRA not configured")
+	}
+	baseJQL, _, err := withPortfolioParent(c, setting.value)
+	if err != nil {
+		return nil, err
+	}
+	counts := issuesByWeek(c, baseURL, email, token, weeklyIssueQuery{
+		component:     component,
+		baseJQL:       baseJQL,
+		months:        summaryJIRAMonths,
+		trackResolved: true,
+		granularity:   granularityWeek,
+	})
+	if err := counts.summaryErr(); err != nil {
+		return nil, err
+	}
+	last := len(counts.weeks) - 1
+	return gin.H{
+		"source":         sourceLive,
+		"week":           counts.weeks[last],
+		"created":        counts.created[last],
+		"resolved":       counts.resolved[last],
+		"failed_queries": counts.failedQueries,
+		"target_status":  kpiTargetMeta(targetKey, intsToFloats(counts.created)),
+	}, nil
+}
+
+// summaryMTBF is the latest week's vehicle stability failure count.
+func summaryMTBF(c *gin.Context) (gin.H, error) {
+	baseURL, email, token, ok := jiraConfig()
+	if !ok {
+		return nil, errors.New("JIRA not configured")
+	}
+	counts := issuesByWeek(c, baseURL, email, token, weeklyIssueQuery{
+		component:   "MTBF Summary",
+		baseJQL:     mtbfJQLSetting.value,
+		months:      summaryJIRAMonths,
+		granularity: granularityWeek,
+	})
+	if err := counts.summaryErr(); err != nil {
+		return nil, err
+	}
+	last := len(counts.weeks) - 1
+	return gin.H{
+		"source":         sourceLive,
+		"week":           counts.weeks[last],
+		"failures":       counts.created[last],
+		"failed_queries": counts.failedQueries,
+	}, nil
+}
+
+// summaryErr fails a summary section when there is no week or every JIRA query for it failed.
+func (w weeklyIssueCounts) summaryErr() error {
+	switch {
+	case len(w.weeks) == 0:
+		return errors.New("no weeks in window")
+	case w.failedQueries == w.queries:
+		return fmt.Errorf("all %d JIRA week queries failed", w.queries)
+	}
+	return nil
+}
+
+// summaryBuildkite is the latest week's deployment failure rate and average deploy time, from the same
+// build cache as /api/kpi/buildkite-combined-all.
+func summaryBuildkite(c *gin.Context) (gin.H, error) {
+	token, _, ok := buildkiteConfig()
+	if !ok {
+		return nil, errors.New("BuildKite not configured")
+	}
+	orgs, err := selectBuildkiteOrgs(c)
+	if err != nil {
+		return nil, err
+	}
+	opts, err := buildkiteAggOptionsFromQuery(c)
+	if err != nil {
+		return nil, err
+	}
+	threeMonthsAgo := requestNow(c).AddDate(0, -3, 0)
+	builds, cacheStatus, err := getCachedBuilds(c.Request.Context(), token, orgs, buildkiteDeploymentPipelines, threeMonthsAgo)
+	if err != nil {
+		return nil, err
+	}
+	opts.Bucket = weekKey
+	m := aggregateBuildkite(builds, opts)
+
+	out := gin.H{
+		"source":               cacheStatus.source(),
+		"failure_rate":         nil,
+		"failure_rate_week":    nil,
+		"avg_deploy_time_mins": nil,
+		"deploy_time_week":     nil,
+		"target_status":        kpiTargetMeta("deployment_failure_rate", m.FailureRates),
+		"cache_age_sec":        int(cacheStatus.Age.Seconds()),
+		"stale":                cacheStatus.Stale,
+	}
+	if n := len(m.RateBuckets); n > 0 {
+		out["failure_rate"] = m.FailureRates[n-1]
+		out["failure_rate_week"] = m.RateBuckets[n-1]
+	}
+	if n := len(m.DurationBuckets); n > 0 {
+		out["avg_deploy_time_mins"] = m.AvgDurations[n-1]
+		out["deploy_time_week"] = m.DurationBuckets[n-1]
+	}
+	if n := len(m.FrequencyBuckets); n > 0 {
+		out["deployments"] = m.DeployCounts[n-1]
+		out["deployments_week"] = m.FrequencyBuckets[n-1]
+	}
+	return out, nil
+}
+
+// summaryTimeInBuild is each program's most recent weekly average build days, computed as /kpi/time-in-build
+// does with its defaults (saved filter, approximate build days, weekly buckets).
+func summaryTimeInBuild(c *gin.Context) (gin.H, error) {
+	baseURL, email, token, ok := jiraConfig()
+	if !ok {
+		return nil, errors.New("JIRA not configured")
+	}
+	rng := timeInBuildRange{maxEpics: kpiMaxEpicsDefault}
+	epicJQL, _, err := timeInBuildEpicJQL(c, rng, baseURL, email, token)
+	if err != nil {
+		return nil, fmt.Errorf("time in build filter: %w", err)
+	}
+	epicSet, err := fetchTimeInBuildEpics(c, baseURL, email, token, epicJQL, timeInBuildEpicFields, rng.maxEpics)
+	if err != nil {
+		return nil, fmt.Errorf("time in build epic search: %w", err)
+	}
+	bt := epicBuildTimes(c.Request.Context(), baseURL, email, token, epicSet.Epics, granularityWeek, nil, false)
+	weeks, avgs, _, _ := buildTimeSeries(bt.points, vehicleProgramNames())
+
+	// A program's average is 0 for weeks it had no finished epic, so take its last non-zero week
+	programs := gin.H{}
+	for name, series := range avgs {
+		latest := gin.H{"week": nil, "avg_days": nil}
+		for i := len(series) - 1; i >= 0; i-- {
+			if series[i] > 0 {
+				latest = gin.H{"week": weeks[i], "avg_days": series[i]}
+				break
+			}
+		}
+		programs[name] = latest
+	}
+	return gin.H{"source": sourceLive, "programs": programs}, nil
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The summary computes time in build directly, without a router to re-enter.
+func TestSummaryTimeInBuildLatestWeekPerProgram(t *testing.T) {
+	setJIRAEnv(t)
+	stubUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/rest/api/3/filter/") {
+			fmt.Fprint(w, `{"jql": "project = VBUILD"}`)
+			return
+		}
+		epic := func(key, summary, created, resolved string) string {
+			return fmt.Sprintf(`{"key": %q, "fields": {"summary": %q, "created": %q, "resolutiondate": %q}}`, key, summary, created, resolved)
+		}
+		fmt.Fprintf(w, `{"issues": [%s, %s, %s], "total": 3}`,
+			epic("VBUILD-1", "ROG-01 build", "2024-04-26T00:00:00.000+0000", "2024-05-06T00:00:00.000+0000"), // 2024-W19, 10 days
+			epic("VBUILD-2", "ROG-02 build", "2024-04-24T00:00:00.000+0000", "2024-05-14T00:00:00.000+0000"), // 2024-W20, 20 days
+			epic("VBUILD-3", "MCE-07 build", "2024-05-01T00:00:00.000+0000", "2024-05-07T00:00:00.000+0000"), // 2024-W19, 6 days
+		)
+	})
+	c, _ := testContext("/api/kpi/summary")
+	c.Request = c.Request.WithContext(withRequestValues(c.Request.Context()))
+
+	out, err := summaryTimeInBuild(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out["source"] != sourceLive {
+		t.Errorf("source = %v, want %s", out["source"], sourceLive)
+	}
+	programs := out["programs"].(gin.H)
+	for name, want := range map[string]string{programRogue: "2024-W20 20", programMachE: "2024-W19 6"} {
+		latest, _ := programs[name].(gin.H)
+		if got := fmt.Sprintf("%v %v", latest["week"], latest["avg_days"]); got != want {
+			t.Errorf("%s latest = %s, want %s", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Target direction: whether exceeding the target is good or bad for a KPI.
+const (
+	directionHigherIsBetter = "higher_is_better"
+	directionLowerIsBetter  = "lower_is_better"
+)
+
+// kpiTargetYellowBand is how far (relative to the target) a value can miss before it turns red.
+const kpiTargetYellowBand = 0.2
+
+// kpiTarget describes a KPI's target line and which side of it is good.
+type kpiTarget struct {
+	ID        string  `json:"id"`
+	Name      string  `json:"name"`
+	Endpoint  string  `json:"endpoint"`
+	Unit      string  `json:"unit"`
+	Target    float64 `json:"target"`
+	Direction string  `json:"direction"`
+}
+
+// defaultKPITargets is the catalog of KPIs with targets (the blue dashed lines on the dashboard).
+var defaultKPITargets = []kpiTarget{
+	{ID: "time_in_build", Name: "Time in Build", Endpoint: "/api/kpi/time-in-build", Unit: "days", Target: 5, Direction: directionLowerIsBetter},
+	{ID: "vos_tickets", Name: "VOS Tickets in Build", Endpoint: "/api/kpi/vos-tickets", Unit: "tickets/week", Target: 2, Direction: directionLowerIsBetter},
+	{ID: "build_bugs", Name: "Bug Tickets in Build", Endpoint: "/api/kpi/build-bugs", Unit: "bugs/week", Target: 1, Direction: directionLowerIsBetter},
+	{ID: "deployment_failure_rate", Name: "Deployment Failure Rate", Endpoint: "/api/kpi/buildkite-deployment-failure-rate", Unit: "%", Target: 5, Direction: directionLowerIsBetter},
+	{ID: "data_collection_efficiency", Name: "Data Collection Efficiency", Endpoint: "/api/kpi/data-collection-efficiency", Unit: "%", Target: 95, Direction: directionHigherIsBetter},
+}
+
+// kpiTargets is the catalog in use: defaultKPITargets with KPI_TARGETS applied by loadConfig.
+var kpiTargets = defaultKPITargets
+
+// parseKPITargets applies comma-separated id=target[:direction] overrides (e.g. "time_in_build=4,
+// data_collection_efficiency=97:higher_is_better") to base. A KPI's direction is kept when none is given.
+func parseKPITargets(raw string, base []kpiTarget) ([]kpiTarget, error) {
+	targets := append([]kpiTarget(nil), base...)
+	for _, entry := range strings.Split(raw, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		id, spec, found := strings.Cut(entry, "=")
+		id = strings.ToLower(strings.TrimSpace(id))
+		value, direction, hasDirection := strings.Cut(spec, ":")
+		f, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if !found || err != nil || math.IsInf(f, 0) || math.IsNaN(f) {
+			return nil, fmt.Errorf("invalid target %q (want id=number[:higher_is_better|lower_is_better])", entry)
+		}
+		direction = strings.ToLower(strings.TrimSpace(direction))
+		if hasDirection && direction != directionHigherIsBetter && direction != directionLowerIsBetter {
+			return nil, fmt.Errorf("invalid direction %q for %s (want %s or %s)", direction, id, directionHigherIsBetter, directionLowerIsBetter)
+		}
+		i := indexKPITarget(targets, id)
+		if i < 0 {
+			return nil, fmt.Errorf("unknown KPI %q", id)
+		}
+		targets[i].Target = f
+		if hasDirection {
+			targets[i].Direction = direction
+		}
+	}
+	return targets, nil
+}
+
+// loadKPITargets reads KPI_TARGETS, falling back to the defaults when unset or invalid.
+func loadKPITargets() []kpiTarget {
+	raw := strings.TrimSpace(os.Getenv("KPI_TARGETS"))
+	if raw == "" {
+		return defaultKPITargets
+	}
+	targets, err := parseKPITargets(raw, defaultKPITargets)
+	if err != nil {
+		log.Printf("[Config] Ignoring KPI_TARGETS=%q: %v", raw, err)
+		return defaultKPITargets
+	}
+	return targets
+}
+
+func indexKPITarget(targets []kpiTarget, id string) int {
+	for i, t := range targets {
+		if t.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func findKPITarget(id string) (kpiTarget, bool) {
+	if i := indexKPITarget(kpiTargets, id); i >= 0 {
+		return kpiTargets[i], true
+	}
+	return kpiTarget{}, false
+}
+
+// status returns green when value meets the target, yellow when it misses by at most kpiTargetYellowBand, else red.
+func (t kpiTarget) status(value float64) string {
+	band := t.Target * kpiTargetYellowBand
+	if t.Direction == directionHigherIsBetter {
+		switch {
+		case value >= t.Target:
+			return "green"
+		case value >= t.Target-band:
+			return "yellow"
+		}
+		return "red"
+	}
+	switch {
+	case value <= t.Target:
+		return "green"
+	case value <= t.Target+band:
+		return "yellow"
+	}
+	return "red"
+}
+
+// trend returns the sentiment of moving from prev to cur: improving, worsening, or flat.
+func (t kpiTarget) trend(prev, cur float64) string {
+	switch {
+	case cur == prev:
+		return "flat"
+	case (cur > prev) == (t.Direction == directionHigherIsBetter):
+		return "improving"
+	}
+	return "worsening"
+}
+
+// kpiTargetMeta summarizes the latest value of a weekly series against the KPI's target for meta.
+func kpiTargetMeta(id string, series []float64) gin.H {
+	t, ok := findKPITarget(id)
+	if !ok {
+		return nil
+	}
+	out := gin.H{
+		"target":    t.Target,
+		"direction": t.Direction,
+		"unit":      t.Unit,
+		"status":    "unknown",
+	}
+	if len(series) == 0 {
+		return out
+	}
+	latest := series[len(series)-1]
+	out["latest"] = latest
+	out["status"] = t.status(latest)
+	if len(series) > 1 {
+		out["trend"] = t.trend(series[len(series)-2], latest)
+	}
+	return out
+}
+
+// intsToFloats converts a count series for kpiTargetMeta.
+func intsToFloats(in []int) []float64 {
+	out := make([]float64, len(in))
+	for i, v := range in {
+		out[i] = float64(v)
+	}
+	return out
+}
+
+// GET /api/kpi/catalog – KPIs with targets and direction so the frontend colors status and trends consistently
+func kpiCatalog(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"kpis":        kpiTargets,
+		"yellow_band": kpiTargetYellowBand,
+		"note":        "status: green meets target; yellow misses by at most yellow_band × target; red otherwise. trend sentiment follows direction.",
+	})
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseKPITargets(t *testing.T) {
+	targets, err := parseKPITargets("time_in_build=4, data_collection_efficiency=97:higher_is_better,build_bugs=3:HIGHER_IS_BETTER", defaultKPITargets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for id, want := range map[string]kpiTarget{
+		"time_in_build":              {Target: 4, Direction: directionLowerIsBetter}, // direction kept
+		"data_collection_efficiency": {Target: 97, Direction: directionHigherIsBetter},
+		"build_bugs":                 {Target: 3, Direction: directionHigherIsBetter},
+		"vos_tickets":                {Target: 2, Direction: directionLowerIsBetter}, // unlisted: default
+	} {
+		got := targets[indexKPITarget(targets, id)]
+		if got.Target != want.Target || got.Direction != want.Direction {
+			t.Errorf("%s = %v %s, want %v %s", id, got.Target, got.Direction, want.Target, want.Direction)
+		}
+	}
+	if defaultKPITargets[indexKPITarget(defaultKPITargets, "time_in_build")].Target != 5 {
+		t.Error("overrides modified defaultKPITargets")
+	}
+
+	for raw, wantErr := range map[string]string{
+		"time_in_build":            "invalid target",
+		"time_in_build=fast":       "invalid target",
+		"time_in_build=4:sideways": "invalid direction",
+		"lead_time=3":              "unknown KPI",
+	} {
+		if _, err := parseKPITargets(raw, defaultKPITargets); err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Errorf("%q: error %v, want %q", raw, err, wantErr)
+		}
+	}
+}
+
+func TestKPITargetMetaFollowsConfiguredDirection(t *testing.T) {
+	targets, err := parseKPITargets("deployment_failure_rate=5:higher_is_better", defaultKPITargets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setForTest(t, &kpiTargets, targets)
+	meta := kpiTargetMeta("deployment_failure_rate", []float64{8, 10})
+	if meta["status"] != "green" || meta["trend"] != "improving" || meta["direction"] != directionHigherIsBetter {
+		t.Errorf("meta = %v, want green and improving once higher is better", meta)
+	}
+}